@@ -0,0 +1,172 @@
+package converter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/adapters/file"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const ffmpegBinary = "ffmpeg"
+const ffprobeBinary = "ffprobe"
+
+// FFmpeg uses local execution with ffmpeg to process audio and video files.
+type FFmpeg struct {
+	binary      string
+	probeBinary string
+}
+
+func NewFFmpeg() (*FFmpeg, error) {
+	for _, binary := range []string{ffmpegBinary, ffprobeBinary} {
+		// #nosec G204: no user input
+		_, err := exec.Command(binary, "-version").Output()
+		if err != nil {
+			log.Debug().Str("command", binary).Msg("binary not found")
+			return nil, fmt.Errorf("%s binary not available", binary)
+		}
+
+		log.Debug().Str("command", binary).Msg("binary found")
+	}
+
+	return &FFmpeg{binary: ffmpegBinary, probeBinary: ffprobeBinary}, nil
+}
+
+func (f *FFmpeg) ExtractAudio(ctx context.Context, videoURL string) ([]byte, error) {
+	data, err := file.DownloadFile(ctx, videoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	extension := extensionFromURL(videoURL)
+	path, err := file.SaveTempFile(data, extension)
+	if err != nil {
+		return nil, err
+	}
+
+	outFile := fmt.Sprintf("%saudio.mp3", strings.TrimSuffix(path, extension))
+	command := createExtractCommand(f.binary, path, outFile)
+
+	defer file.RemoveTempFile(path)
+	defer file.RemoveTempFile(outFile)
+
+	log.Debug().
+		Strs("command", command).
+		Str("outFile", outFile).
+		Str("path", path).
+		Msg("extracting audio")
+
+	// #nosec G204: no user input, paths are generated
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("error executing ffmpeg command: %w", err)
+	}
+
+	log.Debug().Msg("ffmpeg command finished")
+
+	return file.GetTempFile(outFile)
+}
+
+func (f *FFmpeg) SplitAudio(ctx context.Context, audioURL string, segment, overlap time.Duration) ([][]byte, error) {
+	if overlap >= segment {
+		return nil, errors.New("segment overlap must be shorter than the segment length")
+	}
+
+	data, err := file.DownloadFile(ctx, audioURL)
+	if err != nil {
+		return nil, err
+	}
+
+	extension := extensionFromURL(audioURL)
+	path, err := file.SaveTempFile(data, extension)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.RemoveTempFile(path)
+
+	duration, err := f.probeDuration(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments [][]byte
+	for start := time.Duration(0); start < duration; start += segment - overlap {
+		outFile := fmt.Sprintf("%s%d.mp3", strings.TrimSuffix(path, extension), len(segments))
+		command := createSegmentCommand(f.binary, path, outFile, start, segment)
+
+		log.Debug().
+			Strs("command", command).
+			Str("outFile", outFile).
+			Msg("extracting audio segment")
+
+		// #nosec G204: no user input, paths are generated
+		err = exec.CommandContext(ctx, command[0], command[1:]...).Run()
+		if err != nil {
+			file.RemoveTempFile(outFile)
+			return nil, fmt.Errorf("error executing ffmpeg command: %w", err)
+		}
+
+		out, err := file.GetTempFile(outFile)
+		file.RemoveTempFile(outFile)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, out)
+
+		if start+segment >= duration {
+			break
+		}
+	}
+
+	log.Debug().Dur("duration", duration).Int("segments", len(segments)).Msg("split audio")
+
+	return segments, nil
+}
+
+// probeDuration reads the duration of a local media file with ffprobe.
+func (f *FFmpeg) probeDuration(ctx context.Context, path string) (time.Duration, error) {
+	// #nosec G204: no user input, path is generated
+	out, err := exec.CommandContext(ctx, f.probeBinary, "-v", "error", "-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1", path).Output()
+	if err != nil {
+		return 0, fmt.Errorf("error executing ffprobe command: %w", err)
+	}
+
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing media duration: %w", err)
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// extensionFromURL returns the file extension of a URL, or none for data URIs.
+func extensionFromURL(url string) string {
+	if strings.HasPrefix(url, "data:") {
+		return ""
+	}
+
+	return filepath.Ext(url)
+}
+
+// createSegmentCommand builds an ffmpeg command that encodes a section of the input's audio track as mono MP3.
+func createSegmentCommand(binary, inFile, outFile string, start, length time.Duration) []string {
+	return []string{binary, "-y", "-loglevel", "error",
+		"-ss", strconv.FormatFloat(start.Seconds(), 'f', 3, 64),
+		"-t", strconv.FormatFloat(length.Seconds(), 'f', 3, 64),
+		"-i", inFile, "-vn", "-ac", "1", "-b:a", "64k", outFile}
+}
+
+// createExtractCommand builds an ffmpeg command that drops the video stream and encodes the audio track as mono MP3.
+func createExtractCommand(binary, inFile, outFile string) []string {
+	return []string{binary, "-y", "-loglevel", "error", "-i", inFile, "-vn", "-ac", "1", "-b:a", "64k", outFile}
+}
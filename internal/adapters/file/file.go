@@ -2,18 +2,25 @@ package file
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/gofrs/uuid/v5"
 	"github.com/rs/zerolog/log"
 )
 
-// DownloadFile returns the byte content of a file on a provided URL.
+// DownloadFile returns the byte content of a file on a provided URL. Base64 encoded data URIs are decoded directly.
 func DownloadFile(ctx context.Context, path string) ([]byte, error) {
+	if strings.HasPrefix(path, "data:") {
+		return decodeDataURI(path)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		err = fmt.Errorf("error creating request %w", err)
@@ -86,3 +93,23 @@ func RemoveTempFile(path string) {
 	}
 	log.Debug().Str("path", path).Msg("cleaned up temp file")
 }
+
+// EncodeDataURI returns the given bytes as a base64 encoded data URI with the provided MIME type.
+func EncodeDataURI(mimeType string, data []byte) string {
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+}
+
+// decodeDataURI returns the payload of a base64 encoded data URI.
+func decodeDataURI(uri string) ([]byte, error) {
+	_, encoded, ok := strings.Cut(uri, ";base64,")
+	if !ok {
+		return nil, errors.New("unsupported data URI, only base64 encoding is supported")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding data URI: %w", err)
+	}
+
+	return data, nil
+}
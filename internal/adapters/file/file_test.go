@@ -51,6 +51,43 @@ func TestDownloadFile(t *testing.T) {
 	}
 }
 
+func TestDownloadFile_DataURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name: "base64 data URI",
+			uri:  "data:audio/mpeg;base64,dGVzdA==",
+			want: []byte("test"),
+		},
+		{
+			name:    "not base64 encoded",
+			uri:     "data:text/plain,test",
+			wantErr: true,
+		},
+		{
+			name:    "invalid base64",
+			uri:     "data:audio/mpeg;base64,%%%",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := DownloadFile(t.Context(), tc.uri)
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, res)
+			}
+		})
+	}
+}
+
 func TestSaveTempFile(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -128,3 +165,31 @@ func TestGetTempFile(t *testing.T) {
 		})
 	}
 }
+
+func TestEncodeDataURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		data     []byte
+		want     string
+	}{
+		{
+			name:     "audio",
+			mimeType: "audio/mpeg",
+			data:     []byte("test"),
+			want:     "data:audio/mpeg;base64,dGVzdA==",
+		},
+		{
+			name:     "empty data",
+			mimeType: "text/plain",
+			data:     []byte{},
+			want:     "data:text/plain;base64,",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, EncodeDataURI(tc.mimeType, tc.data))
+		})
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"hsbot/internal/core/domain"
 	"io"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"text/template"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
@@ -17,23 +20,33 @@ import (
 	"github.com/revrost/go-openrouter"
 )
 
-// OpenRouter wraps the OpenRouter API.
+// OpenRouter wraps the OpenRouter API. The models are guarded by mu, as they are enriched from the model catalog
+// while serving requests. The default models only need their keyword and identifier, which never change.
 type OpenRouter struct {
 	client        OpenRouterClient
 	Models        []domain.Model
 	defaultModels []domain.Model
 	systemPrompt  string
+	mu            sync.RWMutex
 }
 
 // OpenRouterClient wraps all used methods from *openrouter.Client. Used for mocking in tests.
 type OpenRouterClient interface {
 	CreateChatCompletion(ctx context.Context,
 		ccr openrouter.ChatCompletionRequest) (openrouter.ChatCompletionResponse, error)
+	ListModels(ctx context.Context) ([]openrouter.Model, error)
 }
 
-func NewOpenRouter(apiKey, systemPrompt string) (*OpenRouter, error) {
+// NewOpenRouter creates an OpenRouter generator. The system prompt is a text/template, rendered with the
+// domain.BotIdentity, e.g. {{.Username}}.
+func NewOpenRouter(apiKey, systemPrompt string, identity domain.BotIdentity) (*OpenRouter, error) {
+	renderedPrompt, err := renderSystemPrompt(systemPrompt, identity)
+	if err != nil {
+		return nil, err
+	}
+
 	or := &OpenRouter{
-		systemPrompt: systemPrompt,
+		systemPrompt: renderedPrompt,
 		client: openrouter.NewClient(
 			apiKey,
 			openrouter.WithXTitle("hsbot"),
@@ -41,7 +54,7 @@ func NewOpenRouter(apiKey, systemPrompt string) (*OpenRouter, error) {
 	}
 
 	var models []domain.Model
-	err := viper.UnmarshalKey("openrouter.models", &models)
+	err = viper.UnmarshalKey("openrouter.models", &models)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to unmarshal openrouter models from config")
 		return nil, err
@@ -68,8 +81,22 @@ func NewOpenRouter(apiKey, systemPrompt string) (*OpenRouter, error) {
 	return or, nil
 }
 
+// GenerateFromPrompt answers the prompts with the model requested by the last one, or the default models. Models
+// the role of the context may not use are refused with a *domain.ModelNotAllowedError.
 func (o *OpenRouter) GenerateFromPrompt(
 	ctx context.Context, prompts []domain.Prompt) (domain.ModelResponse, error) {
+	role := domain.RoleFromContext(ctx)
+
+	model, err := o.findModelForPrompt(&prompts[len(prompts)-1], role)
+	if err != nil {
+		return domain.ModelResponse{}, err
+	}
+
+	defaults := o.allowedDefaultModels(role)
+	if model.Identifier == "" && len(defaults) == 0 {
+		return domain.ModelResponse{}, &domain.ModelNotAllowedError{Model: o.defaultModels[0].Keyword, Role: role.Name}
+	}
+
 	messages := make([]openrouter.ChatCompletionMessage, len(prompts)+1)
 
 	messages[0] = openrouter.ChatCompletionMessage{
@@ -97,10 +124,6 @@ func (o *OpenRouter) GenerateFromPrompt(
 		}
 	}
 
-	latestPrompt := prompts[len(prompts)-1].Prompt
-	model := o.findModelByMessage(&latestPrompt)
-	prompts[len(prompts)-1].Prompt = latestPrompt
-
 	ccr := openrouter.ChatCompletionRequest{
 		Messages: messages,
 		Usage: &openrouter.IncludeUsage{
@@ -109,14 +132,15 @@ func (o *OpenRouter) GenerateFromPrompt(
 		Model: model.Identifier,
 	}
 
-	return o.retryCompletion(ctx, ccr)
+	return o.retryCompletion(ctx, ccr, defaults)
 }
 
 const ORProviderError = "Provider returned error"
 
+// retryCompletion requests the completion, retrying with the default models on provider errors.
 func (o *OpenRouter) retryCompletion(ctx context.Context,
-	ccr openrouter.ChatCompletionRequest) (domain.ModelResponse, error) {
-	for i := -1; i < len(o.defaultModels); i++ {
+	ccr openrouter.ChatCompletionRequest, defaults []domain.Model) (domain.ModelResponse, error) {
+	for i := -1; i < len(defaults); i++ {
 		if ccr.Model == "" {
 			// no specific model requested, start with first index from default models
 			i = 0
@@ -124,7 +148,7 @@ func (o *OpenRouter) retryCompletion(ctx context.Context,
 
 		// we're either on a retry or default model iteration
 		if i != -1 {
-			ccr.Model = o.defaultModels[i].Identifier
+			ccr.Model = defaults[i].Identifier
 		}
 
 		resp, err := o.client.CreateChatCompletion(ctx, ccr)
@@ -148,7 +172,7 @@ func (o *OpenRouter) retryCompletion(ctx context.Context,
 	}
 
 	return domain.ModelResponse{},
-		fmt.Errorf("failed to get a response from openrouter, retry count: %d", len(o.defaultModels)-1)
+		fmt.Errorf("failed to get a response from openrouter, retry count: %d", len(defaults)-1)
 }
 
 func createUserMessage(ctx context.Context, prompt domain.Prompt) (openrouter.ChatCompletionMessage, error) {
@@ -201,16 +225,79 @@ func createUserMessage(ctx context.Context, prompt domain.Prompt) (openrouter.Ch
 	}, nil
 }
 
-func (o *OpenRouter) findModelByMessage(message *string) domain.Model {
-	for _, model := range o.Models {
+// GetModels returns all configured models.
+func (o *OpenRouter) GetModels() []domain.Model {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return slices.Clone(o.Models)
+}
+
+// findModelForPrompt returns the model explicitly requested by a prompt, or the one of a #keyword in its text. The
+// keyword is removed from the text and stored as the requested model of the prompt. Models the role may not use are
+// refused.
+func (o *OpenRouter) findModelForPrompt(prompt *domain.Prompt, role domain.Role) (domain.Model, error) {
+	if prompt.Model != "" {
+		for _, model := range o.GetModels() {
+			if strings.EqualFold(model.Keyword, prompt.Model) {
+				if !role.AllowsModel(model.Keyword) {
+					return domain.Model{}, &domain.ModelNotAllowedError{Model: model.Keyword, Role: role.Name}
+				}
+				return model, nil
+			}
+		}
+	}
+
+	model, err := o.findModelByMessage(&prompt.Prompt, role)
+	if err != nil {
+		return domain.Model{}, err
+	}
+
+	prompt.Model = model.Keyword
+	return model, nil
+}
+
+func (o *OpenRouter) findModelByMessage(message *string, role domain.Role) (domain.Model, error) {
+	for _, model := range o.GetModels() {
 		lowercaseMessage := strings.ToLower(*message)
 		lowerCaseModel := strings.ToLower("#" + model.Keyword)
 		if strings.Contains(lowercaseMessage, lowerCaseModel) {
+			if !role.AllowsModel(model.Keyword) {
+				return domain.Model{}, &domain.ModelNotAllowedError{Model: model.Keyword, Role: role.Name}
+			}
+
 			i := strings.Index(lowercaseMessage, lowerCaseModel)
 			*message = (*message)[:i] + (*message)[i+len(lowerCaseModel):]
-			return model
+			return model, nil
+		}
+	}
+
+	return domain.Model{}, nil
+}
+
+// allowedDefaultModels returns the default models the role may use, in order of priority.
+func (o *OpenRouter) allowedDefaultModels(role domain.Role) []domain.Model {
+	var defaults []domain.Model
+	for _, model := range o.defaultModels {
+		if role.AllowsModel(model.Keyword) {
+			defaults = append(defaults, model)
 		}
 	}
 
-	return domain.Model{}
+	return defaults
+}
+
+// renderSystemPrompt executes the system prompt template with the bot identity.
+func renderSystemPrompt(systemPrompt string, identity domain.BotIdentity) (string, error) {
+	tmpl, err := template.New("system_prompt").Parse(systemPrompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse system prompt template: %w", err)
+	}
+
+	sb := &strings.Builder{}
+	if err := tmpl.Execute(sb, identity); err != nil {
+		return "", fmt.Errorf("failed to render system prompt template: %w", err)
+	}
+
+	return sb.String(), nil
 }
@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/revrost/go-openrouter"
+	"github.com/rs/zerolog/log"
+)
+
+// SyncCatalog enriches the configured models with the modalities, context length and prices of the OpenRouter model
+// catalog. Models missing from the catalog fail the sync with domain.ErrModelsNotInCatalog, the others are enriched
+// anyway.
+func (o *OpenRouter) SyncCatalog(ctx context.Context) error {
+	catalog, err := o.client.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch openrouter model catalog: %w", err)
+	}
+
+	byID := make(map[string]openrouter.Model, len(catalog))
+	for _, model := range catalog {
+		byID[model.ID] = model
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var missing []string
+	for i, model := range o.Models {
+		entry, ok := byID[model.Identifier]
+		if !ok {
+			// variants like :online or :floor share the catalog entry of their model
+			base, _, _ := strings.Cut(model.Identifier, ":")
+			entry, ok = byID[base]
+		}
+
+		if !ok {
+			missing = append(missing, model.Identifier)
+			continue
+		}
+
+		o.Models[i] = enrichModel(model, entry)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", domain.ErrModelsNotInCatalog, strings.Join(missing, ", "))
+	}
+
+	log.Debug().Int("models", len(o.Models)).Int("catalog", len(catalog)).Msg("synced openrouter model catalog")
+
+	return nil
+}
+
+// RunCatalogSync syncs the model catalog in the interval until the context is done, logging failures.
+func (o *OpenRouter) RunCatalogSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := o.SyncCatalog(ctx); err != nil {
+				log.Err(err).Msg("failed to sync openrouter model catalog")
+			}
+		}
+	}
+}
+
+// enrichModel returns the configured model with the details of its catalog entry.
+func enrichModel(model domain.Model, entry openrouter.Model) domain.Model {
+	model.InputModalities = entry.Architecture.InputModalities
+
+	switch {
+	case entry.ContextLength != nil:
+		model.ContextLength = int(*entry.ContextLength)
+	case entry.TopProvider.ContextLength != nil:
+		model.ContextLength = int(*entry.TopProvider.ContextLength)
+	}
+
+	model.PromptPrice = pricePerMillion(entry.Pricing.Prompt)
+	model.CompletionPrice = pricePerMillion(entry.Pricing.Completion)
+
+	return model
+}
+
+// pricePerMillion converts the price of a single token in the catalog, a decimal string in dollars, to the price of a
+// million tokens. Unknown prices are zero.
+func pricePerMillion(price string) float64 {
+	perToken, err := strconv.ParseFloat(price, 64)
+	if err != nil || perToken < 0 {
+		return 0
+	}
+
+	return perToken * 1_000_000
+}
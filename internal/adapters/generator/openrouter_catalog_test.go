@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/revrost/go-openrouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func catalogModel(id, prompt, completion string, contextLength int64, modalities ...string) openrouter.Model {
+	return openrouter.Model{
+		ID:            id,
+		Architecture:  openrouter.ModelArchitecture{InputModalities: modalities},
+		ContextLength: &contextLength,
+		Pricing:       openrouter.ModelPricing{Prompt: prompt, Completion: completion},
+	}
+}
+
+func TestOpenRouter_SyncCatalog(t *testing.T) {
+	catalog := []openrouter.Model{
+		catalogModel("anthropic/claude-sonnet-4", "0.000003", "0.000015", 200000, "text", "image"),
+		catalogModel("deepseek/deepseek-chat-v3-0324", "0.0000003", "0.00000088", 163840, "text"),
+		catalogModel("openrouter/auto", "-1", "-1", 2000000, "text"),
+	}
+
+	t.Run("enriches the configured models", func(t *testing.T) {
+		or := &OpenRouter{client: &mockClient{catalog: catalog}, Models: []domain.Model{
+			{Keyword: "claude", Identifier: "anthropic/claude-sonnet-4", Default: 1},
+			{Keyword: "deepseek", Identifier: "deepseek/deepseek-chat-v3-0324:floor"},
+			{Keyword: "auto", Identifier: "openrouter/auto"},
+		}}
+
+		require.NoError(t, or.SyncCatalog(t.Context()))
+
+		models := or.GetModels()
+		assert.Equal(t, domain.Model{Keyword: "claude", Identifier: "anthropic/claude-sonnet-4", Default: 1,
+			InputModalities: []string{"text", "image"}, ContextLength: 200000, PromptPrice: 3, CompletionPrice: 15},
+			roundPrices(models[0]))
+		assert.True(t, models[0].AcceptsImages())
+
+		assert.Equal(t, 163840, models[1].ContextLength, "variants use the catalog entry of their model")
+		assert.InDelta(t, 0.3, models[1].PromptPrice, 1e-9)
+		assert.False(t, models[1].AcceptsImages())
+
+		assert.Zero(t, models[2].PromptPrice, "dynamic prices are unknown")
+	})
+
+	t.Run("fails for models missing from the catalog", func(t *testing.T) {
+		or := &OpenRouter{client: &mockClient{catalog: catalog}, Models: []domain.Model{
+			{Keyword: "claude", Identifier: "anthropic/claude-sonnet-4", Default: 1},
+			{Keyword: "gone", Identifier: "vendor/retired-model"},
+		}}
+
+		err := or.SyncCatalog(t.Context())
+		require.EqualError(t, err, "models not in the openrouter catalog: vendor/retired-model")
+		require.ErrorIs(t, err, domain.ErrModelsNotInCatalog)
+		assert.Equal(t, 200000, or.GetModels()[0].ContextLength, "known models are enriched anyway")
+	})
+
+	t.Run("fails if the catalog is unavailable", func(t *testing.T) {
+		or := &OpenRouter{client: &mockClient{catalogErr: errors.New("offline")}}
+
+		err := or.SyncCatalog(t.Context())
+		require.ErrorContains(t, err, "offline")
+		assert.NotErrorIs(t, err, domain.ErrModelsNotInCatalog, "startup goes on without the catalog")
+	})
+}
+
+func TestOpenRouter_RunCatalogSync(t *testing.T) {
+	contextLength := int64(1000)
+	client := &mockClient{catalog: []openrouter.Model{{ID: "openai/gpt-4.1", ContextLength: &contextLength}}}
+	or := &OpenRouter{client: client, Models: []domain.Model{{Keyword: "gpt", Identifier: "openai/gpt-4.1"}}}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		or.RunCatalogSync(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		return or.GetModels()[0].ContextLength == 1000
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+// roundPrices rounds away the floating point error of converting per token prices.
+func roundPrices(model domain.Model) domain.Model {
+	model.PromptPrice = float64(int(model.PromptPrice*1e6+0.5)) / 1e6
+	model.CompletionPrice = float64(int(model.CompletionPrice*1e6+0.5)) / 1e6
+	return model
+}
@@ -18,6 +18,8 @@ import (
 type mockClient struct {
 	createChatCompletionFunc func(ctx context.Context,
 		ccr openrouter.ChatCompletionRequest) (openrouter.ChatCompletionResponse, error)
+	catalog    []openrouter.Model
+	catalogErr error
 }
 
 func (m *mockClient) CreateChatCompletion(ctx context.Context,
@@ -25,6 +27,10 @@ func (m *mockClient) CreateChatCompletion(ctx context.Context,
 	return m.createChatCompletionFunc(ctx, ccr)
 }
 
+func (m *mockClient) ListModels(_ context.Context) ([]openrouter.Model, error) {
+	return m.catalog, m.catalogErr
+}
+
 func TestNewOpenRouter(t *testing.T) {
 	orig := viper.Get("openrouter.models")
 	defer viper.Set("openrouter.models", orig)
@@ -46,13 +52,16 @@ func TestNewOpenRouter(t *testing.T) {
 
 	apiKey := "fakeApiKey"
 	systemPrompt := "system test"
-	or, err := NewOpenRouter(apiKey, systemPrompt)
+	or, err := NewOpenRouter(apiKey, systemPrompt+" {{.Username}}", domain.BotIdentity{Username: "hsbot"})
 
 	require.NoError(t, err)
 	assert.NotNil(t, or)
 	assert.Len(t, or.Models, 2)
 	assert.Equal(t, expectedModels, or.Models)
-	assert.Equal(t, systemPrompt, or.systemPrompt)
+	assert.Equal(t, systemPrompt+" hsbot", or.systemPrompt)
+
+	_, err = NewOpenRouter(apiKey, "{{.Unclosed", domain.BotIdentity{})
+	require.Error(t, err)
 }
 
 func TestOpenRouterGenerator_GenerateFromPrompt(t *testing.T) {
@@ -279,7 +288,7 @@ func TestOpenRouter_RetryCompletion(t *testing.T) {
 				Messages: []openrouter.ChatCompletionMessage{},
 				Usage:    &openrouter.IncludeUsage{Include: true},
 			}
-			resp, err := gen.retryCompletion(t.Context(), req)
+			resp, err := gen.retryCompletion(t.Context(), req, gen.defaultModels)
 			if tt.wantErr != "" {
 				require.ErrorContains(t, err, tt.wantErr)
 			} else {
@@ -338,9 +347,113 @@ func TestFindModelByMessage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			msg := tt.message
-			gotModel := handler.findModelByMessage(&msg)
+			gotModel, err := handler.findModelByMessage(&msg, domain.Role{})
+			require.NoError(t, err)
 			assert.Equal(t, tt.wantModel, gotModel)
 			assert.Equal(t, tt.wantMessage, msg)
 		})
 	}
 }
+
+func TestFindModelForPrompt(t *testing.T) {
+	models := []domain.Model{
+		{Keyword: "gpt"},
+		{Keyword: "claude"},
+	}
+
+	handler := &OpenRouter{
+		Models: models,
+	}
+
+	guest := domain.Role{Name: domain.RoleGuest, Models: []string{"gpt"}}
+
+	tests := []struct {
+		name       string
+		prompt     domain.Prompt
+		role       domain.Role
+		wantModel  domain.Model
+		wantPrompt domain.Prompt
+		wantErr    string
+	}{
+		{
+			name:       "explicit model wins over keyword",
+			prompt:     domain.Prompt{Prompt: "Hello #gpt", Model: "Claude"},
+			wantModel:  models[1],
+			wantPrompt: domain.Prompt{Prompt: "Hello #gpt", Model: "Claude"},
+		},
+		{
+			name:       "keyword is stored as model",
+			prompt:     domain.Prompt{Prompt: "Hello #gpt"},
+			wantModel:  models[0],
+			wantPrompt: domain.Prompt{Prompt: "Hello ", Model: "gpt"},
+		},
+		{
+			name:       "unknown explicit model falls back to keyword",
+			prompt:     domain.Prompt{Prompt: "Hello", Model: "unknown"},
+			wantModel:  domain.Model{},
+			wantPrompt: domain.Prompt{Prompt: "Hello"},
+		},
+		{
+			name:       "allowed keyword",
+			prompt:     domain.Prompt{Prompt: "Hello #gpt"},
+			role:       guest,
+			wantModel:  models[0],
+			wantPrompt: domain.Prompt{Prompt: "Hello ", Model: "gpt"},
+		},
+		{
+			name:       "disallowed keyword",
+			prompt:     domain.Prompt{Prompt: "Hello #claude"},
+			role:       guest,
+			wantPrompt: domain.Prompt{Prompt: "Hello #claude"},
+			wantErr:    "the model claude is not available to guests, see /models",
+		},
+		{
+			name:       "disallowed explicit model",
+			prompt:     domain.Prompt{Prompt: "Hello", Model: "claude"},
+			role:       guest,
+			wantPrompt: domain.Prompt{Prompt: "Hello", Model: "claude"},
+			wantErr:    "the model claude is not available to guests, see /models",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := tt.prompt
+			gotModel, err := handler.findModelForPrompt(&prompt, tt.role)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantModel, gotModel)
+			assert.Equal(t, tt.wantPrompt, prompt)
+		})
+	}
+}
+
+func TestOpenRouter_GenerateFromPromptDefaultModelsOfRole(t *testing.T) {
+	var requested []string
+	mock := &mockClient{
+		createChatCompletionFunc: func(_ context.Context,
+			ccr openrouter.ChatCompletionRequest) (openrouter.ChatCompletionResponse, error) {
+			requested = append(requested, ccr.Model)
+			return openrouter.ChatCompletionResponse{}, errors.New("Provider returned error")
+		},
+	}
+
+	defaults := []domain.Model{
+		{Keyword: "claude", Identifier: "anthropic/claude", Default: 1},
+		{Keyword: "gpt", Identifier: "openai/gpt", Default: 2},
+	}
+	gen := &OpenRouter{client: mock, Models: defaults, defaultModels: defaults}
+
+	guest := domain.WithRole(t.Context(), domain.Role{Name: domain.RoleGuest, Models: []string{"gpt"}})
+	_, err := gen.GenerateFromPrompt(guest, []domain.Prompt{{Prompt: "hi", Author: domain.User}})
+	require.Error(t, err)
+	assert.Equal(t, []string{"openai/gpt"}, requested, "only the allowed default models are tried")
+
+	none := domain.WithRole(t.Context(), domain.Role{Name: domain.RoleGuest, Models: []string{"grok"}})
+	_, err = gen.GenerateFromPrompt(none, []domain.Prompt{{Prompt: "hi", Author: domain.User}})
+	require.EqualError(t, err, "the model claude is not available to guests, see /models")
+	assert.Len(t, requested, 1)
+}
@@ -2,11 +2,16 @@ package handler
 
 import (
 	"context"
+	"fmt"
+	"hsbot/internal/adapters/file"
 	"hsbot/internal/core/domain"
 	"hsbot/internal/core/domain/command"
 	"hsbot/internal/core/port"
 	"hsbot/internal/core/service"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf16"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -17,10 +22,62 @@ type Command struct {
 	commandRegistry port.CommandRegistry
 	timeout         time.Duration
 	auth            service.Authorizer
+	roles           service.RoleResolver
+	limiter         service.RateLimiter
+	audioConverter  port.AudioConverter
+	identity        domain.BotIdentity
+	middlewares     []port.Middleware
+	sender          port.TextSender
 }
 
-func NewCommand(commandRegistry port.CommandRegistry, timeout time.Duration, authorizer service.Authorizer) *Command {
-	return &Command{commandRegistry: commandRegistry, timeout: timeout, auth: authorizer}
+type CommandParams struct {
+	Registry   port.CommandRegistry
+	Timeout    time.Duration
+	Authorizer service.Authorizer
+	// Roles resolves the role of users pressing buttons. Commands get it from the Permissions middleware.
+	Roles service.RoleResolver
+	// Limiter rate limits button presses of commands. Messages are rate limited by the middlewares.
+	Limiter        service.RateLimiter
+	AudioConverter port.AudioConverter
+	Identity       domain.BotIdentity
+	// Middlewares wrap every command and listener, before the media of the message is resolved.
+	Middlewares []port.Middleware
+	// Sender answers the button presses the handler rejects, so the button stops loading.
+	Sender port.TextSender
+}
+
+func NewCommand(p CommandParams) *Command {
+	return &Command{
+		commandRegistry: p.Registry,
+		timeout:         p.Timeout,
+		auth:            p.Authorizer,
+		roles:           p.Roles,
+		limiter:         p.Limiter,
+		audioConverter:  p.AudioConverter,
+		identity:        p.Identity,
+		middlewares:     p.Middlewares,
+		sender:          p.Sender,
+	}
+}
+
+// MatchNonCommand reports whether an update carries a message that isn't addressed to a command.
+func MatchNonCommand(update *models.Update) bool {
+	if update.Message == nil {
+		return false
+	}
+
+	return !strings.HasPrefix(update.Message.Text, "/") && !strings.HasPrefix(update.Message.Caption, "/")
+}
+
+// MatchMediaCaption reports whether an update carries a photo, audio, voice, video or document with a caption
+// starting with a command prefix.
+func MatchMediaCaption(update *models.Update) bool {
+	if update.Message == nil || !strings.HasPrefix(update.Message.Caption, "/") {
+		return false
+	}
+
+	m := update.Message
+	return m.Photo != nil || m.Audio != nil || m.Voice != nil || m.Video != nil || m.Document != nil
 }
 
 func (c *Command) Handle(ctx context.Context, b *bot.Bot, update *models.Update) {
@@ -28,14 +85,19 @@ func (c *Command) Handle(ctx context.Context, b *bot.Bot, update *models.Update)
 		return
 	}
 
-	if update.Message.Photo != nil {
+	if update.Message.Text == "" {
 		update.Message.Text = update.Message.Caption
 	}
 
+	if !strings.HasPrefix(update.Message.Text, "/") {
+		c.handleListeners(ctx, b, update)
+		return
+	}
+
 	log.Debug().Str("message", update.Message.Text).Msg("received command")
 
-	if !c.auth.IsAuthorized(ctx, update.Message.Chat.ID) {
-		log.Debug().Msg("not authorized")
+	if !command.IsAddressedTo(update.Message.Text, c.identity.Username) {
+		log.Debug().Str("message", update.Message.Text).Msg("command addressed to another bot")
 		return
 	}
 
@@ -46,53 +108,250 @@ func (c *Command) Handle(ctx context.Context, b *bot.Bot, update *models.Update)
 		return
 	}
 
-	replyToMessageID := new(int)
-	var quotedText string
-	var isReplyToBot bool
-	var replyToUsername string
+	config := c.commandRegistry.GetConfig(cmd)
+	if !config.AllowsChat(update.Message.Chat.ID) {
+		log.Debug().Str("command", cmd).Int64("chatId", update.Message.Chat.ID).Msg("command not allowed in chat")
+		return
+	}
 
-	if update.Message.ReplyToMessage != nil {
-		botUser, err := b.GetMe(ctx)
+	timeout := c.timeout
+	if config.Timeout > 0 {
+		timeout = config.Timeout
+	}
+
+	message := c.buildMessage(update.Message)
+	media := c.resolveMedia(b, update, commandHandler)
+
+	c.respond(ctx, message, commandHandler.Respond, commandHandler.GetCommand(), timeout, media)
+}
+
+// HandleCallback routes inline keyboard button presses to the callback handler registered for their namespace.
+func (c *Command) HandleCallback(ctx context.Context, _ *bot.Bot, update *models.Update) {
+	if update.CallbackQuery == nil || update.CallbackQuery.Message.Message == nil {
+		return
+	}
+
+	query := update.CallbackQuery
+	log.Debug().Str("data", query.Data).Msg("received callback")
+
+	namespace, payload := command.ParseCallbackData(query.Data)
+	callbackHandler, err := c.commandRegistry.GetCallback(namespace)
+	if err != nil {
+		log.Debug().Str("namespace", namespace).Msg("no handler for callback")
+		c.answerCallback(ctx, query.ID, "this button is no longer supported")
+		return
+	}
+
+	message := c.buildMessage(query.Message.Message)
+
+	public, ok := callbackHandler.(port.PublicCallbackHandler)
+	if !(ok && public.IsPublic()) && !c.auth.IsAdmin(query.From.ID) && !c.auth.IsChatAuthorized(message.ChatID) {
+		log.Debug().Msg("not authorized")
+		c.answerCallback(ctx, query.ID, "this chat is not authorized to use the bot")
+		return
+	}
+
+	if bound := boundCommand(callbackHandler); bound != "" &&
+		!c.allowsCallback(ctx, bound, query, message, rateLimited(callbackHandler, payload)) {
+		return
+	}
+
+	callback := &domain.Callback{
+		ID:       query.ID,
+		ChatID:   message.ChatID,
+		UserID:   query.From.ID,
+		Username: getUserNameFromMessage(&query.From),
+		Data:     payload,
+		Message:  message,
+	}
+
+	ctx = domain.WithRole(ctx, c.roles.Role(callback.UserID, callback.ChatID))
+
+	handle := func(ctx context.Context, timeout time.Duration, _ *domain.Message) error {
+		return callbackHandler.HandleCallback(ctx, timeout, callback)
+	}
+	chain := command.Chain(namespace, handle, command.Recover(), command.Logging())
+
+	go func() {
+		err := chain(ctx, c.timeout, message)
 		if err != nil {
-			log.Err(err).Str("command", cmd).Msg("failed to get bot user")
+			log.Err(err).Str("namespace", namespace).Msg("failed to handle callback")
+		}
+	}()
+}
+
+// allowsCallback applies the config and, if limited, the rate limit of the command a callback handler is bound to,
+// answering rejected button presses without messaging the chat.
+func (c *Command) allowsCallback(ctx context.Context, bound string, query *models.CallbackQuery,
+	message *domain.Message, limited bool) bool {
+	_, err := c.commandRegistry.Get(bound)
+	config := c.commandRegistry.GetConfig(bound)
+	if err != nil || !config.AllowsChat(message.ChatID) {
+		log.Debug().Str("command", bound).Int64("chatId", message.ChatID).Msg("command not allowed in chat")
+		c.answerCallback(ctx, query.ID, "this button is not available in this chat")
+		return false
+	}
+
+	if !limited {
+		return true
+	}
+
+	requester := &domain.Message{ID: message.ID, ChatID: message.ChatID, UserID: query.From.ID}
+	if wait := c.limiter.Wait(requester, bound, config.RateLimit); wait > 0 {
+		log.Debug().Str("command", bound).Int64("userId", query.From.ID).Msg("rate limited")
+		c.answerCallback(ctx, query.ID, fmt.Sprintf("too many requests, try again in %s", wait))
+		return false
+	}
+
+	return true
+}
+
+// rateLimited reports whether a button press counts towards the rate limit of the command its handler is bound to.
+func rateLimited(handler port.CallbackHandler, data string) bool {
+	limited, ok := handler.(port.RateLimitedCallbackHandler)
+	return !ok || limited.IsRateLimited(data)
+}
+
+// answerCallback acknowledges a rejected button press with a notification to the user.
+func (c *Command) answerCallback(ctx context.Context, callbackID string, text string) {
+	err := c.sender.AnswerCallbackQuery(ctx, callbackID, text)
+	if err != nil {
+		log.Err(err).Msg("failed to answer callback query")
+	}
+}
+
+// handleListeners passes messages that aren't addressed to a command to the first matching listener.
+func (c *Command) handleListeners(ctx context.Context, b *bot.Bot, update *models.Update) {
+	message := c.buildMessage(update.Message)
+
+	listener, err := c.commandRegistry.GetListener(message)
+	if err != nil {
+		log.Trace().Int64("chatId", message.ChatID).Msg("no listener for message")
+		return
+	}
+
+	log.Debug().Int("messageId", message.ID).Msg("received message for listener")
+
+	name, timeout := command.ListenerName, c.timeout
+	if bound := boundCommand(listener); bound != "" {
+		_, err = c.commandRegistry.Get(bound)
+		config := c.commandRegistry.GetConfig(bound)
+		if err != nil || !config.AllowsChat(message.ChatID) {
+			log.Debug().Str("command", bound).Int64("chatId", message.ChatID).Msg("command not allowed in chat")
 			return
 		}
-		if update.Message.ReplyToMessage.From.ID == botUser.ID {
-			isReplyToBot = true
-			quotedText = update.Message.ReplyToMessage.Text
-		} else {
-			replyToUsername = update.Message.ReplyToMessage.From.Username
-			quotedText = update.Message.ReplyToMessage.Text
+
+		name = bound
+		if config.Timeout > 0 {
+			timeout = config.Timeout
 		}
+	}
+
+	c.respond(ctx, message, listener.Respond, name, timeout, c.resolveMedia(b, update, listener))
+}
 
-		*replyToMessageID = update.Message.ReplyToMessage.ID
+// boundCommand returns the command a listener or callback handler acts for, or an empty string if there is none.
+func boundCommand(handler any) string {
+	bound, ok := handler.(port.CommandBound)
+	if !ok {
+		return ""
 	}
 
-	imageURL := make(chan string)
-	audioURL := make(chan string)
+	return bound.BoundCommand()
+}
 
-	go getOptionalImage(ctx, b, update, imageURL)
-	go getOptionalAudio(ctx, b, update, audioURL)
+// respond passes a message through the middlewares and resolving its media to the given respond function in the
+// background.
+func (c *Command) respond(ctx context.Context, message *domain.Message, respond port.RespondFunc, name string,
+	timeout time.Duration, media port.Middleware) {
+	chain := command.Chain(name, respond, append(slices.Clone(c.middlewares), media)...)
 
 	go func() {
-		err := commandHandler.Respond(ctx, c.timeout, &domain.Message{
-			ID:               update.Message.ID,
-			ChatID:           update.Message.Chat.ID,
-			Text:             update.Message.Text,
-			Username:         getUserNameFromMessage(update.Message.From),
-			ReplyToMessageID: replyToMessageID,
-			ReplyToUsername:  replyToUsername,
-			IsReplyToBot:     isReplyToBot,
-			QuotedText:       quotedText,
-			ImageURL:         <-imageURL,
-			AudioURL:         <-audioURL,
-		})
+		err := chain(ctx, timeout, message)
 		if err != nil {
-			log.Err(err).Str("command", cmd).Msg("failed to respond to command")
+			log.Err(err).Str("command", name).Msg("failed to respond to command")
 		}
 	}()
 }
 
+// resolveMedia is a middleware adding the optional image of an update to the message. The audio and the document are
+// only resolved for handlers reading them, as extracting audio from videos and downloading documents is expensive.
+func (c *Command) resolveMedia(b *bot.Bot, update *models.Update, handler any) port.Middleware {
+	withAudio := readsAudio(handler)
+	withDocument := readsDocuments(handler)
+
+	return func(_ string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			imageURL := make(chan string)
+			audioURL := make(chan string, 1)
+			document := make(chan *domain.Document, 1)
+
+			go getOptionalImage(ctx, b, update, imageURL)
+			if withAudio {
+				go c.getOptionalAudio(ctx, b, update, audioURL)
+			} else {
+				audioURL <- ""
+			}
+			if withDocument {
+				go getOptionalDocument(ctx, b, update, document)
+			} else {
+				document <- nil
+			}
+
+			message.ImageURL = <-imageURL
+			message.AudioURL = <-audioURL
+			message.Document = <-document
+
+			return next(ctx, timeout, message)
+		}
+	}
+}
+
+// readsAudio reports whether a command or listener needs the audio of the message.
+func readsAudio(handler any) bool {
+	reader, ok := handler.(port.AudioReader)
+	return ok && reader.ReadsAudio()
+}
+
+// readsDocuments reports whether a command needs the document of the message.
+func readsDocuments(handler any) bool {
+	reader, ok := handler.(port.DocumentReader)
+	return ok && reader.ReadsDocuments()
+}
+
+// buildMessage maps a telegram update to a domain.Message, without resolving media URLs.
+func (c *Command) buildMessage(msg *models.Message) *domain.Message {
+	message := &domain.Message{
+		ID:               msg.ID,
+		ChatID:           msg.Chat.ID,
+		ChatTitle:        msg.Chat.Title,
+		UserID:           msg.From.ID,
+		Text:             msg.Text,
+		Username:         getUserNameFromMessage(msg.From),
+		ReplyToMessageID: new(int),
+		IsPrivateChat:    msg.Chat.Type == models.ChatTypePrivate,
+	}
+
+	if msg.Voice != nil {
+		message.VoiceDuration = time.Duration(msg.Voice.Duration) * time.Second
+	}
+
+	message.MentionsBot = mentionsUser(msg, c.identity.Username)
+
+	if msg.ReplyToMessage != nil {
+		if msg.ReplyToMessage.From.ID == c.identity.ID {
+			message.IsReplyToBot = true
+		} else {
+			message.ReplyToUsername = msg.ReplyToMessage.From.Username
+		}
+
+		message.QuotedText = msg.ReplyToMessage.Text
+		*message.ReplyToMessageID = msg.ReplyToMessage.ID
+	}
+
+	return message
+}
+
 func getOptionalImage(ctx context.Context, b *bot.Bot, update *models.Update, url chan<- string) {
 	var photos []models.PhotoSize
 
@@ -121,35 +380,139 @@ func getOptionalImage(ctx context.Context, b *bot.Bot, update *models.Update, ur
 	url <- b.FileDownloadLink(f)
 }
 
-func getOptionalAudio(ctx context.Context, b *bot.Bot, update *models.Update, url chan<- string) {
-	var fileID string
-	if update.Message.Audio != nil {
-		fileID = update.Message.Audio.FileID
+// mentionsUser reports whether a mention entity of the message text or caption is the @username, in any case. Entity
+// offsets and lengths count UTF-16 code units.
+func mentionsUser(message *models.Message, username string) bool {
+	text, entities := message.Text, message.Entities
+	if len(entities) == 0 {
+		text, entities = message.Caption, message.CaptionEntities
 	}
 
-	if update.Message.ReplyToMessage != nil {
-		if update.Message.ReplyToMessage.Voice != nil {
-			fileID = update.Message.ReplyToMessage.Voice.FileID
+	units := utf16.Encode([]rune(text))
+	for _, entity := range entities {
+		end := entity.Offset + entity.Length
+		if entity.Type != models.MessageEntityTypeMention || entity.Offset < 0 || end > len(units) {
+			continue
+		}
+
+		if strings.EqualFold(string(utf16.Decode(units[entity.Offset:end])), "@"+username) {
+			return true
 		}
+	}
 
-		if update.Message.ReplyToMessage.Audio != nil {
-			fileID = update.Message.ReplyToMessage.Audio.FileID
+	return false
+}
+
+// audioSource references a Telegram file carrying an audio track. Video files need their audio extracted first.
+type audioSource struct {
+	fileID  string
+	isVideo bool
+}
+
+// findAudioSource returns the audio carrying attachment of a message, if any.
+func findAudioSource(message *models.Message) audioSource {
+	switch {
+	case message.Voice != nil:
+		return audioSource{fileID: message.Voice.FileID}
+	case message.Audio != nil:
+		return audioSource{fileID: message.Audio.FileID}
+	case message.VideoNote != nil:
+		return audioSource{fileID: message.VideoNote.FileID, isVideo: true}
+	case message.Video != nil:
+		return audioSource{fileID: message.Video.FileID, isVideo: true}
+	case message.Document != nil && strings.HasPrefix(message.Document.MimeType, "audio/"):
+		return audioSource{fileID: message.Document.FileID}
+	case message.Document != nil && strings.HasPrefix(message.Document.MimeType, "video/"):
+		return audioSource{fileID: message.Document.FileID, isVideo: true}
+	default:
+		return audioSource{}
+	}
+}
+
+func (c *Command) getOptionalAudio(ctx context.Context, b *bot.Bot, update *models.Update, url chan<- string) {
+	source := findAudioSource(update.Message)
+
+	if update.Message.ReplyToMessage != nil {
+		if replySource := findAudioSource(update.Message.ReplyToMessage); replySource.fileID != "" {
+			source = replySource
 		}
 	}
 
-	if fileID == "" {
+	if source.fileID == "" {
 		url <- ""
 		return
 	}
 
-	f, err := b.GetFile(ctx, &bot.GetFileParams{FileID: fileID})
+	f, err := b.GetFile(ctx, &bot.GetFileParams{FileID: source.fileID})
 	if err != nil {
 		log.Error().Msg("error getting file from telegram api")
 		url <- ""
 		return
 	}
 
-	url <- b.FileDownloadLink(f)
+	if !source.isVideo {
+		url <- b.FileDownloadLink(f)
+		return
+	}
+
+	audio, err := c.audioConverter.ExtractAudio(ctx, b.FileDownloadLink(f))
+	if err != nil {
+		log.Err(err).Msg("error extracting audio from video")
+		url <- ""
+		return
+	}
+
+	url <- file.EncodeDataURI("audio/mpeg", audio)
+}
+
+// maxDocumentSize limits the size of documents downloaded for commands.
+const maxDocumentSize = 1 << 20
+
+// findDocument returns the document of a message that isn't an audio or video file, if any.
+func findDocument(message *models.Message) *models.Document {
+	if message.Document == nil || strings.HasPrefix(message.Document.MimeType, "audio/") ||
+		strings.HasPrefix(message.Document.MimeType, "video/") {
+		return nil
+	}
+
+	return message.Document
+}
+
+func getOptionalDocument(ctx context.Context, b *bot.Bot, update *models.Update, document chan<- *domain.Document) {
+	doc := findDocument(update.Message)
+
+	if update.Message.ReplyToMessage != nil {
+		if replyDoc := findDocument(update.Message.ReplyToMessage); replyDoc != nil {
+			doc = replyDoc
+		}
+	}
+
+	if doc == nil {
+		document <- nil
+		return
+	}
+
+	if doc.FileSize > maxDocumentSize {
+		log.Debug().Int64("size", doc.FileSize).Msg("document too large to download")
+		document <- nil
+		return
+	}
+
+	f, err := b.GetFile(ctx, &bot.GetFileParams{FileID: doc.FileID})
+	if err != nil {
+		log.Error().Msg("error getting file from telegram api")
+		document <- nil
+		return
+	}
+
+	data, err := file.DownloadFile(ctx, b.FileDownloadLink(f))
+	if err != nil {
+		log.Err(err).Msg("error downloading document")
+		document <- nil
+		return
+	}
+
+	document <- &domain.Document{Name: doc.FileName, MimeType: doc.MimeType, Data: data}
 }
 
 const minSize = 80000
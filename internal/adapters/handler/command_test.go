@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"hsbot/internal/core/port"
+	"sync"
 	"testing"
 	"time"
 
 	"hsbot/internal/core/domain"
+	"hsbot/internal/core/domain/command"
 
 	"github.com/go-telegram/bot/models"
 	"github.com/stretchr/testify/assert"
@@ -16,7 +18,10 @@ import (
 
 type MockRegistry struct {
 	mock.Mock
-	cmd port.Command
+	cmd      port.Command
+	listener port.Listener
+	callback port.CallbackHandler
+	config   domain.CommandConfig
 }
 
 func (m *MockRegistry) Get(cmd string) (port.Command, error) {
@@ -24,7 +29,11 @@ func (m *MockRegistry) Get(cmd string) (port.Command, error) {
 	return m.cmd, args.Error(1)
 }
 
-func (m *MockRegistry) Register(handler port.Command) {
+func (m *MockRegistry) GetConfig(_ string) domain.CommandConfig {
+	return m.config
+}
+
+func (m *MockRegistry) Register(handler port.Command, _ ...port.Middleware) {
 	m.cmd = handler
 	m.Called(handler)
 }
@@ -34,6 +43,32 @@ func (m *MockRegistry) ListCommands() []string {
 	return []string{"foo", "bar"}
 }
 
+func (m *MockRegistry) RegisterListener(listener port.Listener, _ ...port.Middleware) {
+	m.listener = listener
+	m.Called(listener)
+}
+
+func (m *MockRegistry) GetListener(msg *domain.Message) (port.Listener, error) {
+	args := m.Called(msg)
+	return m.listener, args.Error(1)
+}
+
+func (m *MockRegistry) DescribeCommands(chatID int64, admin bool) []domain.CommandInfo {
+	args := m.Called(chatID, admin)
+	infos, _ := args.Get(0).([]domain.CommandInfo)
+	return infos
+}
+
+func (m *MockRegistry) RegisterCallback(handler port.CallbackHandler) {
+	m.callback = handler
+	m.Called(handler)
+}
+
+func (m *MockRegistry) GetCallback(namespace string) (port.CallbackHandler, error) {
+	args := m.Called(namespace)
+	return m.callback, args.Error(1)
+}
+
 type MockAuthorizer struct {
 	mock.Mock
 }
@@ -43,16 +78,40 @@ func (m *MockAuthorizer) IsAuthorized(ctx context.Context, chatID int64) bool {
 	return args[0].(bool)
 }
 
+func (m *MockAuthorizer) IsChatAuthorized(chatID int64) bool {
+	args := m.Called(chatID)
+	return args[0].(bool)
+}
+
+func (m *MockAuthorizer) IsAdmin(_ int64) bool {
+	return false
+}
+
+// MockCallbackAnswerer records the answers to callback queries, other methods of the sender aren't implemented.
+type MockCallbackAnswerer struct {
+	port.TextSender
+	mock.Mock
+}
+
+func (m *MockCallbackAnswerer) AnswerCallbackQuery(ctx context.Context, callbackID string, text string) error {
+	args := m.Called(ctx, callbackID, text)
+	return args.Error(0)
+}
+
 type MockCmdHandler struct{ mock.Mock }
 
+func (m *MockCmdHandler) Matches(msg *domain.Message) bool {
+	args := m.Called(msg)
+	return args.Bool(0)
+}
+
 func (m *MockCmdHandler) Respond(ctx context.Context, timeout time.Duration, msg *domain.Message) error {
 	args := m.Called(ctx, timeout, msg)
 	return args.Error(0)
 }
 
 func (m *MockCmdHandler) GetCommand() string {
-	m.Called()
-	return ""
+	return "/mock"
 }
 
 func makeUpdate(txt string) *models.Update {
@@ -88,9 +147,8 @@ func TestCommandHandler_Handle(t *testing.T) {
 		{
 			name:   "unknown command",
 			update: makeUpdate("/unknown"),
-			mockSetup: func(r *MockRegistry, _ *MockCmdHandler, a *MockAuthorizer) {
+			mockSetup: func(r *MockRegistry, _ *MockCmdHandler, _ *MockAuthorizer) {
 				r.On("Get", "/unknown").Return(nil, errors.New("no handler"))
-				a.On("IsAuthorized", mock.Anything, mock.Anything).Return(true)
 			},
 			wantCalled: false,
 			wantMsg:    nil,
@@ -108,6 +166,7 @@ func TestCommandHandler_Handle(t *testing.T) {
 			wantMsg: &domain.Message{
 				ID:               1,
 				ChatID:           100,
+				UserID:           200,
 				Username:         "@bob",
 				ReplyToMessageID: new(int),
 				ReplyToUsername:  "",
@@ -131,6 +190,7 @@ func TestCommandHandler_Handle(t *testing.T) {
 			wantMsg: &domain.Message{
 				ID:               1,
 				ChatID:           100,
+				UserID:           200,
 				Username:         "@bob",
 				ReplyToMessageID: new(int),
 				ReplyToUsername:  "",
@@ -141,6 +201,71 @@ func TestCommandHandler_Handle(t *testing.T) {
 				Text:             "/hello@hsbot",
 			},
 		},
+		{
+			name:   "known command, not allowed in chat",
+			update: makeUpdate("/hello"),
+			mockSetup: func(r *MockRegistry, _ *MockCmdHandler, _ *MockAuthorizer) {
+				r.On("Get", "/hello").Return(nil, nil)
+				r.config = domain.CommandConfig{ChatIDs: []int64{42}}
+			},
+			wantCalled: false,
+			wantMsg:    nil,
+		},
+		{
+			name:   "known command with configured timeout",
+			update: makeUpdate("/hello"),
+			mockSetup: func(r *MockRegistry, ch *MockCmdHandler, a *MockAuthorizer) {
+				r.On("Get", "/hello").Return(ch, nil)
+				r.config = domain.CommandConfig{Timeout: time.Minute, ChatIDs: []int64{100}}
+				ch.On("Respond", mock.Anything, time.Minute,
+					mock.AnythingOfType("*domain.Message")).Return(nil)
+				a.On("IsAuthorized", mock.Anything, mock.Anything).Return(true)
+			},
+			wantCalled: true,
+			wantMsg:    nil,
+		},
+		{
+			name:   "command addressed to another bot",
+			update: makeUpdate("/hello@otherbot"),
+			mockSetup: func(_ *MockRegistry, _ *MockCmdHandler, _ *MockAuthorizer) {
+				// No call
+			},
+			wantCalled: false,
+			wantMsg:    nil,
+		},
+		{
+			name: "reply to bot with listener",
+			update: &models.Update{
+				Message: &models.Message{
+					ID:   1,
+					Text: "and then?",
+					Chat: models.Chat{ID: 100},
+					From: &models.User{ID: 200, Username: "bob", FirstName: "bob"},
+					ReplyToMessage: &models.Message{
+						ID:   7,
+						Text: "once upon a time",
+						From: &models.User{ID: 999, Username: "hsbot"},
+					},
+				},
+			},
+			mockSetup: func(r *MockRegistry, ch *MockCmdHandler, a *MockAuthorizer) {
+				r.On("GetListener", mock.Anything).Return(ch, nil)
+				ch.On("Respond", mock.Anything, mock.Anything,
+					mock.AnythingOfType("*domain.Message")).Return(nil)
+				a.On("IsChatAuthorized", mock.Anything).Return(true)
+			},
+			wantCalled: true,
+			wantMsg: &domain.Message{
+				ID:               1,
+				ChatID:           100,
+				UserID:           200,
+				Username:         "@bob",
+				ReplyToMessageID: func() *int { id := 7; return &id }(),
+				IsReplyToBot:     true,
+				QuotedText:       "once upon a time",
+				Text:             "and then?",
+			},
+		},
 		{
 			name:   "known command, Respond returns error",
 			update: makeUpdate("/fail"),
@@ -153,15 +278,54 @@ func TestCommandHandler_Handle(t *testing.T) {
 			wantCalled: true,
 			wantMsg:    nil,
 		},
+		{
+			name:   "plain message without listener",
+			update: makeUpdate("hello"),
+			mockSetup: func(r *MockRegistry, _ *MockCmdHandler, _ *MockAuthorizer) {
+				r.On("GetListener", mock.Anything).Return(nil, errors.New("no listener"))
+			},
+			wantCalled: false,
+			wantMsg:    nil,
+		},
+		{
+			name:   "plain message with listener",
+			update: makeUpdate("hello"),
+			mockSetup: func(r *MockRegistry, ch *MockCmdHandler, a *MockAuthorizer) {
+				r.On("GetListener", mock.Anything).Return(ch, nil)
+				ch.On("Respond", mock.Anything, mock.Anything,
+					mock.AnythingOfType("*domain.Message")).Return(nil)
+				a.On("IsChatAuthorized", mock.Anything).Return(true)
+			},
+			wantCalled: true,
+			wantMsg: &domain.Message{
+				ID:               1,
+				ChatID:           100,
+				UserID:           200,
+				Username:         "@bob",
+				ReplyToMessageID: new(int),
+				Text:             "hello",
+			},
+		},
 		{
 			name:   "known command, unauthorized",
 			update: makeUpdate("/fail"),
-			mockSetup: func(_ *MockRegistry, _ *MockCmdHandler, a *MockAuthorizer) {
+			mockSetup: func(r *MockRegistry, ch *MockCmdHandler, a *MockAuthorizer) {
+				r.On("Get", "/fail").Return(ch, nil)
 				a.On("IsAuthorized", mock.Anything, mock.Anything).Return(false)
 			},
 			wantCalled: false,
 			wantMsg:    nil,
 		},
+		{
+			name:   "plain message with listener, unauthorized",
+			update: makeUpdate("hello"),
+			mockSetup: func(r *MockRegistry, ch *MockCmdHandler, a *MockAuthorizer) {
+				r.On("GetListener", mock.Anything).Return(ch, nil)
+				a.On("IsChatAuthorized", mock.Anything).Return(false)
+			},
+			wantCalled: false,
+			wantMsg:    nil,
+		},
 	}
 
 	for _, tc := range tests {
@@ -170,10 +334,17 @@ func TestCommandHandler_Handle(t *testing.T) {
 			handler := new(MockCmdHandler)
 			ma := new(MockAuthorizer)
 			reg.cmd = handler
+			reg.listener = handler
 			// Prepare mocks for this test case
 			tc.mockSetup(reg, handler, ma)
 
-			ch := NewCommand(reg, 3*time.Second, ma)
+			ch := NewCommand(CommandParams{
+				Registry:    reg,
+				Timeout:     3 * time.Second,
+				Authorizer:  ma,
+				Identity:    domain.BotIdentity{ID: 999, Username: "hsbot"},
+				Middlewares: []port.Middleware{command.Authorize(ma)},
+			})
 			ch.Handle(t.Context(), nil, tc.update)
 
 			// as the Respond() call is a goroutine, wait for finish
@@ -204,6 +375,484 @@ func TestCommandHandler_Handle(t *testing.T) {
 	}
 }
 
+// MockBoundListener is a listener acting for the /chat command.
+type MockBoundListener struct{ *MockCmdHandler }
+
+func (m MockBoundListener) BoundCommand() string {
+	return "/chat"
+}
+
+func TestCommandHandler_BoundListener(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     domain.CommandConfig
+		getErr     error
+		wantCalled bool
+	}{
+		{
+			name:       "command config applies",
+			config:     domain.CommandConfig{ChatIDs: []int64{100}, Timeout: time.Minute},
+			wantCalled: true,
+		},
+		{
+			name:   "command not allowed in chat",
+			config: domain.CommandConfig{ChatIDs: []int64{1}},
+		},
+		{
+			name:   "command disabled",
+			getErr: errors.New("command not found"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := new(MockCmdHandler)
+			reg := &MockRegistry{listener: MockBoundListener{handler}, config: tc.config}
+			reg.On("GetListener", mock.Anything).Return(nil, nil)
+			reg.On("Get", "/chat").Return(nil, tc.getErr)
+			if tc.wantCalled {
+				handler.On("Respond", mock.Anything, time.Minute, mock.Anything).Return(nil)
+			}
+
+			var names []string
+			var mutex sync.Mutex
+			recordName := func(name string, next port.RespondFunc) port.RespondFunc {
+				mutex.Lock()
+				defer mutex.Unlock()
+				names = append(names, name)
+				return next
+			}
+
+			ch := NewCommand(CommandParams{
+				Registry:    reg,
+				Timeout:     3 * time.Second,
+				Identity:    domain.BotIdentity{ID: 999, Username: "hsbot"},
+				Middlewares: []port.Middleware{recordName},
+			})
+			ch.Handle(t.Context(), nil, makeUpdate("hello"))
+
+			time.Sleep(100 * time.Millisecond)
+
+			reg.AssertExpectations(t)
+			handler.AssertExpectations(t)
+			mutex.Lock()
+			defer mutex.Unlock()
+			if tc.wantCalled {
+				assert.Equal(t, []string{"/chat"}, names, "middlewares run under the bound command")
+			} else {
+				assert.Empty(t, handler.Calls)
+			}
+		})
+	}
+}
+
+type MockCallbackHandler struct{ mock.Mock }
+
+func (m *MockCallbackHandler) HandleCallback(ctx context.Context, timeout time.Duration,
+	callback *domain.Callback) error {
+	args := m.Called(ctx, timeout, callback)
+	return args.Error(0)
+}
+
+func (m *MockCallbackHandler) GetCallbackNamespace() string {
+	return "chat"
+}
+
+type MockPublicCallbackHandler struct{ *MockCallbackHandler }
+
+func (m MockPublicCallbackHandler) IsPublic() bool {
+	return true
+}
+
+// guestRoles makes everyone a guest.
+type guestRoles struct{}
+
+func (guestRoles) Role(_, _ int64) domain.Role {
+	return domain.Role{Name: domain.RoleGuest}
+}
+
+func TestCommandHandler_HandleCallback(t *testing.T) {
+	makeCallbackUpdate := func(data string) *models.Update {
+		return &models.Update{
+			CallbackQuery: &models.CallbackQuery{
+				ID:   "query",
+				Data: data,
+				From: models.User{ID: 200, Username: "bob"},
+				Message: models.MaybeInaccessibleMessage{Message: &models.Message{
+					ID:             5,
+					Text:           "answer",
+					Chat:           models.Chat{ID: 100},
+					From:           &models.User{ID: 999, Username: "hsbot"},
+					ReplyToMessage: &models.Message{ID: 4, From: &models.User{ID: 200, Username: "bob"}},
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		update     *models.Update
+		mockSetup  func(r *MockRegistry, ch *MockCallbackHandler, ma *MockAuthorizer)
+		public     bool
+		wantCalled bool
+		wantAnswer string
+	}{
+		{
+			name:       "no callback query",
+			update:     &models.Update{},
+			mockSetup:  func(_ *MockRegistry, _ *MockCallbackHandler, _ *MockAuthorizer) {},
+			wantCalled: false,
+		},
+		{
+			name:   "unknown namespace",
+			update: makeCallbackUpdate("unknown:foo"),
+			mockSetup: func(r *MockRegistry, _ *MockCallbackHandler, _ *MockAuthorizer) {
+				r.On("GetCallback", "unknown").Return(nil, errors.New("no handler"))
+			},
+			wantCalled: false,
+			wantAnswer: "this button is no longer supported",
+		},
+		{
+			name:   "unauthorized",
+			update: makeCallbackUpdate("chat:regen"),
+			mockSetup: func(r *MockRegistry, _ *MockCallbackHandler, a *MockAuthorizer) {
+				r.On("GetCallback", "chat").Return(nil, nil)
+				a.On("IsChatAuthorized", int64(100)).Return(false)
+			},
+			wantCalled: false,
+			wantAnswer: "this chat is not authorized to use the bot",
+		},
+		{
+			name:   "unauthorized, public handler",
+			update: makeCallbackUpdate("chat:regen"),
+			mockSetup: func(r *MockRegistry, ch *MockCallbackHandler, _ *MockAuthorizer) {
+				r.On("GetCallback", "chat").Return(nil, nil)
+				ch.On("HandleCallback", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			public:     true,
+			wantCalled: true,
+		},
+		{
+			name:   "routed to namespace handler",
+			update: makeCallbackUpdate("chat:model:gpt"),
+			mockSetup: func(r *MockRegistry, ch *MockCallbackHandler, a *MockAuthorizer) {
+				r.On("GetCallback", "chat").Return(nil, nil)
+				a.On("IsChatAuthorized", int64(100)).Return(true)
+				ch.On("HandleCallback", mock.MatchedBy(func(ctx context.Context) bool {
+					return domain.RoleFromContext(ctx).Name == domain.RoleGuest
+				}), mock.Anything, mock.MatchedBy(func(cb *domain.Callback) bool {
+					return cb.ID == "query" && cb.Data == "model:gpt" && cb.ChatID == 100 && cb.UserID == 200 &&
+						cb.Username == "@bob" && cb.Message.ID == 5 && *cb.Message.ReplyToMessageID == 4
+				})).Return(nil)
+			},
+			wantCalled: true,
+		},
+		{
+			name:   "panicking handler is recovered",
+			update: makeCallbackUpdate("chat:regen"),
+			mockSetup: func(r *MockRegistry, ch *MockCallbackHandler, a *MockAuthorizer) {
+				r.On("GetCallback", "chat").Return(nil, nil)
+				a.On("IsChatAuthorized", int64(100)).Return(true)
+				ch.On("HandleCallback", mock.Anything, mock.Anything, mock.Anything).Panic("boom")
+			},
+			wantCalled: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := new(MockRegistry)
+			handler := new(MockCallbackHandler)
+			ma := new(MockAuthorizer)
+			reg.callback = handler
+			if tc.public {
+				reg.callback = MockPublicCallbackHandler{handler}
+			}
+			tc.mockSetup(reg, handler, ma)
+
+			answerer := new(MockCallbackAnswerer)
+			if tc.wantAnswer != "" {
+				answerer.On("AnswerCallbackQuery", mock.Anything, "query", tc.wantAnswer).Return(nil)
+			}
+
+			ch := NewCommand(CommandParams{
+				Registry:   reg,
+				Timeout:    3 * time.Second,
+				Authorizer: ma,
+				Roles:      guestRoles{},
+				Identity:   domain.BotIdentity{ID: 999, Username: "hsbot"},
+				Sender:     answerer,
+			})
+			ch.HandleCallback(t.Context(), nil, tc.update)
+
+			time.Sleep(100 * time.Millisecond)
+
+			reg.AssertExpectations(t)
+			ma.AssertExpectations(t)
+			answerer.AssertExpectations(t)
+			if tc.wantCalled {
+				handler.AssertExpectations(t)
+			} else {
+				assert.Empty(t, handler.Calls)
+			}
+		})
+	}
+}
+
+// MockBoundCallbackHandler is a callback handler acting for the /chat command.
+type MockBoundCallbackHandler struct{ *MockCallbackHandler }
+
+func (m MockBoundCallbackHandler) BoundCommand() string {
+	return "/chat"
+}
+
+// IsRateLimited limits every button but the one going back.
+func (m MockBoundCallbackHandler) IsRateLimited(data string) bool {
+	return data != "back"
+}
+
+// mockLimiter allows a fixed number of requests.
+type mockLimiter struct {
+	left int
+}
+
+func (m *mockLimiter) Allow(_ context.Context, _ *domain.Message, _ string, _ domain.RateLimit) bool {
+	m.left--
+	return m.left >= 0
+}
+
+func (m *mockLimiter) Wait(_ *domain.Message, _ string, _ domain.RateLimit) time.Duration {
+	m.left--
+	if m.left < 0 {
+		return 30 * time.Second
+	}
+	return 0
+}
+
+func TestCommandHandler_HandleBoundCallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		config     domain.CommandConfig
+		requests   int
+		wantAnswer string
+	}{
+		{
+			name:     "allowed",
+			data:     "chat:regen",
+			config:   domain.CommandConfig{ChatIDs: []int64{100}},
+			requests: 1,
+		},
+		{
+			name:       "command not allowed in chat",
+			data:       "chat:regen",
+			config:     domain.CommandConfig{ChatIDs: []int64{1}},
+			requests:   1,
+			wantAnswer: "this button is not available in this chat",
+		},
+		{
+			name:       "rate limited",
+			data:       "chat:regen",
+			wantAnswer: "too many requests, try again in 30s",
+		},
+		{
+			name: "navigation isn't rate limited",
+			data: "chat:back",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			update := &models.Update{CallbackQuery: &models.CallbackQuery{
+				ID:   "query",
+				Data: tc.data,
+				From: models.User{ID: 200, Username: "bob"},
+				Message: models.MaybeInaccessibleMessage{Message: &models.Message{ID: 5, Chat: models.Chat{ID: 100},
+					From: &models.User{ID: 999, Username: "hsbot"}}},
+			}}
+
+			handler := new(MockCallbackHandler)
+			reg := &MockRegistry{callback: MockBoundCallbackHandler{handler}, config: tc.config}
+			reg.On("GetCallback", "chat").Return(nil, nil)
+			reg.On("Get", "/chat").Return(nil, nil)
+			ma := new(MockAuthorizer)
+			ma.On("IsChatAuthorized", int64(100)).Return(true)
+
+			answerer := new(MockCallbackAnswerer)
+			if tc.wantAnswer != "" {
+				answerer.On("AnswerCallbackQuery", mock.Anything, "query", tc.wantAnswer).Return(nil)
+			} else {
+				handler.On("HandleCallback", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			}
+
+			ch := NewCommand(CommandParams{
+				Registry:   reg,
+				Timeout:    3 * time.Second,
+				Authorizer: ma,
+				Roles:      guestRoles{},
+				Limiter:    &mockLimiter{left: tc.requests},
+				Sender:     answerer,
+			})
+			ch.HandleCallback(t.Context(), nil, update)
+
+			time.Sleep(100 * time.Millisecond)
+
+			answerer.AssertExpectations(t)
+			handler.AssertExpectations(t)
+			if tc.wantAnswer != "" {
+				assert.Empty(t, handler.Calls)
+			}
+		})
+	}
+}
+
+func TestMatchMediaCaption(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *models.Message
+		want    bool
+	}{
+		{
+			name:    "no message",
+			message: nil,
+			want:    false,
+		},
+		{
+			name:    "voice with command caption",
+			message: &models.Message{Caption: "/chat hi", Voice: &models.Voice{FileID: "v"}},
+			want:    true,
+		},
+		{
+			name:    "video with command caption",
+			message: &models.Message{Caption: "/transcribe", Video: &models.Video{FileID: "v"}},
+			want:    true,
+		},
+		{
+			name:    "document without command caption",
+			message: &models.Message{Caption: "hello", Document: &models.Document{FileID: "d"}},
+			want:    false,
+		},
+		{
+			name:    "caption without media",
+			message: &models.Message{Caption: "/chat"},
+			want:    false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, MatchMediaCaption(&models.Update{Message: tc.message}))
+		})
+	}
+}
+
+func Test_mentionsUser(t *testing.T) {
+	mention := func(offset, length int) []models.MessageEntity {
+		return []models.MessageEntity{{Type: models.MessageEntityTypeMention, Offset: offset, Length: length}}
+	}
+
+	assert.True(t, mentionsUser(&models.Message{Text: "@hsbot hi", Entities: mention(0, 6)}, "hsbot"))
+	assert.True(t, mentionsUser(&models.Message{Text: "hi @HSBot", Entities: mention(3, 6)}, "hsbot"))
+	assert.True(t, mentionsUser(&models.Message{Text: "👋 @hsbot", Entities: mention(3, 6)}, "hsbot"),
+		"offsets count UTF-16 code units")
+	assert.True(t, mentionsUser(&models.Message{Caption: "look @hsbot", CaptionEntities: mention(5, 6)}, "hsbot"))
+	assert.False(t, mentionsUser(&models.Message{Text: "@hsbotx hi", Entities: mention(0, 7)}, "hsbot"))
+	assert.False(t, mentionsUser(&models.Message{Text: "@alice and foo@hsbot.dev", Entities: mention(0, 6)},
+		"hsbot"))
+	assert.False(t, mentionsUser(&models.Message{
+		Text:     "/chat hi",
+		Entities: []models.MessageEntity{{Type: models.MessageEntityTypeBotCommand, Offset: 0, Length: 5}},
+	}, "hsbot"))
+	assert.False(t, mentionsUser(&models.Message{Text: "hi"}, "hsbot"))
+}
+
+func Test_findAudioSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *models.Message
+		want    audioSource
+	}{
+		{
+			name:    "voice",
+			message: &models.Message{Voice: &models.Voice{FileID: "voice"}},
+			want:    audioSource{fileID: "voice"},
+		},
+		{
+			name:    "audio",
+			message: &models.Message{Audio: &models.Audio{FileID: "audio"}},
+			want:    audioSource{fileID: "audio"},
+		},
+		{
+			name:    "video note needs extraction",
+			message: &models.Message{VideoNote: &models.VideoNote{FileID: "note"}},
+			want:    audioSource{fileID: "note", isVideo: true},
+		},
+		{
+			name:    "audio document",
+			message: &models.Message{Document: &models.Document{FileID: "doc", MimeType: "audio/ogg"}},
+			want:    audioSource{fileID: "doc"},
+		},
+		{
+			name:    "video document needs extraction",
+			message: &models.Message{Document: &models.Document{FileID: "doc", MimeType: "video/mp4"}},
+			want:    audioSource{fileID: "doc", isVideo: true},
+		},
+		{
+			name:    "unrelated document",
+			message: &models.Message{Document: &models.Document{FileID: "doc", MimeType: "application/pdf"}},
+			want:    audioSource{},
+		},
+		{
+			name:    "no attachment",
+			message: &models.Message{Text: "hi"},
+			want:    audioSource{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, findAudioSource(tc.message))
+		})
+	}
+}
+
+func Test_findDocument(t *testing.T) {
+	jsonDoc := &models.Document{FileID: "doc", MimeType: "application/json"}
+
+	tests := []struct {
+		name    string
+		message *models.Message
+		want    *models.Document
+	}{
+		{
+			name:    "json document",
+			message: &models.Message{Document: jsonDoc},
+			want:    jsonDoc,
+		},
+		{
+			name:    "audio document",
+			message: &models.Message{Document: &models.Document{FileID: "doc", MimeType: "audio/ogg"}},
+			want:    nil,
+		},
+		{
+			name:    "video document",
+			message: &models.Message{Document: &models.Document{FileID: "doc", MimeType: "video/mp4"}},
+			want:    nil,
+		},
+		{
+			name:    "no document",
+			message: &models.Message{Text: "hi"},
+			want:    nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, findDocument(tc.message))
+		})
+	}
+}
+
 func Test_findMediumSizedImage(t *testing.T) {
 	tests := []struct {
 		name   string
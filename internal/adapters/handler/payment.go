@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/domain/command"
+	"hsbot/internal/core/port"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/rs/zerolog/log"
+)
+
+// Payment passes the pre-checkout queries and successful payments of invoices to the payment handler. Its handlers
+// have to be registered before MatchNonCommand, which matches the messages of successful payments as well.
+type Payment struct {
+	handler port.PaymentHandler
+	timeout time.Duration
+}
+
+func NewPayment(handler port.PaymentHandler, timeout time.Duration) *Payment {
+	return &Payment{handler: handler, timeout: timeout}
+}
+
+// MatchPreCheckoutQuery reports whether an update asks to confirm a payment.
+func MatchPreCheckoutQuery(update *models.Update) bool {
+	return update.PreCheckoutQuery != nil
+}
+
+// MatchSuccessfulPayment reports whether an update carries the message of a successful payment.
+func MatchSuccessfulPayment(update *models.Update) bool {
+	return update.Message != nil && update.Message.SuccessfulPayment != nil
+}
+
+// HandlePreCheckoutQuery lets the payment handler accept or decline a payment. Telegram cancels payments whose query
+// isn't answered within 10 seconds.
+func (p *Payment) HandlePreCheckoutQuery(ctx context.Context, _ *bot.Bot, update *models.Update) {
+	if update.PreCheckoutQuery == nil {
+		return
+	}
+
+	q := update.PreCheckoutQuery
+	query := &domain.PreCheckout{
+		ID:       q.ID,
+		UserID:   q.From.ID,
+		Currency: q.Currency,
+		Amount:   q.TotalAmount,
+		Payload:  q.InvoicePayload,
+	}
+
+	// pre-checkout queries come from the private chat of the user
+	message := &domain.Message{ChatID: q.From.ID, UserID: q.From.ID}
+	handle := func(ctx context.Context, _ time.Duration, _ *domain.Message) error {
+		return p.handler.HandlePreCheckout(ctx, query)
+	}
+
+	err := p.chain("pre-checkout", handle)(ctx, p.timeout, message)
+	if err != nil {
+		log.Err(err).Str("queryId", q.ID).Msg("failed to handle pre-checkout query")
+	}
+}
+
+// HandleSuccessfulPayment passes a completed payment to the payment handler.
+func (p *Payment) HandleSuccessfulPayment(ctx context.Context, _ *bot.Bot, update *models.Update) {
+	if !MatchSuccessfulPayment(update) {
+		return
+	}
+
+	msg := update.Message
+	paid := msg.SuccessfulPayment
+	message := &domain.Message{
+		ID:            msg.ID,
+		ChatID:        msg.Chat.ID,
+		UserID:        msg.From.ID,
+		Username:      getUserNameFromMessage(msg.From),
+		IsPrivateChat: msg.Chat.Type == models.ChatTypePrivate,
+	}
+	payment := &domain.Payment{
+		ChatID:   msg.Chat.ID,
+		UserID:   msg.From.ID,
+		Username: message.Username,
+		Currency: paid.Currency,
+		Amount:   paid.TotalAmount,
+		Payload:  paid.InvoicePayload,
+		ChargeID: paid.TelegramPaymentChargeID,
+	}
+
+	handle := func(ctx context.Context, _ time.Duration, message *domain.Message) error {
+		return p.handler.HandlePayment(ctx, message, payment)
+	}
+
+	err := p.chain("payment", handle)(ctx, p.timeout, message)
+	if err != nil {
+		log.Err(err).Str("chargeId", payment.ChargeID).Msg("failed to handle payment")
+	}
+}
+
+// chain wraps handling a payment update with panic recovery, request logging and the timeout.
+func (p *Payment) chain(name string, handle port.RespondFunc) port.RespondFunc {
+	return command.Chain(name, handle, command.Recover(), command.Logging(), command.Timeout())
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hsbot/internal/adapters/sender"
+	"hsbot/internal/adapters/store"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/domain/command"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBotAPI is a Bot API server recording the requests of the bot.
+type fakeBotAPI struct {
+	mu       sync.Mutex
+	requests []fakeBotRequest
+}
+
+type fakeBotRequest struct {
+	method string
+	params map[string]string
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := map[string]string{}
+	for key, values := range r.MultipartForm.Value {
+		params[key] = values[0]
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeBotRequest{method: method, params: params})
+	f.mu.Unlock()
+
+	result := `true`
+	if strings.HasPrefix(method, "send") {
+		result = fmt.Sprintf(`{"message_id":1,"date":0,"chat":{"id":%s,"type":"group"}}`, params["chat_id"])
+	}
+
+	_, _ = fmt.Fprintf(w, `{"ok":true,"result":%s}`, result)
+}
+
+// take returns and forgets the recorded requests.
+func (f *fakeBotAPI) take() []fakeBotRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	requests := f.requests
+	f.requests = nil
+	return requests
+}
+
+func TestPayment_TopUp(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("payments.star_price", 0.01)
+	viper.Set("telegram.daily_user_spend_limit", 0.5)
+
+	api := &fakeBotAPI{}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	b, err := bot.New("4242:token", bot.WithServerURL(server.URL), bot.WithSkipGetMe(), bot.WithNotAsyncHandlers())
+	require.NoError(t, err)
+
+	wallet, err := store.NewWalletFile(filepath.Join(t.TempDir(), "wallet.jsonl"))
+	require.NoError(t, err)
+
+	telegram := sender.NewTelegram(b)
+	topUp, err := command.NewTopUp(wallet, telegram, telegram, "/topup")
+	require.NoError(t, err)
+
+	payments := NewPayment(topUp, time.Minute)
+	b.RegisterHandlerMatchFunc(MatchPreCheckoutQuery, payments.HandlePreCheckoutQuery)
+	b.RegisterHandlerMatchFunc(MatchSuccessfulPayment, payments.HandleSuccessfulPayment)
+
+	err = topUp.Respond(t.Context(), time.Minute, &domain.Message{ID: 5, ChatID: -100, UserID: 7,
+		Text: "/topup 250"})
+	require.NoError(t, err)
+
+	requests := api.take()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "sendInvoice", requests[0].method)
+	assert.Equal(t, "XTR", requests[0].params["currency"])
+	assert.Equal(t, "topup:7:250", requests[0].params["payload"])
+	assert.Empty(t, requests[0].params["provider_token"])
+
+	var prices []models.LabeledPrice
+	require.NoError(t, json.Unmarshal([]byte(requests[0].params["prices"]), &prices))
+	assert.Equal(t, 250, prices[0].Amount)
+
+	preCheckout := func(id string, userID int64, amount int) {
+		b.ProcessUpdate(t.Context(), &models.Update{PreCheckoutQuery: &models.PreCheckoutQuery{ID: id,
+			From: &models.User{ID: userID}, Currency: "XTR", TotalAmount: amount, InvoicePayload: "topup:7:250"}})
+	}
+
+	preCheckout("valid", 7, 250)
+	preCheckout("other user", 8, 250)
+	preCheckout("other amount", 7, 1)
+
+	requests = api.take()
+	require.Len(t, requests, 3)
+	for _, request := range requests {
+		assert.Equal(t, "answerPreCheckoutQuery", request.method)
+	}
+	assert.Equal(t, map[string]string{"pre_checkout_query_id": "valid", "ok": "true"}, requests[0].params)
+	assert.Equal(t, "false", requests[1].params["ok"])
+	assert.Contains(t, requests[1].params["error_message"], "belongs to another user")
+	assert.Equal(t, "false", requests[2].params["ok"])
+	assert.Contains(t, requests[2].params["error_message"], "invalid")
+
+	payment := &models.Update{Message: &models.Message{ID: 6, Chat: models.Chat{ID: -100, Type: models.ChatTypeGroup},
+		From: &models.User{ID: 7, Username: "alice"}, SuccessfulPayment: &models.SuccessfulPayment{Currency: "XTR",
+			TotalAmount: 250, InvoicePayload: "topup:7:250", TelegramPaymentChargeID: "charge-1"}}}
+
+	b.ProcessUpdate(t.Context(), payment)
+	b.ProcessUpdate(t.Context(), payment)
+
+	assert.InDelta(t, 2.5, wallet.Balance(7), 1e-9, "a redelivered payment is credited once")
+
+	requests = api.take()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "sendMessage", requests[0].method)
+	assert.Equal(t, "Thanks! $2.50 were added to your prepaid balance, which is now $2.50.",
+		requests[0].params["text"])
+}
+
+// panickingPaymentHandler panics on every update.
+type panickingPaymentHandler struct{}
+
+func (panickingPaymentHandler) HandlePreCheckout(_ context.Context, _ *domain.PreCheckout) error {
+	panic("boom")
+}
+
+func (panickingPaymentHandler) HandlePayment(_ context.Context, _ *domain.Message, _ *domain.Payment) error {
+	panic("boom")
+}
+
+func TestPayment_RecoversFromPanics(t *testing.T) {
+	payments := NewPayment(panickingPaymentHandler{}, time.Minute)
+
+	assert.NotPanics(t, func() {
+		payments.HandlePreCheckoutQuery(t.Context(), nil, &models.Update{PreCheckoutQuery: &models.PreCheckoutQuery{
+			ID: "query", From: &models.User{ID: 7}}})
+		payments.HandleSuccessfulPayment(t.Context(), nil, &models.Update{Message: &models.Message{ID: 6,
+			Chat: models.Chat{ID: 7}, From: &models.User{ID: 7}, SuccessfulPayment: &models.SuccessfulPayment{}}})
+	})
+}
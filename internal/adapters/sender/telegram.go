@@ -5,7 +5,9 @@ import (
 	"context"
 	"fmt"
 	"hsbot/internal/core/domain"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -16,9 +18,18 @@ const TelegramMessageLimit = 4096
 
 // TelegramBotAPI is a wrapper interface for all the used methods of the *bot.Bot struct. Used for mocking in tests.
 type TelegramBotAPI interface {
+	GetMe(ctx context.Context) (*models.User, error)
 	SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error)
 	SendPhoto(ctx context.Context, params *bot.SendPhotoParams) (*models.Message, error)
+	SendDocument(ctx context.Context, params *bot.SendDocumentParams) (*models.Message, error)
 	SendChatAction(ctx context.Context, params *bot.SendChatActionParams) (bool, error)
+	EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error)
+	EditMessageReplyMarkup(ctx context.Context, params *bot.EditMessageReplyMarkupParams) (*models.Message, error)
+	AnswerCallbackQuery(ctx context.Context, params *bot.AnswerCallbackQueryParams) (bool, error)
+	SetMyCommands(ctx context.Context, params *bot.SetMyCommandsParams) (bool, error)
+	DeleteMyCommands(ctx context.Context, params *bot.DeleteMyCommandsParams) (bool, error)
+	SendInvoice(ctx context.Context, params *bot.SendInvoiceParams) (*models.Message, error)
+	AnswerPreCheckoutQuery(ctx context.Context, params *bot.AnswerPreCheckoutQueryParams) (bool, error)
 }
 
 type Telegram struct {
@@ -29,23 +40,59 @@ func NewTelegram(bot TelegramBotAPI) *Telegram {
 	return &Telegram{bot: bot}
 }
 
+// GetBotIdentity resolves the telegram user the bot is running as.
+func (s *Telegram) GetBotIdentity(ctx context.Context) (domain.BotIdentity, error) {
+	user, err := s.bot.GetMe(ctx)
+	if err != nil {
+		return domain.BotIdentity{}, fmt.Errorf("failed to get bot user: %w", err)
+	}
+
+	return domain.BotIdentity{ID: user.ID, Username: user.Username, FirstName: user.FirstName}, nil
+}
+
 func (s *Telegram) SendMessageReply(
 	ctx context.Context,
 	message *domain.Message,
 	text string) (int, error) {
+	return s.sendReply(ctx, message, text, nil)
+}
+
+func (s *Telegram) SendMessageReplyWithKeyboard(ctx context.Context, message *domain.Message, text string,
+	keyboard domain.Keyboard) (int, error) {
+	markup := toInlineKeyboard(keyboard)
+	if markup == nil {
+		return s.sendReply(ctx, message, text, nil)
+	}
+
+	return s.sendReply(ctx, message, text, markup)
+}
+
+// sendReply sends text as reply, split into multiple messages when exceeding the telegram message limit. The reply
+// markup is attached to the last message. Messages without ID get a plain message in their chat.
+func (s *Telegram) sendReply(ctx context.Context, message *domain.Message, text string,
+	markup models.ReplyMarkup) (int, error) {
 	replies := (len(text) + TelegramMessageLimit - 1) / TelegramMessageLimit
 	lastSentID := -1
 
 	for i := range replies {
 		substr := text[i*TelegramMessageLimit : min(len(text), (i+1)*TelegramMessageLimit)]
-		sent, err := s.bot.SendMessage(ctx, &bot.SendMessageParams{
+		params := &bot.SendMessageParams{
 			ChatID: message.ChatID,
 			Text:   substr,
-			ReplyParameters: &models.ReplyParameters{
+		}
+
+		if message.ID != 0 {
+			params.ReplyParameters = &models.ReplyParameters{
 				MessageID: message.ID,
 				ChatID:    message.ChatID,
-			},
-		})
+			}
+		}
+
+		if i == replies-1 && markup != nil {
+			params.ReplyMarkup = markup
+		}
+
+		sent, err := s.bot.SendMessage(ctx, params)
 		if err != nil {
 			return -1, fmt.Errorf("failed to send message: %w", err)
 		}
@@ -58,6 +105,120 @@ func (s *Telegram) SendMessageReply(
 	return lastSentID, nil
 }
 
+func (s *Telegram) EditMessageText(ctx context.Context, chatID int64, messageID int, text string) error {
+	_, err := s.bot.EditMessageText(ctx, &bot.EditMessageTextParams{
+		ChatID:    chatID,
+		MessageID: messageID,
+		Text:      truncate(text, TelegramMessageLimit),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	log.Debug().Int64("chatID", chatID).Int("messageID", messageID).Str("text", text).Msg("edited message")
+
+	return nil
+}
+
+// truncate cuts a text to at most limit bytes, on a rune boundary so the text stays valid UTF-8.
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+
+	return text[:limit]
+}
+
+func (s *Telegram) EditMessageKeyboard(ctx context.Context, chatID int64, messageID int,
+	keyboard domain.Keyboard) error {
+	markup := toInlineKeyboard(keyboard)
+	if markup == nil {
+		markup = &models.InlineKeyboardMarkup{InlineKeyboard: [][]models.InlineKeyboardButton{}}
+	}
+
+	_, err := s.bot.EditMessageReplyMarkup(ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:      chatID,
+		MessageID:   messageID,
+		ReplyMarkup: markup,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to edit keyboard: %w", err)
+	}
+
+	log.Debug().Int64("chatID", chatID).Int("messageID", messageID).Msg("edited keyboard")
+
+	return nil
+}
+
+func (s *Telegram) AnswerCallbackQuery(ctx context.Context, callbackID string, text string) error {
+	_, err := s.bot.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callbackID,
+		Text:            text,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to answer callback query: %w", err)
+	}
+
+	return nil
+}
+
+// SendInvoice sends an invoice in Telegram Stars, which need no payment provider.
+func (s *Telegram) SendInvoice(ctx context.Context, message *domain.Message, invoice domain.Invoice) error {
+	_, err := s.bot.SendInvoice(ctx, &bot.SendInvoiceParams{
+		ChatID:      message.ChatID,
+		Title:       invoice.Title,
+		Description: invoice.Description,
+		Payload:     invoice.Payload,
+		Currency:    domain.CurrencyStars,
+		Prices:      []models.LabeledPrice{{Label: invoice.Title, Amount: invoice.Stars}},
+		ReplyParameters: &models.ReplyParameters{
+			MessageID: message.ID,
+			ChatID:    message.ChatID,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send invoice: %w", err)
+	}
+
+	log.Debug().Int64("chatID", message.ChatID).Int("stars", invoice.Stars).Msg("sent invoice")
+
+	return nil
+}
+
+func (s *Telegram) AnswerPreCheckoutQuery(ctx context.Context, queryID string, errorMessage string) error {
+	_, err := s.bot.AnswerPreCheckoutQuery(ctx, &bot.AnswerPreCheckoutQueryParams{
+		PreCheckoutQueryID: queryID,
+		OK:                 errorMessage == "",
+		ErrorMessage:       errorMessage,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to answer pre-checkout query: %w", err)
+	}
+
+	return nil
+}
+
+// toInlineKeyboard maps a domain.Keyboard to the telegram markup, returning nil for empty keyboards.
+func toInlineKeyboard(keyboard domain.Keyboard) *models.InlineKeyboardMarkup {
+	if len(keyboard) == 0 {
+		return nil
+	}
+
+	rows := make([][]models.InlineKeyboardButton, len(keyboard))
+	for i, row := range keyboard {
+		rows[i] = make([]models.InlineKeyboardButton, len(row))
+		for j, button := range row {
+			rows[i][j] = models.InlineKeyboardButton{Text: button.Text, CallbackData: button.Data}
+		}
+	}
+
+	return &models.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
+
 func (s *Telegram) SendImageURLReply(ctx context.Context, message *domain.Message, url string) error {
 	params := &bot.SendPhotoParams{
 		ChatID: message.ChatID,
@@ -99,6 +260,61 @@ func (s *Telegram) SendImageFileReply(ctx context.Context, message *domain.Messa
 	return nil
 }
 
+func (s *Telegram) SendDocumentReply(ctx context.Context, message *domain.Message, filename string,
+	data []byte) error {
+	params := &bot.SendDocumentParams{
+		ChatID:   message.ChatID,
+		Document: &models.InputFileUpload{Filename: filename, Data: bytes.NewReader(data)},
+		ReplyParameters: &models.ReplyParameters{
+			MessageID: message.ID,
+			ChatID:    message.ChatID,
+		},
+	}
+
+	_, err := s.bot.SendDocument(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to send document: %w", err)
+	}
+
+	log.Debug().Int64("chatID", message.ChatID).Str("filename", filename).Int("size", len(data)).
+		Msg("sent document reply")
+
+	return nil
+}
+
+func (s *Telegram) SetCommands(ctx context.Context, commands []domain.CommandInfo, chatID int64) error {
+	var scope models.BotCommandScope = &models.BotCommandScopeDefault{}
+	if chatID != 0 {
+		scope = &models.BotCommandScopeChat{ChatID: chatID}
+	}
+
+	if len(commands) == 0 {
+		_, err := s.bot.DeleteMyCommands(ctx, &bot.DeleteMyCommandsParams{Scope: scope})
+		if err != nil {
+			return fmt.Errorf("failed to delete commands: %w", err)
+		}
+
+		return nil
+	}
+
+	botCommands := make([]models.BotCommand, 0, len(commands))
+	for _, command := range commands {
+		botCommands = append(botCommands, models.BotCommand{
+			Command:     strings.TrimPrefix(command.Command, "/"),
+			Description: command.Description,
+		})
+	}
+
+	log.Debug().Int64("chatID", chatID).Int("commands", len(botCommands)).Msg("set commands")
+
+	_, err := s.bot.SetMyCommands(ctx, &bot.SetMyCommandsParams{Commands: botCommands, Scope: scope})
+	if err != nil {
+		return fmt.Errorf("failed to set commands: %w", err)
+	}
+
+	return nil
+}
+
 const ChatActionRepeatSeconds = 5
 
 func (s *Telegram) SendChatAction(ctx context.Context, chatID int64, action domain.Action) {
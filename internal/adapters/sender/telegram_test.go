@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"hsbot/internal/core/domain"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -17,6 +19,12 @@ type MockBot struct {
 	mock.Mock
 }
 
+func (m *MockBot) GetMe(ctx context.Context) (*models.User, error) {
+	args := m.Called(ctx)
+	user, _ := args.Get(0).(*models.User)
+	return user, args.Error(1)
+}
+
 func (m *MockBot) SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
 	args := m.Called(ctx, params)
 	msg, _ := args.Get(0).(*models.Message)
@@ -27,11 +35,70 @@ func (m *MockBot) SendPhoto(ctx context.Context, params *bot.SendPhotoParams) (*
 	msg, _ := args.Get(0).(*models.Message)
 	return msg, args.Error(1)
 }
+func (m *MockBot) SendDocument(ctx context.Context, params *bot.SendDocumentParams) (*models.Message, error) {
+	args := m.Called(ctx, params)
+	msg, _ := args.Get(0).(*models.Message)
+	return msg, args.Error(1)
+}
+func (m *MockBot) SetMyCommands(ctx context.Context, params *bot.SetMyCommandsParams) (bool, error) {
+	args := m.Called(ctx, params)
+	return args.Bool(0), args.Error(1)
+}
+func (m *MockBot) DeleteMyCommands(ctx context.Context, params *bot.DeleteMyCommandsParams) (bool, error) {
+	args := m.Called(ctx, params)
+	return args.Bool(0), args.Error(1)
+}
 func (m *MockBot) SendChatAction(ctx context.Context, params *bot.SendChatActionParams) (bool, error) {
 	args := m.Called(ctx, params)
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockBot) EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+	args := m.Called(ctx, params)
+	msg, _ := args.Get(0).(*models.Message)
+	return msg, args.Error(1)
+}
+
+func (m *MockBot) SendInvoice(ctx context.Context, params *bot.SendInvoiceParams) (*models.Message, error) {
+	args := m.Called(ctx, params)
+	msg, _ := args.Get(0).(*models.Message)
+	return msg, args.Error(1)
+}
+
+func (m *MockBot) AnswerPreCheckoutQuery(ctx context.Context, params *bot.AnswerPreCheckoutQueryParams) (bool, error) {
+	args := m.Called(ctx, params)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestTelegramSender_GetBotIdentity(t *testing.T) {
+	mb := new(MockBot)
+	sender := NewTelegram(mb)
+
+	mb.On("GetMe", mock.Anything).Return(&models.User{ID: 42, Username: "hsbot", FirstName: "HSBot"}, nil).Once()
+
+	identity, err := sender.GetBotIdentity(t.Context())
+	require.NoError(t, err)
+	require.Equal(t, domain.BotIdentity{ID: 42, Username: "hsbot", FirstName: "HSBot"}, identity)
+
+	mb.On("GetMe", mock.Anything).Return(nil, errors.New("fail")).Once()
+
+	_, err = sender.GetBotIdentity(t.Context())
+	require.Error(t, err)
+	mb.AssertExpectations(t)
+}
+
+func (m *MockBot) EditMessageReplyMarkup(ctx context.Context,
+	params *bot.EditMessageReplyMarkupParams) (*models.Message, error) {
+	args := m.Called(ctx, params)
+	msg, _ := args.Get(0).(*models.Message)
+	return msg, args.Error(1)
+}
+
+func (m *MockBot) AnswerCallbackQuery(ctx context.Context, params *bot.AnswerCallbackQueryParams) (bool, error) {
+	args := m.Called(ctx, params)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestTelegramSender_SendMessageReply(t *testing.T) {
 	b := make([]byte, TelegramMessageLimit+10)
 	for i := range TelegramMessageLimit + 10 {
@@ -107,6 +174,162 @@ func TestTelegramSender_SendMessageReply(t *testing.T) {
 	}
 }
 
+func TestTelegramSender_SendMessageWithoutReply(t *testing.T) {
+	mb := new(MockBot)
+	sender := NewTelegram(mb)
+
+	mb.On("SendMessage", mock.Anything, mock.MatchedBy(func(params *bot.SendMessageParams) bool {
+		return params.ChatID == int64(1001) && params.ReplyParameters == nil
+	})).Return(&models.Message{ID: 1}, nil).Once()
+
+	_, err := sender.SendMessageReply(t.Context(), &domain.Message{ChatID: 1001}, "hello")
+	require.NoError(t, err)
+	mb.AssertExpectations(t)
+}
+
+func TestTelegramSender_SendMessageReplyWithKeyboard(t *testing.T) {
+	b := make([]byte, TelegramMessageLimit+10)
+	for i := range TelegramMessageLimit + 10 {
+		b[i] = 42
+	}
+
+	mb := new(MockBot)
+	sender := NewTelegram(mb)
+	keyboard := domain.Keyboard{{{Text: "🔁 Regenerate", Data: "chat:regen"}}}
+
+	mb.On("SendMessage", mock.Anything, mock.MatchedBy(func(params *bot.SendMessageParams) bool {
+		return params.ReplyMarkup == nil
+	})).Return(&models.Message{ID: 1}, nil).Once()
+	mb.On("SendMessage", mock.Anything, mock.MatchedBy(func(params *bot.SendMessageParams) bool {
+		markup, ok := params.ReplyMarkup.(*models.InlineKeyboardMarkup)
+		return ok && markup.InlineKeyboard[0][0].CallbackData == "chat:regen"
+	})).Return(&models.Message{ID: 2}, nil).Once()
+
+	id, err := sender.SendMessageReplyWithKeyboard(t.Context(), &domain.Message{ID: 42, ChatID: 1001},
+		string(b), keyboard)
+	require.NoError(t, err)
+	require.Equal(t, 2, id)
+	mb.AssertExpectations(t)
+}
+
+func TestTelegramSender_EditMessageKeyboard(t *testing.T) {
+	mb := new(MockBot)
+	sender := NewTelegram(mb)
+
+	mb.On("EditMessageReplyMarkup", mock.Anything, mock.MatchedBy(func(params *bot.EditMessageReplyMarkupParams) bool {
+		markup, ok := params.ReplyMarkup.(*models.InlineKeyboardMarkup)
+		return ok && len(markup.InlineKeyboard) == 0
+	})).Return(&models.Message{}, nil).Once()
+
+	require.NoError(t, sender.EditMessageKeyboard(t.Context(), 1, 2, nil))
+
+	mb.On("EditMessageReplyMarkup", mock.Anything, mock.Anything).Return(nil, errors.New("fail")).Once()
+
+	require.Error(t, sender.EditMessageKeyboard(t.Context(), 1, 2, domain.Keyboard{{{Text: "a", Data: "b"}}}))
+	mb.AssertExpectations(t)
+}
+
+func TestTelegramSender_AnswerCallbackQuery(t *testing.T) {
+	mb := new(MockBot)
+	sender := NewTelegram(mb)
+
+	mb.On("AnswerCallbackQuery", mock.Anything, mock.MatchedBy(func(params *bot.AnswerCallbackQueryParams) bool {
+		return params.CallbackQueryID == "query" && params.Text == "done"
+	})).Return(true, nil).Once()
+
+	require.NoError(t, sender.AnswerCallbackQuery(t.Context(), "query", "done"))
+
+	mb.On("AnswerCallbackQuery", mock.Anything, mock.Anything).Return(false, errors.New("fail")).Once()
+
+	require.Error(t, sender.AnswerCallbackQuery(t.Context(), "query", ""))
+	mb.AssertExpectations(t)
+}
+
+func TestTelegramSender_SendInvoice(t *testing.T) {
+	mb := new(MockBot)
+	sender := NewTelegram(mb)
+
+	mb.On("SendInvoice", mock.Anything, mock.MatchedBy(func(p *bot.SendInvoiceParams) bool {
+		return p.ChatID == int64(44) && p.Currency == "XTR" && p.Payload == "topup:7:100" &&
+			len(p.Prices) == 1 && p.Prices[0].Amount == 100 && p.ReplyParameters.MessageID == 33
+	})).Return(&models.Message{}, nil).Once()
+
+	invoice := domain.Invoice{Title: "Top-up", Description: "credit", Payload: "topup:7:100", Stars: 100}
+	require.NoError(t, sender.SendInvoice(t.Context(), &domain.Message{ID: 33, ChatID: 44}, invoice))
+
+	mb.On("SendInvoice", mock.Anything, mock.Anything).Return(nil, errors.New("fail")).Once()
+
+	require.Error(t, sender.SendInvoice(t.Context(), &domain.Message{ID: 33, ChatID: 44}, invoice))
+	mb.AssertExpectations(t)
+}
+
+func TestTelegramSender_AnswerPreCheckoutQuery(t *testing.T) {
+	mb := new(MockBot)
+	sender := NewTelegram(mb)
+
+	mb.On("AnswerPreCheckoutQuery", mock.Anything, mock.MatchedBy(func(p *bot.AnswerPreCheckoutQueryParams) bool {
+		return p.PreCheckoutQueryID == "query" && p.OK && p.ErrorMessage == ""
+	})).Return(true, nil).Once()
+	mb.On("AnswerPreCheckoutQuery", mock.Anything, mock.MatchedBy(func(p *bot.AnswerPreCheckoutQueryParams) bool {
+		return p.PreCheckoutQueryID == "query" && !p.OK && p.ErrorMessage == "declined"
+	})).Return(true, nil).Once()
+
+	require.NoError(t, sender.AnswerPreCheckoutQuery(t.Context(), "query", ""))
+	require.NoError(t, sender.AnswerPreCheckoutQuery(t.Context(), "query", "declined"))
+	mb.AssertExpectations(t)
+}
+
+func TestTelegramSender_EditMessageText(t *testing.T) {
+	tests := []struct {
+		name    string
+		retErr  error
+		wantErr bool
+	}{
+		{
+			name:    "success",
+			retErr:  nil,
+			wantErr: false,
+		},
+		{
+			name:    "edit fails",
+			retErr:  errors.New("fail"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mb := new(MockBot)
+			sender := NewTelegram(mb)
+
+			mb.On("EditMessageText", mock.Anything, mock.MatchedBy(func(params *bot.EditMessageTextParams) bool {
+				return params.ChatID == int64(20) && params.MessageID == 10 && params.Text == "edited"
+			})).Return(&models.Message{}, tc.retErr).Once()
+
+			err := sender.EditMessageText(t.Context(), 20, 10, "edited")
+
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			mb.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTelegramSender_EditMessageTextTruncates(t *testing.T) {
+	mb := new(MockBot)
+	sender := NewTelegram(mb)
+
+	text := "a" + strings.Repeat("ü", TelegramMessageLimit)
+	mb.On("EditMessageText", mock.Anything, mock.MatchedBy(func(params *bot.EditMessageTextParams) bool {
+		return len(params.Text) == TelegramMessageLimit-1 && utf8.ValidString(params.Text)
+	})).Return(&models.Message{}, nil).Once()
+
+	require.NoError(t, sender.EditMessageText(t.Context(), 20, 10, text))
+	mb.AssertExpectations(t)
+}
+
 func TestTelegramSender_SendImageURLReply(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -186,6 +409,85 @@ func TestTelegramSender_SendImageFileReply(t *testing.T) {
 	}
 }
 
+func TestTelegramSender_SendDocumentReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		retErr  error
+		wantErr bool
+	}{
+		{
+			name:    "success",
+			retErr:  nil,
+			wantErr: false,
+		},
+		{
+			name:    "fail send",
+			retErr:  errors.New("fail"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mb := new(MockBot)
+			sender := NewTelegram(mb)
+
+			msg := &domain.Message{ID: 33, ChatID: 44}
+			mb.On("SendDocument", mock.Anything, mock.MatchedBy(func(p *bot.SendDocumentParams) bool {
+				upload, ok := p.Document.(*models.InputFileUpload)
+				return ok && upload.Filename == "export.json" && p.ChatID == int64(44) &&
+					p.ReplyParameters.MessageID == 33
+			})).Return(&models.Message{}, tc.retErr).Once()
+
+			err := sender.SendDocumentReply(t.Context(), msg, "export.json", []byte("{}"))
+
+			if tc.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			mb.AssertExpectations(t)
+		})
+	}
+}
+
+func TestTelegramSender_SetCommands(t *testing.T) {
+	commands := []domain.CommandInfo{{Command: "/chat", Description: "chat"}, {Command: "/help", Description: "help"}}
+
+	t.Run("default scope", func(t *testing.T) {
+		mb := new(MockBot)
+		mb.On("SetMyCommands", mock.Anything, mock.MatchedBy(func(p *bot.SetMyCommandsParams) bool {
+			_, isDefault := p.Scope.(*models.BotCommandScopeDefault)
+			return isDefault && len(p.Commands) == 2 && p.Commands[0].Command == "chat" &&
+				p.Commands[1].Description == "help"
+		})).Return(true, nil).Once()
+
+		require.NoError(t, NewTelegram(mb).SetCommands(t.Context(), commands, 0))
+		mb.AssertExpectations(t)
+	})
+
+	t.Run("chat scope", func(t *testing.T) {
+		mb := new(MockBot)
+		mb.On("SetMyCommands", mock.Anything, mock.MatchedBy(func(p *bot.SetMyCommandsParams) bool {
+			scope, ok := p.Scope.(*models.BotCommandScopeChat)
+			return ok && scope.ChatID == int64(42)
+		})).Return(false, errors.New("fail")).Once()
+
+		require.Error(t, NewTelegram(mb).SetCommands(t.Context(), commands, 42))
+		mb.AssertExpectations(t)
+	})
+
+	t.Run("no commands reset the chat scope", func(t *testing.T) {
+		mb := new(MockBot)
+		mb.On("DeleteMyCommands", mock.Anything, mock.MatchedBy(func(p *bot.DeleteMyCommandsParams) bool {
+			scope, ok := p.Scope.(*models.BotCommandScopeChat)
+			return ok && scope.ChatID == int64(42)
+		})).Return(true, nil).Once()
+
+		require.NoError(t, NewTelegram(mb).SetCommands(t.Context(), nil, 42))
+		mb.AssertExpectations(t)
+	})
+}
+
 func TestTelegramSender_NotifyAndReturnError(t *testing.T) {
 	tests := []struct {
 		name            string
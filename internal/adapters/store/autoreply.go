@@ -0,0 +1,71 @@
+package store
+
+import (
+	"slices"
+	"sync"
+)
+
+// AutoReplyFile keeps the chats with disabled auto replies persisted as JSON file.
+type AutoReplyFile struct {
+	path     string
+	mu       sync.RWMutex
+	disabled []int64
+}
+
+// NewAutoReplyFile loads the chats with disabled auto replies from the file at path. Without existing file, the
+// chats start with the seed, usually the disabled chats of the config.
+func NewAutoReplyFile(path string, seed []int64) (*AutoReplyFile, error) {
+	s := &AutoReplyFile{path: path}
+
+	exists, err := readJSON(path, &s.disabled)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		s.disabled = slices.Clone(seed)
+	}
+
+	slices.Sort(s.disabled)
+	s.disabled = slices.Compact(s.disabled)
+
+	return s, nil
+}
+
+func (s *AutoReplyFile) IsAutoReplyDisabled(chatID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, found := slices.BinarySearch(s.disabled, chatID)
+	return found
+}
+
+func (s *AutoReplyFile) SetAutoReplyDisabled(chatID int64, disabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, found := slices.BinarySearch(s.disabled, chatID)
+	switch {
+	case found == disabled:
+		return nil
+	case disabled:
+		return s.save(slices.Insert(slices.Clone(s.disabled), i, chatID))
+	default:
+		return s.save(slices.Delete(slices.Clone(s.disabled), i, i+1))
+	}
+}
+
+// save writes the chats and keeps them only if that succeeded. The caller must hold the write lock.
+func (s *AutoReplyFile) save(disabled []int64) error {
+	if disabled == nil {
+		disabled = []int64{}
+	}
+
+	err := writeJSON(s.path, disabled)
+	if err != nil {
+		return err
+	}
+
+	s.disabled = disabled
+	return nil
+}
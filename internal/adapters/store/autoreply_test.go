@@ -0,0 +1,30 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoReplyFile_SetAutoReplyDisabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "autoreply.json")
+
+	s, err := NewAutoReplyFile(path, []int64{2})
+	require.NoError(t, err)
+	assert.True(t, s.IsAutoReplyDisabled(2))
+	assert.False(t, s.IsAutoReplyDisabled(1))
+
+	require.NoError(t, s.SetAutoReplyDisabled(1, true))
+	require.NoError(t, s.SetAutoReplyDisabled(1, true))
+	require.NoError(t, s.SetAutoReplyDisabled(2, false))
+	assert.True(t, s.IsAutoReplyDisabled(1))
+	assert.False(t, s.IsAutoReplyDisabled(2))
+
+	// the persisted chats replace the seed
+	reloaded, err := NewAutoReplyFile(path, []int64{2})
+	require.NoError(t, err)
+	assert.True(t, reloaded.IsAutoReplyDisabled(1))
+	assert.False(t, reloaded.IsAutoReplyDisabled(2))
+}
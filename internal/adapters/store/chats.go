@@ -0,0 +1,87 @@
+package store
+
+import (
+	"slices"
+	"sync"
+)
+
+// ChatFile is a chat allowlist persisted as JSON file.
+type ChatFile struct {
+	path  string
+	mu    sync.RWMutex
+	chats []int64
+}
+
+// NewChatFile loads the allowlist from the file at path. Without existing file, the allowlist starts with the seed,
+// usually the allowed chats of the config.
+func NewChatFile(path string, seed []int64) (*ChatFile, error) {
+	s := &ChatFile{path: path}
+
+	exists, err := readJSON(path, &s.chats)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		s.chats = slices.Clone(seed)
+	}
+
+	slices.Sort(s.chats)
+	s.chats = slices.Compact(s.chats)
+
+	return s, nil
+}
+
+func (s *ChatFile) IsChatAllowed(chatID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, found := slices.BinarySearch(s.chats, chatID)
+	return found
+}
+
+func (s *ChatFile) AllowedChats() []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Clone(s.chats)
+}
+
+func (s *ChatFile) AllowChat(chatID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, found := slices.BinarySearch(s.chats, chatID)
+	if found {
+		return false, nil
+	}
+
+	return true, s.save(slices.Insert(slices.Clone(s.chats), i, chatID))
+}
+
+func (s *ChatFile) DenyChat(chatID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, found := slices.BinarySearch(s.chats, chatID)
+	if !found {
+		return false, nil
+	}
+
+	return true, s.save(slices.Delete(slices.Clone(s.chats), i, i+1))
+}
+
+// save writes the chats and keeps them only if that succeeded. The caller must hold the write lock.
+func (s *ChatFile) save(chats []int64) error {
+	if chats == nil {
+		chats = []int64{}
+	}
+
+	err := writeJSON(s.path, chats)
+	if err != nil {
+		return err
+	}
+
+	s.chats = chats
+	return nil
+}
@@ -0,0 +1,74 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatFile_Seed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chats.json")
+
+	s, err := NewChatFile(path, []int64{3, 1, 3})
+	require.NoError(t, err)
+
+	assert.Equal(t, []int64{1, 3}, s.AllowedChats())
+	assert.True(t, s.IsChatAllowed(3))
+	assert.False(t, s.IsChatAllowed(2))
+
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist, "the seed alone is not persisted")
+}
+
+func TestChatFile_AllowAndDeny(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "chats.json")
+
+	s, err := NewChatFile(path, []int64{1})
+	require.NoError(t, err)
+
+	added, err := s.AllowChat(-100)
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	added, err = s.AllowChat(-100)
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	removed, err := s.DenyChat(1)
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	removed, err = s.DenyChat(1)
+	require.NoError(t, err)
+	assert.False(t, removed)
+
+	// the persisted chats replace the seed
+	reloaded, err := NewChatFile(path, []int64{1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-100}, reloaded.AllowedChats())
+}
+
+func TestChatFile_DenyAll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chats.json")
+
+	s, err := NewChatFile(path, []int64{1})
+	require.NoError(t, err)
+
+	_, err = s.DenyChat(1)
+	require.NoError(t, err)
+
+	reloaded, err := NewChatFile(path, []int64{1})
+	require.NoError(t, err)
+	assert.Empty(t, reloaded.AllowedChats())
+}
+
+func TestChatFile_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chats.json")
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0o600))
+
+	_, err := NewChatFile(path, nil)
+	require.Error(t, err)
+}
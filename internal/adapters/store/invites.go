@@ -0,0 +1,136 @@
+package store
+
+import (
+	"fmt"
+	"hsbot/internal/core/domain"
+	"slices"
+	"sync"
+)
+
+// InviteFile keeps invite codes and their redemptions persisted as JSON file.
+type InviteFile struct {
+	path    string
+	mu      sync.RWMutex
+	invites []domain.Invite
+}
+
+// NewInviteFile loads the invites from the file at path, starting without invites if it doesn't exist yet.
+func NewInviteFile(path string) (*InviteFile, error) {
+	s := &InviteFile{path: path}
+
+	_, err := readJSON(path, &s.invites)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *InviteFile) CreateInvite(invite domain.Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.find(invite.Code) >= 0 {
+		return fmt.Errorf("invite code %s already exists", invite.Code)
+	}
+
+	return s.save(append(slices.Clone(s.invites), invite))
+}
+
+func (s *InviteFile) RedeemInvite(code string, redemption domain.Redemption) (domain.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.find(code)
+	if i < 0 {
+		return domain.Invite{}, domain.ErrInvalidInvite
+	}
+
+	invite := s.invites[i]
+	if slices.ContainsFunc(invite.Redemptions, func(r domain.Redemption) bool {
+		return r.ChatID == redemption.ChatID && !r.Revoked
+	}) {
+		return invite, nil
+	}
+
+	if !invite.IsValid(redemption.RedeemedAt) {
+		return domain.Invite{}, domain.ErrInvalidInvite
+	}
+
+	invite.Redemptions = append(slices.Clone(invite.Redemptions), redemption)
+
+	invites := slices.Clone(s.invites)
+	invites[i] = invite
+
+	return invite, s.save(invites)
+}
+
+func (s *InviteFile) IsChatInvited(chatID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.Contains(s.invitedChats(), chatID)
+}
+
+func (s *InviteFile) InvitedChats() []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.invitedChats()
+}
+
+func (s *InviteFile) RevokeChat(chatID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	revoked := false
+	invites := slices.Clone(s.invites)
+
+	for i, invite := range invites {
+		redemptions := slices.Clone(invite.Redemptions)
+		for j, r := range redemptions {
+			if r.ChatID == chatID && !r.Revoked {
+				redemptions[j].Revoked = true
+				invites[i].Redemptions = redemptions
+				revoked = true
+			}
+		}
+	}
+
+	if !revoked {
+		return false, nil
+	}
+
+	return true, s.save(invites)
+}
+
+// invitedChats returns the chats of the redemptions that weren't revoked. The caller must hold the lock.
+func (s *InviteFile) invitedChats() []int64 {
+	var chats []int64
+	for _, invite := range s.invites {
+		for _, r := range invite.Redemptions {
+			if !r.Revoked {
+				chats = append(chats, r.ChatID)
+			}
+		}
+	}
+
+	slices.Sort(chats)
+	return slices.Compact(chats)
+}
+
+// find returns the index of the invite with the code, or -1. The caller must hold the lock.
+func (s *InviteFile) find(code string) int {
+	return slices.IndexFunc(s.invites, func(i domain.Invite) bool { return i.Code == code })
+}
+
+// save writes the invites and keeps them only if that succeeded. The caller must hold the write lock.
+func (s *InviteFile) save(invites []domain.Invite) error {
+	err := writeJSON(s.path, invites)
+	if err != nil {
+		return err
+	}
+
+	s.invites = invites
+	return nil
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"hsbot/internal/core/domain"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInviteFile_Redeem(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "invites.json")
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := NewInviteFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, s.CreateInvite(domain.Invite{Code: "abc", CreatedBy: 7, CreatedAt: now,
+		ExpiresAt: now.Add(time.Hour), Uses: 2}))
+	require.Error(t, s.CreateInvite(domain.Invite{Code: "abc"}), "codes are unique")
+
+	invite, err := s.RedeemInvite("abc", domain.Redemption{ChatID: -100, UserID: 5, RedeemedAt: now})
+	require.NoError(t, err)
+	assert.Equal(t, 1, invite.Remaining())
+
+	invite, err = s.RedeemInvite("abc", domain.Redemption{ChatID: -100, UserID: 6, RedeemedAt: now})
+	require.NoError(t, err)
+	assert.Equal(t, 1, invite.Remaining(), "redeeming again in the same chat doesn't use the code up")
+
+	_, err = s.RedeemInvite("abc", domain.Redemption{ChatID: 5, RedeemedAt: now.Add(2 * time.Hour)})
+	require.ErrorIs(t, err, domain.ErrInvalidInvite, "expired")
+
+	_, err = s.RedeemInvite("abc", domain.Redemption{ChatID: 5, RedeemedAt: now})
+	require.NoError(t, err)
+
+	_, err = s.RedeemInvite("abc", domain.Redemption{ChatID: 6, RedeemedAt: now})
+	require.ErrorIs(t, err, domain.ErrInvalidInvite, "used up")
+
+	_, err = s.RedeemInvite("xyz", domain.Redemption{ChatID: 6, RedeemedAt: now})
+	require.ErrorIs(t, err, domain.ErrInvalidInvite, "unknown")
+
+	assert.True(t, s.IsChatInvited(-100))
+	assert.False(t, s.IsChatInvited(6))
+	assert.Equal(t, []int64{-100, 5}, s.InvitedChats())
+
+	reloaded, err := NewInviteFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-100, 5}, reloaded.InvitedChats())
+}
+
+func TestInviteFile_RevokeChat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invites.json")
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := NewInviteFile(path)
+	require.NoError(t, err)
+
+	require.NoError(t, s.CreateInvite(domain.Invite{Code: "abc", ExpiresAt: now.Add(time.Hour), Uses: 5}))
+	_, err = s.RedeemInvite("abc", domain.Redemption{ChatID: -100, RedeemedAt: now})
+	require.NoError(t, err)
+
+	revoked, err := s.RevokeChat(-100)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = s.RevokeChat(-100)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.False(t, s.IsChatInvited(-100))
+
+	// the revoked redemption stays in the history and the chat can redeem a code again
+	invite, err := s.RedeemInvite("abc", domain.Redemption{ChatID: -100, RedeemedAt: now})
+	require.NoError(t, err)
+	assert.Len(t, invite.Redemptions, 2)
+	assert.True(t, s.IsChatInvited(-100))
+
+	reloaded, err := NewInviteFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{-100}, reloaded.InvitedChats())
+}
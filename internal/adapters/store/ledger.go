@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+	"hsbot/internal/core/domain"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LedgerFile is a spending ledger persisted as JSON Lines file, one entry per line. Entries are only appended, so
+// the file is never rewritten.
+type LedgerFile struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewLedgerFile(path string) (*LedgerFile, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory of %s: %w", path, err)
+	}
+
+	return &LedgerFile{path: path}, nil
+}
+
+func (l *LedgerFile) Record(entry domain.LedgerEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return appendJSONLine(l.path, entry)
+}
+
+func (l *LedgerFile) Entries(since time.Time) ([]domain.LedgerEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	all, err := readJSONLines[domain.LedgerEntry](l.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []domain.LedgerEntry
+	for _, entry := range all {
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"hsbot/internal/core/domain"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedgerFile_RecordAndEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "ledger.jsonl")
+
+	l, err := NewLedgerFile(path)
+	require.NoError(t, err)
+
+	entries, err := l.Entries(time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no ledger file yet")
+
+	start := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	first := domain.LedgerEntry{Time: start, ChatID: -100, UserID: 7, Username: "alice", Command: "/chat",
+		Model: "openai/gpt-4.1", Tokens: 1200, Cost: 0.012}
+	second := domain.LedgerEntry{Time: start.Add(time.Hour), ChatID: -100, UserID: 8, Command: "/image",
+		Model: "imagen3", Cost: 0.025}
+
+	require.NoError(t, l.Record(first))
+	require.NoError(t, l.Record(second))
+
+	reloaded, err := NewLedgerFile(path)
+	require.NoError(t, err)
+
+	entries, err = reloaded.Entries(time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []domain.LedgerEntry{first, second}, entries)
+
+	entries, err = reloaded.Entries(start.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []domain.LedgerEntry{second}, entries)
+}
+
+func TestLedgerFile_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ledger.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"cost\":1}\nnot json\n"), 0o600))
+
+	l, err := NewLedgerFile(path)
+	require.NoError(t, err)
+
+	_, err = l.Entries(time.Time{})
+	require.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"fmt"
+	"hsbot/internal/core/domain"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WalletFile keeps the prepaid balances of users as JSON Lines file of their credit transactions, which are only
+// appended. The balances are the sums of the transactions, replayed when the file is opened.
+type WalletFile struct {
+	path     string
+	balances map[int64]float64
+	charges  map[string]bool
+	mu       sync.Mutex
+	now      func() time.Time
+}
+
+func NewWalletFile(path string) (*WalletFile, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory of %s: %w", path, err)
+	}
+
+	transactions, err := readJSONLines[domain.CreditTransaction](path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &WalletFile{
+		path:     path,
+		balances: make(map[int64]float64),
+		charges:  make(map[string]bool),
+		now:      time.Now,
+	}
+
+	for _, transaction := range transactions {
+		w.apply(transaction)
+	}
+
+	return w, nil
+}
+
+func (w *WalletFile) Balance(userID int64) float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.balances[userID]
+}
+
+func (w *WalletFile) Deposit(transaction domain.CreditTransaction) (float64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if transaction.ChargeID != "" && w.charges[transaction.ChargeID] {
+		return w.balances[transaction.UserID], domain.ErrDuplicateCharge
+	}
+
+	transaction.Time = w.now()
+	err := w.record(transaction)
+	if err != nil {
+		return w.balances[transaction.UserID], err
+	}
+
+	return w.balances[transaction.UserID], nil
+}
+
+func (w *WalletFile) Withdraw(userID, chatID int64, amount float64) (float64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	amount = min(amount, w.balances[userID])
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	err := w.record(domain.CreditTransaction{Time: w.now(), UserID: userID, ChatID: chatID, Amount: -amount})
+	if err != nil {
+		return 0, err
+	}
+
+	return amount, nil
+}
+
+// record appends the transaction to the file and applies it to the balances. The caller must hold the lock.
+func (w *WalletFile) record(transaction domain.CreditTransaction) error {
+	err := appendJSONLine(w.path, transaction)
+	if err != nil {
+		return err
+	}
+
+	w.apply(transaction)
+
+	return nil
+}
+
+func (w *WalletFile) apply(transaction domain.CreditTransaction) {
+	w.balances[transaction.UserID] += transaction.Amount
+	if transaction.ChargeID != "" {
+		w.charges[transaction.ChargeID] = true
+	}
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"hsbot/internal/core/domain"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalletFile_DepositAndWithdraw(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "wallet.jsonl")
+
+	w, err := NewWalletFile(path)
+	require.NoError(t, err)
+	assert.Zero(t, w.Balance(7))
+
+	drawn, err := w.Withdraw(7, -100, 0.5)
+	require.NoError(t, err)
+	assert.Zero(t, drawn, "nothing to draw from an empty balance")
+
+	balance, err := w.Deposit(domain.CreditTransaction{UserID: 7, ChatID: -100, Amount: 1.3, Stars: 100,
+		ChargeID: "charge-1"})
+	require.NoError(t, err)
+	assert.InDelta(t, 1.3, balance, 1e-9)
+
+	balance, err = w.Deposit(domain.CreditTransaction{UserID: 7, ChatID: -100, Amount: 1.3, Stars: 100,
+		ChargeID: "charge-1"})
+	require.ErrorIs(t, err, domain.ErrDuplicateCharge)
+	assert.InDelta(t, 1.3, balance, 1e-9)
+
+	drawn, err = w.Withdraw(7, -100, 1)
+	require.NoError(t, err)
+	assert.InDelta(t, 1, drawn, 1e-9)
+
+	drawn, err = w.Withdraw(7, 1, 1)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.3, drawn, 1e-9, "at most the balance is drawn")
+
+	_, err = w.Deposit(domain.CreditTransaction{UserID: 8, Amount: 0.65, Stars: 50, ChargeID: "charge-2"})
+	require.NoError(t, err)
+
+	reloaded, err := NewWalletFile(path)
+	require.NoError(t, err)
+	assert.InDelta(t, 0, reloaded.Balance(7), 1e-9)
+	assert.InDelta(t, 0.65, reloaded.Balance(8), 1e-9)
+
+	_, err = reloaded.Deposit(domain.CreditTransaction{UserID: 8, Amount: 0.65, Stars: 50, ChargeID: "charge-2"})
+	require.ErrorIs(t, err, domain.ErrDuplicateCharge, "charges are remembered across restarts")
+
+	transactions, err := readJSONLines[domain.CreditTransaction](path)
+	require.NoError(t, err)
+	require.Len(t, transactions, 4, "every deposit and withdrawal is recorded")
+	assert.Equal(t, "charge-1", transactions[0].ChargeID)
+	assert.Equal(t, 100, transactions[0].Stars)
+	assert.InDelta(t, -1, transactions[1].Amount, 1e-9)
+	assert.Equal(t, int64(1), transactions[2].ChatID)
+	assert.False(t, transactions[0].Time.IsZero())
+}
+
+func TestWalletFile_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"userId\":7,\"amount\":1}\n{\n"), 0o600))
+
+	_, err := NewWalletFile(path)
+	require.ErrorContains(t, err, "line 2")
+}
@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type audioDurationKey struct{}
+
+// WithAudioDuration returns a copy of ctx carrying the known duration of the audio to process, e.g. of a voice message.
+func WithAudioDuration(ctx context.Context, duration time.Duration) context.Context {
+	return context.WithValue(ctx, audioDurationKey{}, duration)
+}
+
+// AudioDurationFromContext returns the audio duration carried by ctx, or zero if it is unknown.
+func AudioDurationFromContext(ctx context.Context) time.Duration {
+	duration, _ := ctx.Value(audioDurationKey{}).(time.Duration)
+	return duration
+}
@@ -0,0 +1,211 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const accessNamespace = "access"
+
+const (
+	callbackRequest = "request"
+	callbackApprove = "approve"
+	callbackDeny    = "deny"
+)
+
+// defaultAccessRequestCooldown is the time between access requests of a chat, if not configured.
+const defaultAccessRequestCooldown = time.Hour
+
+// AccessRequestKeyboard returns the button of unauthorized chats to request access from the admins.
+func AccessRequestKeyboard() domain.Keyboard {
+	return domain.Keyboard{{{Text: "🔑 Request access", Data: CallbackData(accessNamespace, callbackRequest)}}}
+}
+
+// AccessRequest handles the access request button of unauthorized chats. Requests are sent to the admins, who can
+// approve or deny them with buttons. Repeated requests of a chat are throttled.
+type AccessRequest struct {
+	chats      port.ChatStore
+	textSender port.TextSender
+	adminIDs   []int64
+	cooldown   time.Duration
+
+	mu        sync.Mutex
+	requested map[int64]time.Time
+	now       func() time.Time
+}
+
+func NewAccessRequest(chats port.ChatStore, sender port.TextSender) (*AccessRequest, error) {
+	var adminIDs []int64
+
+	err := viper.UnmarshalKey("telegram.admin_ids", &adminIDs)
+	if err != nil {
+		return nil, errors.New("failed to load admin user IDs")
+	}
+
+	cooldown := defaultAccessRequestCooldown
+	if viper.IsSet("telegram.access_request_cooldown") {
+		cooldown = viper.GetDuration("telegram.access_request_cooldown")
+	}
+
+	return &AccessRequest{
+		chats:      chats,
+		textSender: sender,
+		adminIDs:   adminIDs,
+		cooldown:   cooldown,
+		requested:  make(map[int64]time.Time),
+		now:        time.Now,
+	}, nil
+}
+
+func (a *AccessRequest) GetCallbackNamespace() string {
+	return accessNamespace
+}
+
+// IsPublic lets unauthorized chats request access. Approvals are checked against the admins.
+func (a *AccessRequest) IsPublic() bool {
+	return true
+}
+
+func (a *AccessRequest) HandleCallback(ctx context.Context, timeout time.Duration, callback *domain.Callback) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	action, payload, _ := strings.Cut(callback.Data, ":")
+
+	switch action {
+	case callbackRequest:
+		return a.request(ctx, callback)
+	case callbackApprove, callbackDeny:
+		chatID, err := strconv.ParseInt(payload, 10, 64)
+		if err != nil {
+			return a.textSender.AnswerCallbackQuery(ctx, callback.ID, "invalid chat")
+		}
+
+		return a.decide(ctx, callback, chatID, action == callbackApprove)
+	default:
+		return a.textSender.AnswerCallbackQuery(ctx, callback.ID, "unknown action")
+	}
+}
+
+// request sends the access request of the callback's chat to the admins.
+func (a *AccessRequest) request(ctx context.Context, callback *domain.Callback) error {
+	if a.chats.IsChatAllowed(callback.ChatID) {
+		return a.textSender.AnswerCallbackQuery(ctx, callback.ID, "this chat is already allowed")
+	}
+
+	if wait := a.throttle(callback.ChatID); wait > 0 {
+		return a.textSender.AnswerCallbackQuery(ctx, callback.ID,
+			fmt.Sprintf("access was already requested, try again in %s", wait))
+	}
+
+	title := callback.Message.ChatTitle
+	if title == "" {
+		title = "private chat"
+	}
+
+	text := fmt.Sprintf("Access request for %q (%d) by %s (%d)",
+		title, callback.ChatID, callback.Username, callback.UserID)
+	chatID := strconv.FormatInt(callback.ChatID, 10)
+	keyboard := domain.Keyboard{{
+		{Text: "✅ Approve", Data: CallbackData(accessNamespace, callbackApprove, chatID)},
+		{Text: "❌ Deny", Data: CallbackData(accessNamespace, callbackDeny, chatID)},
+	}}
+
+	sent := 0
+	for _, adminID := range a.adminIDs {
+		_, err := a.textSender.SendMessageReplyWithKeyboard(ctx, &domain.Message{ChatID: adminID}, text, keyboard)
+		if err != nil {
+			log.Warn().Err(err).Int64("adminId", adminID).Msg("failed to send access request")
+			continue
+		}
+		sent++
+	}
+
+	if sent == 0 {
+		a.forget(callback.ChatID)
+		return a.textSender.AnswerCallbackQuery(ctx, callback.ID, "failed to reach an admin, try again later")
+	}
+
+	log.Info().Int64("chatId", callback.ChatID).Int64("userId", callback.UserID).Msg("access requested")
+
+	err := a.textSender.EditMessageKeyboard(ctx, callback.ChatID, callback.Message.ID, nil)
+	if err != nil {
+		log.Warn().Err(err).Int64("chatId", callback.ChatID).Msg("failed to remove access request button")
+	}
+
+	return a.textSender.AnswerCallbackQuery(ctx, callback.ID, "access requested")
+}
+
+// decide approves or denies the access request of a chat and notifies the requesting chat.
+func (a *AccessRequest) decide(ctx context.Context, callback *domain.Callback, chatID int64, approve bool) error {
+	if !slices.Contains(a.adminIDs, callback.UserID) {
+		return a.textSender.AnswerCallbackQuery(ctx, callback.ID, "only admins can decide access requests")
+	}
+
+	decision, notification := "denied", "Your access request was denied."
+	if approve {
+		_, err := a.chats.AllowChat(chatID)
+		if err != nil {
+			log.Err(err).Int64("chatId", chatID).Msg("failed to allow chat")
+			return a.textSender.AnswerCallbackQuery(ctx, callback.ID, "failed to allow chat")
+		}
+
+		decision, notification = "approved", "Your access request was approved, see /help to get started."
+	}
+
+	log.Info().Int64("chatId", chatID).Int64("adminId", callback.UserID).Str("decision", decision).
+		Msg("decided access request")
+
+	err := a.textSender.EditMessageText(ctx, callback.ChatID, callback.Message.ID,
+		fmt.Sprintf("%s\n\n%s by %s", callback.Message.Text, decision, callback.Username))
+	if err != nil {
+		log.Warn().Err(err).Int64("chatId", chatID).Msg("failed to update access request")
+	}
+
+	_, err = a.textSender.SendMessageReply(ctx, &domain.Message{ChatID: chatID}, notification)
+	if err != nil {
+		log.Warn().Err(err).Int64("chatId", chatID).Msg("failed to notify requester")
+	}
+
+	return a.textSender.AnswerCallbackQuery(ctx, callback.ID, decision)
+}
+
+// throttle records an access request of the chat, returning the remaining cooldown if it requested recently.
+func (a *AccessRequest) throttle(chatID int64) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	if last, ok := a.requested[chatID]; ok && now.Sub(last) < a.cooldown {
+		return (last.Add(a.cooldown).Sub(now) + time.Second - 1).Truncate(time.Second)
+	}
+
+	for id, last := range a.requested {
+		if now.Sub(last) >= a.cooldown {
+			delete(a.requested, id)
+		}
+	}
+
+	a.requested[chatID] = now
+
+	return 0
+}
+
+// forget drops the recorded access request of the chat, so it can request again.
+func (a *AccessRequest) forget(chatID int64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.requested, chatID)
+}
@@ -0,0 +1,165 @@
+package command
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMessage struct {
+	chatID   int64
+	text     string
+	keyboard domain.Keyboard
+}
+
+// chatRecordingSender records the messages sent to each chat.
+type chatRecordingSender struct {
+	MockTextSender
+	sent []sentMessage
+}
+
+func (s *chatRecordingSender) SendMessageReply(ctx context.Context, message *domain.Message,
+	text string) (int, error) {
+	return s.SendMessageReplyWithKeyboard(ctx, message, text, nil)
+}
+
+func (s *chatRecordingSender) SendMessageReplyWithKeyboard(_ context.Context, message *domain.Message, text string,
+	keyboard domain.Keyboard) (int, error) {
+	s.sent = append(s.sent, sentMessage{chatID: message.ChatID, text: text, keyboard: keyboard})
+	return len(s.sent), nil
+}
+
+func newTestAccessRequest(t *testing.T, store *mockChatStore, ts *chatRecordingSender) *AccessRequest {
+	t.Helper()
+
+	viper.Set("telegram.admin_ids", []int64{7})
+	viper.Set("telegram.access_request_cooldown", "1h")
+	t.Cleanup(func() { viper.Set("telegram", nil) })
+
+	a, err := NewAccessRequest(store, ts)
+	require.NoError(t, err)
+
+	return a
+}
+
+func newAccessCallback(chatID, userID int64, data string) *domain.Callback {
+	return &domain.Callback{
+		ID:       "cb",
+		ChatID:   chatID,
+		UserID:   userID,
+		Username: "@bob",
+		Data:     data,
+		Message:  &domain.Message{ID: 3, ChatID: chatID, ChatTitle: "friends", Text: "request text"},
+	}
+}
+
+func TestAccessRequest_Request(t *testing.T) {
+	store := &mockChatStore{}
+	ts := &chatRecordingSender{}
+	a := newTestAccessRequest(t, store, ts)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	err := a.HandleCallback(t.Context(), time.Minute, newAccessCallback(-100, 5, "request"))
+	require.NoError(t, err)
+
+	require.Len(t, ts.sent, 1)
+	assert.Equal(t, int64(7), ts.sent[0].chatID)
+	assert.Equal(t, `Access request for "friends" (-100) by @bob (5)`, ts.sent[0].text)
+	assert.Equal(t, domain.Keyboard{{
+		{Text: "✅ Approve", Data: "access:approve:-100"},
+		{Text: "❌ Deny", Data: "access:deny:-100"},
+	}}, ts.sent[0].keyboard)
+	assert.Equal(t, "access requested", ts.CallbackAnswer)
+
+	now = now.Add(15 * time.Minute)
+	err = a.HandleCallback(t.Context(), time.Minute, newAccessCallback(-100, 6, "request"))
+	require.NoError(t, err)
+
+	assert.Len(t, ts.sent, 1, "repeated requests are throttled")
+	assert.Equal(t, "access was already requested, try again in 45m0s", ts.CallbackAnswer)
+
+	now = now.Add(time.Hour)
+	err = a.HandleCallback(t.Context(), time.Minute, newAccessCallback(-100, 6, "request"))
+	require.NoError(t, err)
+
+	assert.Len(t, ts.sent, 2)
+}
+
+func TestAccessRequest_RequestAllowedChat(t *testing.T) {
+	ts := &chatRecordingSender{}
+	a := newTestAccessRequest(t, &mockChatStore{chats: []int64{-100}}, ts)
+
+	err := a.HandleCallback(t.Context(), time.Minute, newAccessCallback(-100, 5, "request"))
+	require.NoError(t, err)
+
+	assert.Empty(t, ts.sent)
+	assert.Equal(t, "this chat is already allowed", ts.CallbackAnswer)
+}
+
+func TestAccessRequest_Decide(t *testing.T) {
+	tests := []struct {
+		name       string
+		userID     int64
+		data       string
+		wantChats  []int64
+		wantAnswer string
+		wantNotice string
+	}{
+		{
+			name:       "approve",
+			userID:     7,
+			data:       "approve:-100",
+			wantChats:  []int64{-100},
+			wantAnswer: "approved",
+			wantNotice: "Your access request was approved, see /help to get started.",
+		},
+		{
+			name:       "deny",
+			userID:     7,
+			data:       "deny:-100",
+			wantAnswer: "denied",
+			wantNotice: "Your access request was denied.",
+		},
+		{
+			name:       "not an admin",
+			userID:     5,
+			data:       "approve:-100",
+			wantAnswer: "only admins can decide access requests",
+		},
+		{
+			name:       "invalid chat",
+			userID:     7,
+			data:       "approve:abc",
+			wantAnswer: "invalid chat",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockChatStore{}
+			ts := &chatRecordingSender{}
+			a := newTestAccessRequest(t, store, ts)
+
+			err := a.HandleCallback(t.Context(), time.Minute, newAccessCallback(7, tc.userID, tc.data))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantChats, store.chats)
+			assert.Equal(t, tc.wantAnswer, ts.CallbackAnswer)
+
+			if tc.wantNotice == "" {
+				assert.Empty(t, ts.sent)
+				return
+			}
+
+			require.Len(t, ts.sent, 1)
+			assert.Equal(t, sentMessage{chatID: -100, text: tc.wantNotice}, ts.sent[0])
+			assert.Equal(t, "request text\n\n"+tc.wantAnswer+" by @bob", ts.Message)
+		})
+	}
+}
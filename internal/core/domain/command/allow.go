@@ -0,0 +1,70 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var chatIDArgs = ArgSpec{
+	Args: []Arg{{Name: "chatID", Type: ArgInt, Help: "telegram chat ID", Required: true}},
+}
+
+// Allow adds a chat to the allowlist. Register it with the AdminOnly middleware.
+type Allow struct {
+	chats      port.ChatStore
+	textSender port.TextSender
+	command    string
+}
+
+func NewAllow(chats port.ChatStore, sender port.TextSender, command string) *Allow {
+	return &Allow{chats: chats, textSender: sender, command: command}
+}
+
+func (a *Allow) IsAdminOnly() bool {
+	return true
+}
+
+func (a *Allow) GetCommand() string {
+	return a.command
+}
+
+func (a *Allow) GetDescription() string {
+	return "Allow a chat to use the bot (admins only)"
+}
+
+func (a *Allow) GetUsage() string {
+	return chatIDArgs.Usage(a.command)
+}
+
+func (a *Allow) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	args, err := chatIDArgs.Parse(a.command, message.Text)
+	if err != nil {
+		_ = a.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	chatID := int64(args.Int("chatID"))
+
+	added, err := a.chats.AllowChat(chatID)
+	if err != nil {
+		return a.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to allow chat: %w", err), message)
+	}
+
+	reply := fmt.Sprintf("chat %d is already allowed", chatID)
+	if added {
+		zerolog.Ctx(ctx).Info().Int64("allowedChatId", chatID).Msg("allowed chat")
+		reply = fmt.Sprintf("chat %d is now allowed", chatID)
+	}
+
+	_, err = a.textSender.SendMessageReply(ctx, message, reply)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
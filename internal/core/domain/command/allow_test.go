@@ -0,0 +1,125 @@
+package command
+
+import (
+	"errors"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockChatStore struct {
+	chats []int64
+	err   error
+}
+
+func (m *mockChatStore) IsChatAllowed(chatID int64) bool {
+	return slices.Contains(m.chats, chatID)
+}
+
+func (m *mockChatStore) AllowedChats() []int64 {
+	return m.chats
+}
+
+func (m *mockChatStore) AllowChat(chatID int64) (bool, error) {
+	if m.err != nil || m.IsChatAllowed(chatID) {
+		return false, m.err
+	}
+
+	m.chats = append(m.chats, chatID)
+	return true, nil
+}
+
+func (m *mockChatStore) DenyChat(chatID int64) (bool, error) {
+	if m.err != nil || !m.IsChatAllowed(chatID) {
+		return false, m.err
+	}
+
+	m.chats = slices.DeleteFunc(m.chats, func(id int64) bool { return id == chatID })
+	return true, nil
+}
+
+func TestAllowAndDeny(t *testing.T) {
+	store := &mockChatStore{chats: []int64{1}}
+	invites := &mockInviteStore{invited: []int64{-200}}
+	ts := &MockTextSender{}
+	allow := NewAllow(store, ts, "/allow")
+	deny := NewDeny(store, invites, ts, "/deny")
+
+	tests := []struct {
+		name      string
+		respond   port.RespondFunc
+		text      string
+		want      string
+		wantChats []int64
+	}{
+		{name: "allow", respond: allow.Respond, text: "/allow -100",
+			want: "chat -100 is now allowed", wantChats: []int64{1, -100}},
+		{name: "allow again", respond: allow.Respond, text: "/allow -100",
+			want: "chat -100 is already allowed", wantChats: []int64{1, -100}},
+		{name: "deny", respond: deny.Respond, text: "/deny 1",
+			want: "chat 1 is no longer allowed", wantChats: []int64{-100}},
+		{name: "deny again", respond: deny.Respond, text: "/deny 1",
+			want: "chat 1 is not allowed", wantChats: []int64{-100}},
+		{name: "deny invited", respond: deny.Respond, text: "/deny -200",
+			want: "chat -200 is no longer allowed", wantChats: []int64{-100}},
+		{name: "missing chat ID", respond: allow.Respond, text: "/allow",
+			want: "missing chatID\n" + allow.GetUsage(), wantChats: []int64{-100}},
+		{name: "invalid chat ID", respond: deny.Respond, text: "/deny abc",
+			want: "invalid chatID: \"abc\" is not a whole number\n" + deny.GetUsage(), wantChats: []int64{-100}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.respond(t.Context(), time.Minute, &domain.Message{Text: tc.text})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, ts.Message)
+			assert.Equal(t, tc.wantChats, store.chats)
+		})
+	}
+
+	assert.Empty(t, invites.invited)
+}
+
+func TestAllowStoreError(t *testing.T) {
+	ts := &MockTextSender{}
+	allow := NewAllow(&mockChatStore{err: errors.New("disk full")}, ts, "/allow")
+
+	err := allow.Respond(t.Context(), time.Minute, &domain.Message{Text: "/allow 5"})
+	require.Error(t, err)
+
+	assert.Equal(t, "failed to allow chat: disk full", ts.Message)
+}
+
+func TestChats(t *testing.T) {
+	tests := []struct {
+		name    string
+		chats   []int64
+		invited []int64
+		want    string
+	}{
+		{name: "no chats", want: "no chats are allowed"},
+		{name: "one chat", chats: []int64{5}, want: "1 allowed chat:\n5"},
+		{name: "several chats", chats: []int64{-100, 5}, want: "2 allowed chats:\n-100\n5"},
+		{name: "invited chats", chats: []int64{5}, invited: []int64{-200},
+			want: "1 allowed chat:\n5\n\n1 invited chat:\n-200"},
+		{name: "only invited chats", invited: []int64{-200, -300}, want: "2 invited chats:\n-200\n-300"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := &MockTextSender{}
+			chats := NewChats(&mockChatStore{chats: tc.chats}, &mockInviteStore{invited: tc.invited}, ts, "/chats")
+
+			err := chats.Respond(t.Context(), time.Minute, &domain.Message{Text: "/chats"})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, ts.Message)
+		})
+	}
+}
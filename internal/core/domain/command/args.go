@@ -0,0 +1,350 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ArgType is the value type of a command argument or flag.
+type ArgType int
+
+const (
+	ArgString ArgType = iota
+	ArgInt
+	ArgFloat
+	ArgBool
+)
+
+// Arg declares a positional command argument.
+type Arg struct {
+	Name string
+	Type ArgType
+	Help string
+	// Default is used when the argument is omitted. Arguments without default are optional, unless Required.
+	Default  string
+	Required bool
+	// Min and Max bound numeric values, if Min < Max.
+	Min, Max float64
+	// Choices restricts string values to the listed ones, compared case-insensitively.
+	Choices []string
+	// Rest makes the argument consume the remaining text unchanged. It must be the last argument.
+	Rest bool
+}
+
+// Flag declares a --name or --name=value command flag. Boolean flags don't take a separate value.
+type Flag struct {
+	Name    string
+	Type    ArgType
+	Help    string
+	Default string
+	// Min and Max bound numeric values, if Min < Max.
+	Min, Max float64
+}
+
+// ArgSpec declares the arguments and flags of a command.
+type ArgSpec struct {
+	Args  []Arg
+	Flags []Flag
+}
+
+// UsageError is returned for invalid command arguments and includes the usage of the command.
+type UsageError struct {
+	Err   error
+	Usage string
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error() + "\n" + e.Usage
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// Args holds parsed and validated argument and flag values.
+type Args struct {
+	values map[string]any
+}
+
+// Has reports whether a value was given or defaulted for the name.
+func (a Args) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+func (a Args) String(name string) string {
+	v, _ := a.values[name].(string)
+	return v
+}
+
+func (a Args) Int(name string) int {
+	v, _ := a.values[name].(int)
+	return v
+}
+
+func (a Args) Float(name string) float64 {
+	v, _ := a.values[name].(float64)
+	return v
+}
+
+func (a Args) Bool(name string) bool {
+	v, _ := a.values[name].(bool)
+	return v
+}
+
+// token is a whitespace separated word of the arguments, with its offset in the text.
+type token struct {
+	text  string
+	start int
+}
+
+// Parse parses the arguments of a command message text. Errors are *UsageError with the usage of the given command.
+func (s ArgSpec) Parse(command, text string) (Args, error) {
+	args, err := s.parse(ParseCommandArgs(text))
+	if err != nil {
+		return Args{}, &UsageError{Err: err, Usage: s.Usage(command)}
+	}
+
+	return args, nil
+}
+
+func (s ArgSpec) parse(text string) (Args, error) {
+	args := Args{values: map[string]any{}}
+	tokens := tokenize(text)
+	position := 0
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if strings.HasPrefix(tok.text, "--") && len(tok.text) > 2 {
+			name, value, hasValue := strings.Cut(tok.text[2:], "=")
+			flag, ok := s.findFlag(name)
+			if !ok {
+				return Args{}, fmt.Errorf("unknown flag --%s", name)
+			}
+
+			if flag.Type == ArgBool && !hasValue {
+				value = "true"
+			} else if !hasValue {
+				if i+1 >= len(tokens) {
+					return Args{}, fmt.Errorf("missing value for --%s", name)
+				}
+				i++
+				value = tokens[i].text
+			}
+
+			parsed, err := parseValue(value, flag.Type, flag.Min, flag.Max, nil)
+			if err != nil {
+				return Args{}, fmt.Errorf("invalid --%s: %w", name, err)
+			}
+			args.values[flag.Name] = parsed
+			continue
+		}
+
+		if position >= len(s.Args) {
+			return Args{}, fmt.Errorf("unexpected argument %q", tok.text)
+		}
+
+		arg := s.Args[position]
+		position++
+
+		value := tok.text
+		if arg.Rest {
+			value = strings.TrimRightFunc(text[tok.start:], unicode.IsSpace)
+			i = len(tokens)
+		}
+
+		parsed, err := parseValue(value, arg.Type, arg.Min, arg.Max, arg.Choices)
+		if err != nil {
+			return Args{}, fmt.Errorf("invalid %s: %w", arg.Name, err)
+		}
+		args.values[arg.Name] = parsed
+	}
+
+	for _, arg := range s.Args[position:] {
+		if arg.Required {
+			return Args{}, fmt.Errorf("missing %s", arg.Name)
+		}
+
+		if err := args.setDefault(arg.Name, arg.Default, arg.Type); err != nil {
+			return Args{}, err
+		}
+	}
+
+	for _, flag := range s.Flags {
+		if args.Has(flag.Name) {
+			continue
+		}
+
+		if err := args.setDefault(flag.Name, flag.Default, flag.Type); err != nil {
+			return Args{}, err
+		}
+	}
+
+	return args, nil
+}
+
+func (a Args) setDefault(name, value string, argType ArgType) error {
+	if value == "" {
+		return nil
+	}
+
+	parsed, err := parseValue(value, argType, 0, 0, nil)
+	if err != nil {
+		return fmt.Errorf("invalid default of %s: %w", name, err)
+	}
+	a.values[name] = parsed
+
+	return nil
+}
+
+func (s ArgSpec) findFlag(name string) (Flag, bool) {
+	for _, flag := range s.Flags {
+		if strings.EqualFold(flag.Name, name) {
+			return flag, true
+		}
+	}
+
+	return Flag{}, false
+}
+
+// Usage returns the usage of a command with the spec's arguments and flags.
+func (s ArgSpec) Usage(command string) string {
+	var sb strings.Builder
+
+	sb.WriteString("usage: " + command)
+	for _, arg := range s.Args {
+		name := arg.Name
+		if arg.Rest {
+			name += "..."
+		}
+
+		if arg.Required {
+			sb.WriteString(" <" + name + ">")
+		} else {
+			sb.WriteString(" [" + name + "]")
+		}
+	}
+
+	for _, flag := range s.Flags {
+		if flag.Type == ArgBool {
+			sb.WriteString(" [--" + flag.Name + "]")
+		} else {
+			sb.WriteString(" [--" + flag.Name + " <" + typeName(flag.Type) + ">]")
+		}
+	}
+
+	for _, arg := range s.Args {
+		writeDescription(&sb, arg.Name, describe(arg.Help, arg.Min, arg.Max, arg.Choices, arg.Default))
+	}
+
+	for _, flag := range s.Flags {
+		writeDescription(&sb, "--"+flag.Name, describe(flag.Help, flag.Min, flag.Max, nil, flag.Default))
+	}
+
+	return sb.String()
+}
+
+func writeDescription(sb *strings.Builder, name, description string) {
+	if description != "" {
+		sb.WriteString("\n  " + name + ": " + description)
+	}
+}
+
+func describe(help string, minimum, maximum float64, choices []string, def string) string {
+	var parts []string
+	if help != "" {
+		parts = append(parts, help)
+	}
+
+	if minimum < maximum {
+		parts = append(parts, fmt.Sprintf("%g-%g", minimum, maximum))
+	}
+
+	if len(choices) > 0 {
+		parts = append(parts, strings.Join(choices, "|"))
+	}
+
+	if def != "" {
+		parts = append(parts, "default "+def)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func typeName(argType ArgType) string {
+	switch argType {
+	case ArgInt:
+		return "int"
+	case ArgFloat:
+		return "number"
+	case ArgBool:
+		return "bool"
+	default:
+		return "text"
+	}
+}
+
+func parseValue(value string, argType ArgType, minimum, maximum float64, choices []string) (any, error) {
+	switch argType {
+	case ArgInt:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a whole number", value)
+		}
+		return n, checkRange(float64(n), minimum, maximum)
+	case ArgFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return f, checkRange(f, minimum, maximum)
+	case ArgBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return b, nil
+	default:
+		if len(choices) > 0 {
+			idx := slices.IndexFunc(choices, func(choice string) bool { return strings.EqualFold(choice, value) })
+			if idx == -1 {
+				return nil, fmt.Errorf("%q is not one of %s", value, strings.Join(choices, ", "))
+			}
+			return choices[idx], nil
+		}
+		return value, nil
+	}
+}
+
+func checkRange(value, minimum, maximum float64) error {
+	if minimum < maximum && (value < minimum || value > maximum) {
+		return fmt.Errorf("%g is not within %g-%g", value, minimum, maximum)
+	}
+
+	return nil
+}
+
+func tokenize(text string) []token {
+	var tokens []token
+
+	start := -1
+	for i, r := range text {
+		switch {
+		case unicode.IsSpace(r) && start >= 0:
+			tokens = append(tokens, token{text: text[start:i], start: start})
+			start = -1
+		case !unicode.IsSpace(r) && start < 0:
+			start = i
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, token{text: text[start:], start: start})
+	}
+
+	return tokens
+}
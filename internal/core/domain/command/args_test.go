@@ -0,0 +1,133 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testArgs = ArgSpec{
+	Args: []Arg{
+		{Name: "count", Type: ArgInt, Help: "how many", Default: "2", Min: 1, Max: 10},
+		{Name: "mode", Help: "output mode", Choices: []string{"fast", "slow"}},
+		{Name: "prompt", Help: "the prompt", Rest: true},
+	},
+	Flags: []Flag{
+		{Name: "scale", Type: ArgFloat, Help: "scale factor", Default: "1.5", Min: 0.5, Max: 4},
+		{Name: "raw", Type: ArgBool, Help: "raw output"},
+	},
+}
+
+func TestArgSpec_Parse(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantCount int
+		wantMode  string
+		wantText  string
+		wantScale float64
+		wantRaw   bool
+		wantErr   string
+	}{
+		{
+			name:      "defaults",
+			text:      "/test",
+			wantCount: 2,
+			wantScale: 1.5,
+		},
+		{
+			name:      "positional and rest keeps text unchanged",
+			text:      "/test 3 FAST draw  a cat\n--raw ",
+			wantCount: 3,
+			wantMode:  "fast",
+			wantText:  "draw  a cat\n--raw",
+			wantScale: 1.5,
+		},
+		{
+			name:      "flags before and between arguments",
+			text:      "/test --scale 2 5 --raw=true slow --scale=3",
+			wantCount: 5,
+			wantMode:  "slow",
+			wantScale: 3,
+			wantRaw:   true,
+		},
+		{
+			name:      "boolean flag without value",
+			text:      "/test --raw",
+			wantCount: 2,
+			wantScale: 1.5,
+			wantRaw:   true,
+		},
+		{
+			name:    "not a number",
+			text:    "/test foo",
+			wantErr: `invalid count: "foo" is not a whole number`,
+		},
+		{
+			name:    "out of range",
+			text:    "/test 11",
+			wantErr: "invalid count: 11 is not within 1-10",
+		},
+		{
+			name:    "invalid choice",
+			text:    "/test 1 medium",
+			wantErr: `invalid mode: "medium" is not one of fast, slow`,
+		},
+		{
+			name:    "unknown flag",
+			text:    "/test --foo",
+			wantErr: "unknown flag --foo",
+		},
+		{
+			name:    "missing flag value",
+			text:    "/test --scale",
+			wantErr: "missing value for --scale",
+		},
+		{
+			name:    "flag out of range",
+			text:    "/test --scale 5",
+			wantErr: "invalid --scale: 5 is not within 0.5-4",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args, err := testArgs.Parse("/test", tc.text)
+			if tc.wantErr != "" {
+				var usageErr *UsageError
+				require.ErrorAs(t, err, &usageErr)
+				assert.EqualError(t, usageErr.Err, tc.wantErr)
+				assert.Equal(t, testArgs.Usage("/test"), usageErr.Usage)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCount, args.Int("count"))
+			assert.Equal(t, tc.wantMode, args.String("mode"))
+			assert.Equal(t, tc.wantText, args.String("prompt"))
+			assert.InDelta(t, tc.wantScale, args.Float("scale"), 0.0001)
+			assert.Equal(t, tc.wantRaw, args.Bool("raw"))
+		})
+	}
+}
+
+func TestArgSpec_ParseRequired(t *testing.T) {
+	spec := ArgSpec{Args: []Arg{{Name: "prompt", Required: true, Rest: true}}}
+
+	_, err := spec.Parse("/image", "/image")
+	require.EqualError(t, err, "missing prompt\nusage: /image <prompt...>")
+
+	args, err := spec.Parse("/image", "/image one two")
+	require.NoError(t, err)
+	assert.Equal(t, "one two", args.String("prompt"))
+}
+
+func TestArgSpec_Usage(t *testing.T) {
+	assert.Equal(t, `usage: /test [count] [mode] [prompt...] [--scale <number>] [--raw]
+  count: how many, 1-10, default 2
+  mode: output mode, fast|slow
+  prompt: the prompt
+  --scale: scale factor, 0.5-4, default 1.5
+  --raw: raw output`, testArgs.Usage("/test"))
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"slices"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// AutoTranscribe is a listener replying to voice messages with their transcript in opted-in chats.
+type AutoTranscribe struct {
+	transcriber port.Transcriber
+	textSender  port.TextSender
+	chatIDs     []int64
+	minDuration time.Duration
+	maxDuration time.Duration
+}
+
+func NewAutoTranscribe(transcriber port.Transcriber, textSender port.TextSender) (*AutoTranscribe, error) {
+	var chatIDs []int64
+
+	err := viper.UnmarshalKey("transcribe.auto_chat_ids", &chatIDs)
+	if err != nil {
+		return nil, errors.New("failed to load auto transcription chat IDs")
+	}
+
+	return &AutoTranscribe{
+		transcriber: transcriber,
+		textSender:  textSender,
+		chatIDs:     chatIDs,
+		minDuration: viper.GetDuration("transcribe.auto_min_duration"),
+		maxDuration: viper.GetDuration("transcribe.auto_max_duration"),
+	}, nil
+}
+
+func (a *AutoTranscribe) ReadsAudio() bool {
+	return true
+}
+
+func (a *AutoTranscribe) Matches(message *domain.Message) bool {
+	if message.VoiceDuration == 0 || !slices.Contains(a.chatIDs, message.ChatID) {
+		return false
+	}
+
+	if message.VoiceDuration < a.minDuration {
+		return false
+	}
+
+	return a.maxDuration == 0 || message.VoiceDuration <= a.maxDuration
+}
+
+func (a *AutoTranscribe) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	if message.AudioURL == "" {
+		return errors.New("missing audio for voice message")
+	}
+
+	resp, err := a.transcriber.GenerateFromAudio(withTranscriptionContext(ctx, a.textSender, message), message.AudioURL)
+	if err != nil {
+		return a.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to generate transcript: %w", err), message)
+	}
+
+	_, err = a.textSender.SendMessageReply(ctx, message, resp)
+	if err != nil {
+		err = fmt.Errorf("error sending transcript: %w", err)
+		return a.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	return nil
+}
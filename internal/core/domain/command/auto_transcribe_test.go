@@ -0,0 +1,98 @@
+package command
+
+import (
+	"errors"
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAutoTranscribe(t *testing.T, mt *MockTranscriber, ts *MockTextSender) *AutoTranscribe {
+	t.Helper()
+
+	viper.Set("transcribe.auto_chat_ids", []int64{1})
+	viper.Set("transcribe.auto_min_duration", "2s")
+	viper.Set("transcribe.auto_max_duration", "1m")
+
+	a, err := NewAutoTranscribe(mt, ts)
+	require.NoError(t, err)
+
+	return a
+}
+
+func TestAutoTranscribe_Matches(t *testing.T) {
+	a := newTestAutoTranscribe(t, &MockTranscriber{}, &MockTextSender{})
+
+	tests := []struct {
+		name    string
+		message *domain.Message
+		want    bool
+	}{
+		{
+			name:    "voice in opted-in chat",
+			message: &domain.Message{ChatID: 1, VoiceDuration: 10 * time.Second},
+			want:    true,
+		},
+		{
+			name:    "voice in other chat",
+			message: &domain.Message{ChatID: 2, VoiceDuration: 10 * time.Second},
+			want:    false,
+		},
+		{
+			name:    "no voice",
+			message: &domain.Message{ChatID: 1, Text: "hello"},
+			want:    false,
+		},
+		{
+			name:    "too short",
+			message: &domain.Message{ChatID: 1, VoiceDuration: time.Second},
+			want:    false,
+		},
+		{
+			name:    "too long",
+			message: &domain.Message{ChatID: 1, VoiceDuration: 2 * time.Minute},
+			want:    false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, a.Matches(tc.message))
+		})
+	}
+}
+
+func TestAutoTranscribe_RespondSuccessful(t *testing.T) {
+	ts := &MockTextSender{}
+	a := newTestAutoTranscribe(t, &MockTranscriber{}, ts)
+
+	err := a.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, AudioURL: "mock"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "mock", ts.Message)
+}
+
+func TestAutoTranscribe_RespondOverLimit(t *testing.T) {
+	ts := &MockTextSender{}
+	a := newTestAutoTranscribe(t, &MockTranscriber{}, ts)
+	respond := Chain("autoTranscribe", a.Respond, SpendLimit(&MockTracker{withinLimit: false}))
+
+	err := respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, AudioURL: "mock"})
+	require.NoError(t, err)
+
+	assert.Empty(t, ts.Message)
+}
+
+func TestAutoTranscribe_RespondErrorGenerating(t *testing.T) {
+	ts := &MockTextSender{}
+	a := newTestAutoTranscribe(t, &MockTranscriber{err: errors.New("mock error")}, ts)
+
+	err := a.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, AudioURL: "mock"})
+	require.Error(t, err)
+
+	assert.Equal(t, "failed to generate transcript: mock error", ts.Message)
+}
@@ -7,6 +7,7 @@ import (
 	"hsbot/internal/core/domain"
 	"hsbot/internal/core/port"
 	"hsbot/internal/core/service"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,28 +22,38 @@ type Chat struct {
 	textGenerator port.TextGenerator
 	textSender    port.TextSender
 	transcriber   port.Transcriber
+	modelProvider port.ModelProvider
 	cacheDuration time.Duration
 	command       string
 	cache         *sync.Map
 
-	track service.Tracker
-	l     *zerolog.Logger
+	track         service.Tracker
+	confirmations *Confirmations
+	l             *zerolog.Logger
 }
 
+// Conversation is the cached context of a chat. Its fields are guarded by mu, which is held for a whole chat turn
+// so that concurrent requests of the same chat do not interleave.
 type Conversation struct {
+	mu         sync.Mutex
 	timestamp  time.Time
 	messages   []domain.Prompt
 	exitSignal chan struct{}
 	chatID     int64
+	// lastReplyID is the message ID of the latest answer, the only one that can be regenerated.
+	lastReplyID int
 }
 
 type ChatParams struct {
 	TextGenerator port.TextGenerator
 	TextSender    port.TextSender
 	Transcriber   port.Transcriber
+	ModelProvider port.ModelProvider
 	Command       string
 	CacheDuration time.Duration
 	Track         service.Tracker
+	// Confirmations holds back expensive regenerations of answers by button, if set.
+	Confirmations *Confirmations
 }
 
 func NewChat(p ChatParams) (*Chat, error) {
@@ -55,10 +66,12 @@ func NewChat(p ChatParams) (*Chat, error) {
 		textGenerator: p.TextGenerator,
 		textSender:    p.TextSender,
 		transcriber:   p.Transcriber,
+		modelProvider: p.ModelProvider,
 		cacheDuration: p.CacheDuration,
 		command:       p.Command,
 		cache:         &sync.Map{},
 		track:         p.Track,
+		confirmations: p.Confirmations,
 		l:             &logger,
 	}
 
@@ -69,29 +82,26 @@ func (c *Chat) GetCommand() string {
 	return c.command
 }
 
-func (c *Chat) Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error {
-	l := c.l.With().
-		Int("messageId", message.ID).
-		Int64("chatId", message.ChatID).
-		Str("func", "Respond").
-		Logger()
+func (c *Chat) GetDescription() string {
+	return "Chat with an AI model, keeping the conversation context"
+}
 
-	l.Debug().Str("prompt", message.Text).
+func (c *Chat) GetUsage() string {
+	return fmt.Sprintf("usage: %s [#model] <prompt>", c.command)
+}
+
+// ReadsAudio returns true, as the transcript of the audio is added to the prompt.
+func (c *Chat) ReadsAudio() bool {
+	return true
+}
+
+func (c *Chat) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	zerolog.Ctx(ctx).Debug().Str("prompt", message.Text).
 		Str("quoted", message.QuotedText).
 		Str("image", message.ImageURL).
 		Str("audio", message.AudioURL).
 		Str("username", message.Username).
-		Msg("handling request")
-
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	if !c.track.CheckLimit(ctx, message.ChatID) {
-		l.Debug().Msg("spending limit reached")
-		return nil
-	}
-
-	go c.textSender.SendChatAction(ctx, message.ChatID, domain.Typing)
+		Msg("chat request")
 
 	promptText, err := c.extractPrompt(ctx, message)
 	if err != nil {
@@ -102,14 +112,11 @@ func (c *Chat) Respond(ctx context.Context, timeout time.Duration, message *doma
 
 	conversation, err := c.getConversationForMessage(message)
 	if err != nil {
-		if err := c.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to get conversation: %w", err),
-			message); err != nil {
-			return err
-		}
+		return c.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to get conversation: %w", err), message)
 	}
+	defer conversation.mu.Unlock()
 
-	conversation.timestamp = time.Now()
-	go c.startConversationTimer(conversation)
+	c.resetConversationTimer(conversation)
 
 	if message.QuotedText != "" && message.ImageURL == "" {
 		// if there's a user message being replied to, add the previous message to the context
@@ -136,14 +143,20 @@ func (c *Chat) Respond(ctx context.Context, timeout time.Duration, message *doma
 		return c.textSender.NotifyAndReturnError(ctx, err, message)
 	}
 
-	c.track.AddCost(message.ChatID, response.Metadata.Cost)
+	c.addCost(ctx, message, response.Metadata)
 
 	conversation.messages = append(conversation.messages,
-		domain.Prompt{Author: domain.System, Prompt: response.Response})
+		domain.Prompt{
+			Author: domain.System,
+			Prompt: response.Response,
+			Model:  response.Metadata.Model,
+			Cost:   response.Metadata.Cost,
+		})
 
-	_, err = c.textSender.SendMessageReply(ctx,
+	conversation.lastReplyID, err = c.textSender.SendMessageReplyWithKeyboard(ctx,
 		message,
-		response.Response)
+		response.Response,
+		c.replyKeyboard())
 	if err != nil {
 		return err
 	}
@@ -155,6 +168,7 @@ func (c *Chat) Respond(ctx context.Context, timeout time.Duration, message *doma
 	return nil
 }
 
+// getConversationForMessage returns the locked conversation of the message's chat, creating it if necessary.
 func (c *Chat) getConversationForMessage(message *domain.Message) (*Conversation, error) {
 	l := c.l.With().
 		Int("messageId", message.ID).
@@ -162,29 +176,27 @@ func (c *Chat) getConversationForMessage(message *domain.Message) (*Conversation
 		Str("func", "getConversationForMessage").
 		Logger()
 
-	var conversation *Conversation
-	conv, ok := c.cache.Load(message.ChatID)
-	if !ok {
-		l.Trace().Msg("new conversation")
-
-		c.cache.Store(message.ChatID, &Conversation{
-			chatID:     message.ChatID,
-			exitSignal: make(chan struct{}),
-		})
-		conv, _ = c.cache.Load(message.ChatID)
-		conversation, ok = conv.(*Conversation)
+	for {
+		conv, loaded := c.cache.LoadOrStore(message.ChatID, &Conversation{chatID: message.ChatID})
+		conversation, ok := conv.(*Conversation)
 		if !ok {
 			return nil, errors.New("conversation type error")
 		}
-	} else {
-		conversation, ok = conv.(*Conversation)
-		if !ok {
-			return nil, errors.New("conversation type error")
+
+		conversation.mu.Lock()
+
+		// the conversation might have expired while waiting for the lock
+		if current, _ := c.cache.Load(message.ChatID); current == conv {
+			if loaded {
+				l.Trace().Msg("existing conversation")
+			} else {
+				l.Trace().Msg("new conversation")
+			}
+			return conversation, nil
 		}
-		l.Trace().Msg("existing conversation, stopping timer")
-		conversation.exitSignal <- struct{}{}
+
+		conversation.mu.Unlock()
 	}
-	return conversation, nil
 }
 
 func (c *Chat) sendDebugInfo(message *domain.Message, metadata domain.ResponseMetadata, length int) {
@@ -211,13 +223,18 @@ convo size: %d | cost: %f`,
 }
 
 func (c *Chat) extractPrompt(ctx context.Context, message *domain.Message) (string, error) {
-	promptText := ParseCommandArgs(message.Text)
+	promptText := message.Text
+	if strings.HasPrefix(promptText, "/") {
+		promptText = ParseCommandArgs(promptText)
+	}
+
 	if promptText == "" {
 		return "", domain.ErrEmptyPrompt
 	}
 
 	if message.AudioURL != "" {
-		transcript, err := c.transcriber.GenerateFromAudio(ctx, message.AudioURL)
+		transcript, err := c.transcriber.GenerateFromAudio(withTranscriptionContext(ctx, c.textSender, message),
+			message.AudioURL)
 		if err != nil {
 			return "", fmt.Errorf("failed to generate transcript: %w", err)
 		}
@@ -229,18 +246,51 @@ func (c *Chat) extractPrompt(ctx context.Context, message *domain.Message) (stri
 	return promptText, nil
 }
 
-func (c *Chat) startConversationTimer(convo *Conversation) {
+// addCost adds the cost of a response to the spending of the message's chat and user.
+func (c *Chat) addCost(ctx context.Context, message *domain.Message, metadata domain.ResponseMetadata) {
+	c.track.AddCost(ctx, domain.LedgerEntry{ChatID: message.ChatID, UserID: message.UserID, Username: message.Username,
+		Command: c.command, Model: metadata.Model, Tokens: metadata.TotalTokens, Cost: metadata.Cost})
+}
+
+// resetConversationTimer stops the running expiry timer of a conversation and starts a new one. The caller must
+// hold the conversation lock.
+func (c *Chat) resetConversationTimer(convo *Conversation) {
+	convo.stopTimer()
+	convo.timestamp = time.Now()
+	convo.exitSignal = make(chan struct{}, 1)
+	go c.startConversationTimer(convo, convo.exitSignal)
+}
+
+func (c *Chat) startConversationTimer(convo *Conversation, exitSignal <-chan struct{}) {
 	t := time.NewTimer(c.cacheDuration)
 
 	for {
 		select {
 		case <-t.C:
 			c.l.Debug().Int64("chatID", convo.chatID).Msg("clearing conversation")
-			c.cache.Delete(convo.chatID)
+			c.cache.CompareAndDelete(convo.chatID, convo)
 			return
-		case <-convo.exitSignal:
+		case <-exitSignal:
 			t.Stop()
 			return
 		}
 	}
 }
+
+// stopTimer signals the expiry timer of the conversation to stop. Each timer has its own buffered signal channel,
+// so this never blocks, even if the timer already fired. The caller must hold the conversation lock.
+func (convo *Conversation) stopTimer() {
+	if convo.exitSignal == nil {
+		return
+	}
+
+	select {
+	case convo.exitSignal <- struct{}{}:
+	default:
+	}
+}
+
+// expiresIn returns the remaining time until the conversation is cleared. The caller must hold the conversation lock.
+func (convo *Conversation) expiresIn(cacheDuration time.Duration) time.Duration {
+	return max(time.Until(convo.timestamp.Add(cacheDuration)), 0)
+}
@@ -0,0 +1,266 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"strings"
+	"time"
+)
+
+const chatNamespace = "chat"
+
+const (
+	callbackRegenerate = "regen"
+	callbackModels     = "models"
+	callbackModel      = "model"
+	callbackBack       = "back"
+)
+
+const modelButtonsPerRow = 3
+
+// anyReply regenerates the latest answer of a conversation, whichever message it was sent as.
+const anyReply = -1
+
+// errNotLatestAnswer is returned when the answer to regenerate was replaced in the meantime.
+var errNotLatestAnswer = errors.New("only the latest answer can be regenerated")
+
+func (c *Chat) GetCallbackNamespace() string {
+	return chatNamespace
+}
+
+// BoundCommand returns the command of the chat, its buttons are subject to its config and rate limit.
+func (c *Chat) BoundCommand() string {
+	return c.command
+}
+
+// IsRateLimited reports whether a button regenerates an answer, switching keyboards is not rate limited.
+func (c *Chat) IsRateLimited(data string) bool {
+	action, _, _ := strings.Cut(data, ":")
+	return action == callbackRegenerate || action == callbackModel
+}
+
+func (c *Chat) HandleCallback(ctx context.Context, timeout time.Duration, callback *domain.Callback) error {
+	l := c.l.With().
+		Int("messageId", callback.Message.ID).
+		Int64("chatId", callback.ChatID).
+		Str("data", callback.Data).
+		Str("func", "HandleCallback").
+		Logger()
+
+	l.Debug().Msg("handling callback")
+
+	if role := domain.RoleFromContext(ctx); !role.AllowsCommand(c.command) {
+		return c.textSender.AnswerCallbackQuery(ctx, callback.ID,
+			fmt.Sprintf("%s is not available to %ss", c.command, role.Name))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	action, keyword, _ := strings.Cut(callback.Data, ":")
+
+	switch action {
+	case callbackModels:
+		return c.answerWithKeyboard(ctx, callback, c.modelKeyboard())
+	case callbackBack:
+		return c.answerWithKeyboard(ctx, callback, c.replyKeyboard())
+	case callbackRegenerate, callbackModel:
+		return c.regenerateReply(ctx, timeout, callback, keyword)
+	default:
+		return c.textSender.AnswerCallbackQuery(ctx, callback.ID, "unknown action")
+	}
+}
+
+// answerWithKeyboard acknowledges a callback and swaps the keyboard of its message.
+func (c *Chat) answerWithKeyboard(ctx context.Context, callback *domain.Callback, keyboard domain.Keyboard) error {
+	err := c.textSender.AnswerCallbackQuery(ctx, callback.ID, "")
+	if err != nil {
+		return err
+	}
+
+	return c.textSender.EditMessageKeyboard(ctx, callback.ChatID, callback.Message.ID, keyboard)
+}
+
+// regenerateReply replaces the latest answer of a conversation, replying to the original prompt with a new one.
+// Regenerations estimated above the confirmation threshold wait for the user to confirm them.
+func (c *Chat) regenerateReply(ctx context.Context, timeout time.Duration, callback *domain.Callback,
+	keyword string) error {
+	conversation, err := c.loadConversation(callback.ChatID)
+	if err != nil {
+		return c.textSender.AnswerCallbackQuery(ctx, callback.ID, err.Error())
+	}
+
+	conversation.mu.Lock()
+	latest := conversation.lastReplyID == callback.Message.ID
+	conversation.mu.Unlock()
+
+	if !latest {
+		return c.textSender.AnswerCallbackQuery(ctx, callback.ID, errNotLatestAnswer.Error())
+	}
+
+	cost := c.estimateCost(callback.ChatID, keyword, "")
+	spendCap := domain.RoleFromContext(ctx).SpendCap
+	if !c.track.AllowsCost(callback.ChatID, callback.UserID, spendCap, cost) {
+		return c.textSender.AnswerCallbackQuery(ctx, callback.ID, "spending limit reached")
+	}
+
+	prompt := &domain.Message{ID: callback.Message.ID, ChatID: callback.ChatID, UserID: callback.UserID,
+		Username: callback.Username}
+	if callback.Message.ReplyToMessageID != nil && *callback.Message.ReplyToMessageID != 0 {
+		prompt.ID = *callback.Message.ReplyToMessageID
+	}
+
+	if c.confirmations == nil || !c.confirmations.Required(cost) {
+		err = c.textSender.AnswerCallbackQuery(ctx, callback.ID, "regenerating...")
+		if err != nil {
+			return err
+		}
+
+		go c.textSender.SendChatAction(ctx, callback.ChatID, domain.Typing)
+
+		err = c.regenerate(ctx, conversation, prompt, callback.Message.ID, keyword)
+		if errors.Is(err, errNotLatestAnswer) {
+			// another press of the buttons regenerated the answer first
+			c.l.Debug().Int("messageId", callback.Message.ID).Msg("answer already replaced")
+			return nil
+		}
+
+		return err
+	}
+
+	err = c.textSender.AnswerCallbackQuery(ctx, callback.ID, "")
+	if err != nil {
+		return err
+	}
+
+	c.l.Debug().Float64("estimate", cost).Msg("asking for confirmation")
+
+	return c.confirmations.Ask(ctx, prompt, cost, func(confirmed context.Context) error {
+		ctx, cancel := context.WithTimeout(confirmed, timeout)
+		defer cancel()
+
+		if !c.track.CheckCost(ctx, callback.ChatID, callback.UserID, spendCap, cost) {
+			c.l.Debug().Float64("estimate", cost).Msg("spending limit reached after confirmation")
+			return nil
+		}
+
+		go c.textSender.SendChatAction(ctx, callback.ChatID, domain.Typing)
+
+		err := c.regenerate(ctx, conversation, prompt, callback.Message.ID, keyword)
+		if errors.Is(err, errNotLatestAnswer) {
+			return c.textSender.NotifyAndReturnError(ctx, err, prompt)
+		}
+
+		return err
+	})
+}
+
+// regenerate drops the latest answer of a conversation and generates a new one from the same context, optionally
+// with the model of the given keyword. The new answer is sent as reply to the message. The latest answer must still
+// be the reply with the given ID, unless it is anyReply, or errNotLatestAnswer is returned.
+func (c *Chat) regenerate(ctx context.Context, conversation *Conversation, message *domain.Message, replyID int,
+	keyword string) error {
+	conversation.mu.Lock()
+	defer conversation.mu.Unlock()
+
+	if replyID != anyReply && conversation.lastReplyID != replyID {
+		return errNotLatestAnswer
+	}
+
+	size := len(conversation.messages)
+	if size < 2 || conversation.messages[size-1].Author != domain.System {
+		return c.textSender.NotifyAndReturnError(ctx, errors.New("no answer to regenerate"), message)
+	}
+
+	c.resetConversationTimer(conversation)
+
+	previousReplyID := conversation.lastReplyID
+	conversation.messages = conversation.messages[:size-1]
+	if keyword != "" {
+		conversation.messages[size-2].Model = keyword
+	}
+
+	response, err := c.textGenerator.GenerateFromPrompt(ctx, conversation.messages)
+	if err != nil {
+		err := fmt.Errorf("failed to regenerate response: %w", err)
+		conversation.messages = append(conversation.messages, domain.Prompt{Author: domain.System, Prompt: err.Error()})
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	c.addCost(ctx, message, response.Metadata)
+
+	conversation.messages = append(conversation.messages,
+		domain.Prompt{
+			Author: domain.System,
+			Prompt: response.Response,
+			Model:  response.Metadata.Model,
+			Cost:   response.Metadata.Cost,
+		})
+
+	err = c.textSender.EditMessageKeyboard(ctx, message.ChatID, previousReplyID, nil)
+	if err != nil {
+		c.l.Warn().Err(err).Int64("chatID", message.ChatID).Msg("failed to remove keyboard of previous answer")
+	}
+
+	conversation.lastReplyID, err = c.textSender.SendMessageReplyWithKeyboard(ctx, message, response.Response,
+		c.replyKeyboard())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// loadConversation fetches the cached conversation of a chat without creating a new one.
+func (c *Chat) loadConversation(chatID int64) (*Conversation, error) {
+	conv, ok := c.cache.Load(chatID)
+	if !ok {
+		return nil, errors.New("no conversation context")
+	}
+
+	conversation, ok := conv.(*Conversation)
+	if !ok {
+		return nil, errors.New("conversation type error")
+	}
+
+	return conversation, nil
+}
+
+// replyKeyboard returns the keyboard attached to chat answers.
+func (c *Chat) replyKeyboard() domain.Keyboard {
+	row := []domain.Button{{Text: "🔁 Regenerate", Data: CallbackData(chatNamespace, callbackRegenerate)}}
+
+	if c.modelProvider != nil && len(c.modelProvider.GetModels()) > 1 {
+		row = append(row, domain.Button{Text: "🔀 Switch model", Data: CallbackData(chatNamespace, callbackModels)})
+	}
+
+	return domain.Keyboard{row}
+}
+
+// modelKeyboard returns a model picker, regenerating the answer with the chosen model.
+func (c *Chat) modelKeyboard() domain.Keyboard {
+	var keyboard domain.Keyboard
+	var row []domain.Button
+
+	if c.modelProvider != nil {
+		for _, model := range c.modelProvider.GetModels() {
+			row = append(row, domain.Button{
+				Text: model.Keyword,
+				Data: CallbackData(chatNamespace, callbackModel, model.Keyword),
+			})
+
+			if len(row) == modelButtonsPerRow {
+				keyboard = append(keyboard, row)
+				row = nil
+			}
+		}
+	}
+
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	return append(keyboard, []domain.Button{{Text: "« Back", Data: CallbackData(chatNamespace, callbackBack)}})
+}
@@ -0,0 +1,282 @@
+package command
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type MockModelProvider struct {
+	models []domain.Model
+}
+
+func (m *MockModelProvider) GetModels() []domain.Model {
+	return m.models
+}
+
+func newCallbackTestChat(t *testing.T, ms *MockTextSender, mtr *MockTracker) *Chat {
+	t.Helper()
+
+	chat, err := NewChat(ChatParams{
+		TextGenerator: &MockTextGenerator{response: "mock response"},
+		TextSender:    ms,
+		Transcriber:   &MockTranscriber{},
+		Command:       "/chat",
+		CacheDuration: time.Second * 3,
+		Track:         mtr,
+		ModelProvider: &MockModelProvider{models: []domain.Model{
+			{Identifier: "model/one", Keyword: "one"},
+			{Identifier: "model/two", Keyword: "two"},
+		}},
+	})
+	require.NoError(t, err)
+
+	return chat
+}
+
+func newTestCallback(data string, messageID int) *domain.Callback {
+	replyTo := 1
+	return &domain.Callback{
+		ID:     "query",
+		ChatID: 1,
+		Data:   data,
+		Message: &domain.Message{
+			ID:               messageID,
+			ChatID:           1,
+			ReplyToMessageID: &replyTo,
+		},
+	}
+}
+
+func TestChat_HandleCallback(t *testing.T) {
+	tests := []struct {
+		name           string
+		data           string
+		messageID      int
+		seed           bool
+		withinLimit    bool
+		role           domain.Role
+		wantAnswer     string
+		wantModel      string
+		wantRegenerate bool
+	}{
+		{
+			name:           "regenerate latest answer",
+			data:           "regen",
+			messageID:      0,
+			seed:           true,
+			withinLimit:    true,
+			wantAnswer:     "regenerating...",
+			wantRegenerate: true,
+		},
+		{
+			name:           "regenerate with other model",
+			data:           "model:two",
+			messageID:      0,
+			seed:           true,
+			withinLimit:    true,
+			wantAnswer:     "regenerating...",
+			wantModel:      "two",
+			wantRegenerate: true,
+		},
+		{
+			name:        "stale answer",
+			data:        "regen",
+			messageID:   42,
+			seed:        true,
+			withinLimit: true,
+			wantAnswer:  "only the latest answer can be regenerated",
+		},
+		{
+			name:        "no conversation",
+			data:        "regen",
+			withinLimit: true,
+			wantAnswer:  "no conversation context",
+		},
+		{
+			name:        "spending limit reached",
+			data:        "regen",
+			seed:        true,
+			withinLimit: false,
+			wantAnswer:  "spending limit reached",
+		},
+		{
+			name:       "unknown action",
+			data:       "foo",
+			wantAnswer: "unknown action",
+		},
+		{
+			name:        "command not permitted",
+			data:        "regen",
+			seed:        true,
+			withinLimit: true,
+			role:        domain.Role{Name: domain.RoleGuest, Commands: []string{"/image"}},
+			wantAnswer:  "/chat is not available to guests",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ms := &MockTextSender{}
+			mtr := &MockTracker{withinLimit: true}
+			chat := newCallbackTestChat(t, ms, mtr)
+
+			if tc.seed {
+				err := chat.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, ID: 1, Text: "/chat prompt"})
+				require.NoError(t, err)
+				ms.Message = ""
+			}
+			mtr.withinLimit = tc.withinLimit
+
+			ctx := domain.WithRole(t.Context(), tc.role)
+			err := chat.HandleCallback(ctx, time.Minute, newTestCallback(tc.data, tc.messageID))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantAnswer, ms.CallbackAnswer)
+
+			if !tc.wantRegenerate {
+				assert.Empty(t, ms.Message)
+				return
+			}
+
+			conversation, err := chat.loadConversation(1)
+			require.NoError(t, err)
+			require.Len(t, conversation.messages, 2)
+			assert.Equal(t, tc.wantModel, conversation.messages[0].Model)
+			assert.Equal(t, "mock response", conversation.messages[1].Prompt)
+			assert.Equal(t, "mock response", ms.Message)
+			assert.Equal(t, chat.replyKeyboard(), ms.Keyboard)
+		})
+	}
+}
+
+func TestChat_HandleCallbackKeyboards(t *testing.T) {
+	ms := &MockTextSender{}
+	chat := newCallbackTestChat(t, ms, &MockTracker{withinLimit: true})
+
+	err := chat.HandleCallback(t.Context(), time.Minute, newTestCallback("models", 5))
+	require.NoError(t, err)
+	assert.Equal(t, domain.Keyboard{
+		{
+			{Text: "one", Data: "chat:model:one"},
+			{Text: "two", Data: "chat:model:two"},
+		},
+		{{Text: "« Back", Data: "chat:back"}},
+	}, ms.Keyboard)
+
+	err = chat.HandleCallback(t.Context(), time.Minute, newTestCallback("back", 5))
+	require.NoError(t, err)
+	assert.Equal(t, domain.Keyboard{{
+		{Text: "🔁 Regenerate", Data: "chat:regen"},
+		{Text: "🔀 Switch model", Data: "chat:models"},
+	}}, ms.Keyboard)
+}
+
+func TestChat_IsRateLimited(t *testing.T) {
+	chat := newCallbackTestChat(t, &MockTextSender{}, &MockTracker{withinLimit: true})
+
+	assert.True(t, chat.IsRateLimited("regen"))
+	assert.True(t, chat.IsRateLimited("model:one"))
+	assert.False(t, chat.IsRateLimited("models"))
+	assert.False(t, chat.IsRateLimited("back"))
+}
+
+func TestChat_HandleCallbackConfirmation(t *testing.T) {
+	ms := &MockTextSender{}
+	confirmations := &Confirmations{textSender: ms, threshold: 0.5, pending: map[string]pendingRequest{},
+		now: time.Now}
+	chat, err := NewChat(ChatParams{
+		TextGenerator: &MockTextGenerator{response: "mock response"},
+		TextSender:    ms,
+		Transcriber:   &MockTranscriber{},
+		Command:       "/chat",
+		CacheDuration: time.Second * 3,
+		Track:         &MockTracker{withinLimit: true},
+		ModelProvider: &MockModelProvider{models: []domain.Model{
+			{Identifier: "model/one", Keyword: "one", Default: 1},
+			{Identifier: "model/two", Keyword: "two", PromptPrice: 1_000_000},
+		}},
+		Confirmations: confirmations,
+	})
+	require.NoError(t, err)
+
+	err = chat.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, ID: 1, Text: "/chat prompt"})
+	require.NoError(t, err)
+
+	require.NoError(t, chat.HandleCallback(t.Context(), time.Minute, newTestCallback("regen", 0)))
+	assert.Equal(t, "regenerating...", ms.CallbackAnswer, "cheap regenerations run right away")
+
+	require.NoError(t, chat.HandleCallback(t.Context(), time.Minute, newTestCallback("model:two", 0)))
+	assert.Empty(t, ms.CallbackAnswer)
+	assert.True(t, strings.HasPrefix(ms.Message, "This request will cost about $"), ms.Message)
+
+	conversation, err := chat.loadConversation(1)
+	require.NoError(t, err)
+	assert.Empty(t, conversation.messages[0].Model, "the regeneration waits for the confirmation")
+
+	_, payload := ParseCallbackData(ms.Keyboard[0][0].Data)
+	err = confirmations.HandleCallback(t.Context(), time.Minute, &domain.Callback{ChatID: 1, Data: payload,
+		Message: &domain.Message{ID: 2}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "two", conversation.messages[0].Model)
+	assert.Equal(t, "mock response", ms.Message)
+
+	require.NoError(t, chat.HandleCallback(t.Context(), time.Minute, newTestCallback("model:two", 0)))
+	_, payload = ParseCallbackData(ms.Keyboard[0][0].Data)
+	conversation.lastReplyID = 9
+	messages := slices.Clone(conversation.messages)
+
+	err = confirmations.HandleCallback(t.Context(), time.Minute, &domain.Callback{ChatID: 1, Data: payload,
+		Message: &domain.Message{ID: 3}})
+	require.ErrorIs(t, err, errNotLatestAnswer, "the answer was replaced while waiting for the confirmation")
+	assert.Equal(t, messages, conversation.messages)
+}
+
+// silentTracker fails tests telling the chat about exceeded limits.
+type silentTracker struct {
+	MockTracker
+	t *testing.T
+}
+
+func (m silentTracker) CheckCost(_ context.Context, _, _ int64, _, _ float64) bool {
+	m.t.Error("buttons must not post limit notices to the chat")
+	return false
+}
+
+func TestChat_HandleCallbackSpendingLimit(t *testing.T) {
+	ms := &MockTextSender{}
+	chat := newCallbackTestChat(t, ms, &MockTracker{withinLimit: true})
+
+	err := chat.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, ID: 1, Text: "/chat prompt"})
+	require.NoError(t, err)
+	ms.Message = ""
+
+	chat.track = silentTracker{t: t}
+	require.NoError(t, chat.HandleCallback(t.Context(), time.Minute, newTestCallback("regen", 0)))
+	assert.Equal(t, "spending limit reached", ms.CallbackAnswer)
+	assert.Empty(t, ms.Message)
+}
+
+func TestChat_RegenerateReplacedAnswer(t *testing.T) {
+	ms := &MockTextSender{}
+	chat := newCallbackTestChat(t, ms, &MockTracker{withinLimit: true})
+
+	err := chat.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, ID: 1, Text: "/chat prompt"})
+	require.NoError(t, err)
+
+	conversation, err := chat.loadConversation(1)
+	require.NoError(t, err)
+	conversation.lastReplyID = 7
+	messages := slices.Clone(conversation.messages)
+
+	err = chat.regenerate(t.Context(), conversation, &domain.Message{ChatID: 1, ID: 1}, 5, "two")
+	require.ErrorIs(t, err, errNotLatestAnswer, "the pressed answer was replaced after the button was checked")
+	assert.Equal(t, messages, conversation.messages)
+	assert.Equal(t, 7, conversation.lastReplyID)
+}
@@ -8,7 +8,7 @@ import (
 	"hsbot/internal/core/port"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 )
 
 type ChatClearContext struct {
@@ -25,14 +25,16 @@ func (c *ChatClearContext) GetCommand() string {
 	return c.command
 }
 
-func (c *ChatClearContext) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
-	l := log.With().
-		Int("messageId", message.ID).
-		Int64("chatId", message.ChatID).
-		Str("command", c.GetCommand()).
-		Logger()
+func (c *ChatClearContext) GetDescription() string {
+	return "Clear the chat conversation context"
+}
 
-	l.Info().Msg("handling request")
+func (c *ChatClearContext) GetUsage() string {
+	return "usage: " + c.command
+}
+
+func (c *ChatClearContext) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	l := zerolog.Ctx(ctx)
 
 	conv, ok := c.chat.cache.Load(message.ChatID)
 	if !ok {
@@ -52,6 +54,7 @@ func (c *ChatClearContext) Respond(ctx context.Context, _ time.Duration, message
 		return errors.New("conversation type error")
 	}
 
+	conversation.mu.Lock()
 	size := len(conversation.messages)
 
 	var plural string
@@ -59,8 +62,9 @@ func (c *ChatClearContext) Respond(ctx context.Context, _ time.Duration, message
 		plural = "s"
 	}
 
-	c.chat.cache.Delete(message.ChatID)
-	conversation.exitSignal <- struct{}{}
+	c.chat.cache.CompareAndDelete(message.ChatID, conversation)
+	conversation.stopTimer()
+	conversation.mu.Unlock()
 
 	l.Debug().Msg("cleared conversation cache")
 
@@ -20,6 +20,24 @@ type mockTextSender struct {
 	notifyErr      error
 }
 
+func (m *mockTextSender) SendMessageReplyWithKeyboard(ctx context.Context, message *domain.Message, text string,
+	_ domain.Keyboard) (int, error) {
+	return m.SendMessageReply(ctx, message, text)
+}
+
+func (m *mockTextSender) EditMessageKeyboard(_ context.Context, _ int64, _ int, _ domain.Keyboard) error {
+	return m.replyErr
+}
+
+func (m *mockTextSender) AnswerCallbackQuery(_ context.Context, _ string, _ string) error {
+	return m.replyErr
+}
+
+func (m *mockTextSender) EditMessageText(_ context.Context, _ int64, _ int, text string) error {
+	m.replyCalls = append(m.replyCalls, text)
+	return m.replyErr
+}
+
 func (m *mockTextSender) SendChatAction(_ context.Context, _ int64, _ domain.Action) {
 	// not implemented
 }
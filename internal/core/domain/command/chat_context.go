@@ -0,0 +1,113 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+// charsPerToken is a rough average used to estimate the token count of a conversation.
+const charsPerToken = 4
+
+type ChatContext struct {
+	chat       *Chat
+	textSender port.TextSender
+	command    string
+}
+
+func NewChatContext(chat *Chat, sender port.TextSender, command string) *ChatContext {
+	return &ChatContext{chat: chat, textSender: sender, command: command}
+}
+
+func (c *ChatContext) GetCommand() string {
+	return c.command
+}
+
+func (c *ChatContext) GetDescription() string {
+	return "Show a summary of the chat conversation context"
+}
+
+func (c *ChatContext) GetUsage() string {
+	return "usage: " + c.command
+}
+
+func (c *ChatContext) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	l := zerolog.Ctx(ctx)
+
+	conv, ok := c.chat.cache.Load(message.ChatID)
+	if !ok {
+		l.Debug().Msg("no conversation in cache")
+
+		_, err := c.textSender.SendMessageReply(ctx, message, "no conversation context")
+		if err != nil {
+			err = fmt.Errorf("error sending context response: %w", err)
+			return c.textSender.NotifyAndReturnError(ctx, err, message)
+		}
+
+		return nil
+	}
+
+	conversation, ok := conv.(*Conversation)
+	if !ok {
+		return errors.New("conversation type error")
+	}
+
+	conversation.mu.Lock()
+	summary := summarizeConversation(conversation.messages, conversation.expiresIn(c.chat.cacheDuration))
+	conversation.mu.Unlock()
+
+	_, err := c.textSender.SendMessageReply(ctx, message, summary)
+	if err != nil {
+		err = fmt.Errorf("error sending context response: %w", err)
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	return nil
+}
+
+func summarizeConversation(messages []domain.Prompt, expiresIn time.Duration) string {
+	var userTurns, systemTurns, images, chars int
+	var authors []string
+
+	for _, message := range messages {
+		chars += utf8.RuneCountInString(message.Prompt)
+
+		if message.ImageURL != "" {
+			images++
+		}
+
+		if message.Author != domain.User {
+			systemTurns++
+			continue
+		}
+
+		userTurns++
+		if author, _, ok := strings.Cut(message.Prompt, ": "); ok && !slices.Contains(authors, author) {
+			authors = append(authors, author)
+		}
+	}
+
+	if len(authors) == 0 {
+		authors = []string{"-"}
+	}
+
+	return fmt.Sprintf(`conversation context:
+turns: %d (user: %d, assistant: %d)
+authors: %s
+estimated tokens: ~%d
+images: %d
+expires in: %s`,
+		len(messages), userTurns, systemTurns,
+		strings.Join(authors, ", "),
+		(chars+charsPerToken-1)/charsPerToken,
+		images,
+		expiresIn.Round(time.Second))
+}
@@ -0,0 +1,48 @@
+package command
+
+import (
+	"hsbot/internal/core/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatContext_Respond(t *testing.T) {
+	chat := &Chat{cache: new(sync.Map), cacheDuration: time.Hour}
+	chat.cache.Store(int64(1), &Conversation{
+		timestamp: time.Now(),
+		messages: []domain.Prompt{
+			{Author: domain.User, Prompt: "@alice: describe this", ImageURL: "https://example.com/image.jpg"},
+			{Author: domain.System, Prompt: "a cat"},
+			{Author: domain.User, Prompt: "@bob: and this?"},
+			{Author: domain.User, Prompt: "@alice: what about it"},
+			{Author: domain.System, Prompt: "a dog"},
+		},
+	})
+
+	sender := &mockTextSender{}
+	cc := NewChatContext(chat, sender, "/context")
+
+	err := cc.Respond(t.Context(), time.Second, &domain.Message{ID: 1, ChatID: 1})
+	require.NoError(t, err)
+	require.Len(t, sender.replyCalls, 1)
+
+	reply := sender.replyCalls[0]
+	assert.Contains(t, reply, "turns: 5 (user: 3, assistant: 2)")
+	assert.Contains(t, reply, "authors: @alice, @bob")
+	assert.Contains(t, reply, "estimated tokens: ~17")
+	assert.Contains(t, reply, "images: 1")
+	assert.Regexp(t, `expires in: (59m59s|1h0m0s)`, reply)
+}
+
+func TestChatContext_Respond_NoConversationInCache(t *testing.T) {
+	sender := &mockTextSender{}
+	cc := NewChatContext(&Chat{cache: new(sync.Map)}, sender, "/context")
+
+	err := cc.Respond(t.Context(), time.Second, &domain.Message{ID: 1, ChatID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"no conversation context"}, sender.replyCalls)
+}
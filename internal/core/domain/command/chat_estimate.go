@@ -0,0 +1,86 @@
+package command
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"strings"
+	"unicode/utf8"
+)
+
+// EstimateCost estimates the prompt cost of a chat request from the conversation and the new prompt, priced with the
+// model of a #keyword in the prompt or the first default model.
+func (c *Chat) EstimateCost(_ context.Context, message *domain.Message) float64 {
+	return c.estimateCost(message.ChatID, c.keywordInText(message.Text), message.Text+message.QuotedText)
+}
+
+// EstimateCost estimates the prompt cost of regenerating the latest answer, priced with the requested model.
+func (c *ChatRetry) EstimateCost(_ context.Context, message *domain.Message) float64 {
+	args, err := retryArgs.Parse(c.command, message.Text)
+	if err != nil {
+		return 0
+	}
+
+	keyword, err := c.findKeyword(args.String("model"))
+	if err != nil {
+		return 0
+	}
+
+	return c.chat.estimateCost(message.ChatID, keyword, "")
+}
+
+// estimateCost prices the prompt tokens of the chat's conversation and the text with the model of the keyword, or
+// the first default model without one. Models without price are estimated at zero.
+func (c *Chat) estimateCost(chatID int64, keyword, text string) float64 {
+	model, ok := c.pricedModel(keyword)
+	if !ok {
+		return 0
+	}
+
+	chars := utf8.RuneCountInString(text)
+	conv, _ := c.cache.Load(chatID)
+	if conversation, ok := conv.(*Conversation); ok {
+		conversation.mu.Lock()
+		for _, prompt := range conversation.messages {
+			chars += utf8.RuneCountInString(prompt.Prompt)
+		}
+		conversation.mu.Unlock()
+	}
+
+	return float64(chars) / charsPerToken * model.PromptPrice / 1_000_000
+}
+
+// pricedModel returns the model of the keyword, or the default model with the highest priority for an empty one.
+func (c *Chat) pricedModel(keyword string) (domain.Model, bool) {
+	if c.modelProvider == nil {
+		return domain.Model{}, false
+	}
+
+	var found domain.Model
+	for _, model := range c.modelProvider.GetModels() {
+		if keyword != "" && strings.EqualFold(model.Keyword, keyword) {
+			return model, true
+		}
+
+		if keyword == "" && model.Default > 0 && (found.Default == 0 || model.Default < found.Default) {
+			found = model
+		}
+	}
+
+	return found, found.Default > 0
+}
+
+// keywordInText returns the keyword of the first model requested with #keyword in the text.
+func (c *Chat) keywordInText(text string) string {
+	if c.modelProvider == nil {
+		return ""
+	}
+
+	text = strings.ToLower(text)
+	for _, model := range c.modelProvider.GetModels() {
+		if strings.Contains(text, "#"+strings.ToLower(model.Keyword)) {
+			return model.Keyword
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,50 @@
+package command
+
+import (
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChat_EstimateCost(t *testing.T) {
+	chat := &Chat{cache: new(sync.Map), modelProvider: &MockModelProvider{models: []domain.Model{
+		{Keyword: "cheap", Identifier: "model/cheap", PromptPrice: 1},
+		{Keyword: "second", Identifier: "model/second", Default: 2, PromptPrice: 2},
+		{Keyword: "first", Identifier: "model/first", Default: 1, PromptPrice: 10},
+		{Keyword: "free", Identifier: "model/free"},
+	}}}
+	chat.cache.Store(int64(1), &Conversation{messages: []domain.Prompt{
+		{Author: domain.User, Prompt: strings.Repeat("a", 400_000)},
+		{Author: domain.System, Prompt: strings.Repeat("b", 400_000)},
+	}})
+	retry := NewChatRetry(chat, &MockTextSender{}, "/retry")
+
+	prompt := strings.Repeat("c", 199_994)
+
+	tests := []struct {
+		name      string
+		estimator port.CostEstimator
+		message   *domain.Message
+		want      float64
+	}{
+		{name: "default model", estimator: chat, message: &domain.Message{ChatID: 2, Text: "/chat " + prompt},
+			want: 0.5},
+		{name: "keyword", estimator: chat, message: &domain.Message{ChatID: 2, Text: "/chat #CHEAP" + prompt},
+			want: 0.05},
+		{name: "conversation", estimator: chat, message: &domain.Message{ChatID: 1, Text: "/chat " + prompt},
+			want: 2.5},
+		{name: "unpriced model", estimator: chat, message: &domain.Message{ChatID: 1, Text: "/chat #free hi"}},
+		{name: "retry", estimator: retry, message: &domain.Message{ChatID: 1, Text: "/retry #second"}, want: 0.4},
+		{name: "retry with unknown model", estimator: retry, message: &domain.Message{ChatID: 1, Text: "/retry x"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.InDelta(t, tc.want, tc.estimator.EstimateCost(t.Context(), tc.message), 0.0001)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// exportVersion is the version of the JSON export format, checked on import.
+const exportVersion = 1
+
+// conversationExport is the JSON document of an exported conversation. Image URLs are not exported, as telegram file
+// links contain the bot token.
+type conversationExport struct {
+	Version    int            `json:"version"`
+	ChatID     int64          `json:"chat_id"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Turns      []exportedTurn `json:"turns"`
+}
+
+type exportedTurn struct {
+	Author   domain.Author `json:"author"`
+	Prompt   string        `json:"prompt"`
+	Model    string        `json:"model,omitempty"`
+	Cost     float64       `json:"cost,omitempty"`
+	HasImage bool          `json:"has_image,omitempty"`
+}
+
+type ChatExport struct {
+	chat           *Chat
+	textSender     port.TextSender
+	documentSender port.DocumentSender
+	command        string
+}
+
+func NewChatExport(chat *Chat, textSender port.TextSender, documentSender port.DocumentSender,
+	command string) *ChatExport {
+	return &ChatExport{chat: chat, textSender: textSender, documentSender: documentSender, command: command}
+}
+
+func (c *ChatExport) GetCommand() string {
+	return c.command
+}
+
+func (c *ChatExport) GetDescription() string {
+	return "Export the chat conversation as Markdown and JSON"
+}
+
+func (c *ChatExport) GetUsage() string {
+	return "usage: " + c.command
+}
+
+func (c *ChatExport) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	l := zerolog.Ctx(ctx)
+
+	conversation, err := c.chat.loadConversation(message.ChatID)
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	conversation.mu.Lock()
+	export := newConversationExport(message.ChatID, conversation.messages, time.Now())
+	conversation.mu.Unlock()
+
+	if len(export.Turns) == 0 {
+		return c.textSender.NotifyAndReturnError(ctx, errors.New("conversation is empty"), message)
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to encode conversation: %w", err), message)
+	}
+
+	name := fmt.Sprintf("conversation-%d-%s", message.ChatID, export.ExportedAt.Format("20060102-150405"))
+
+	err = c.documentSender.SendDocumentReply(ctx, message, name+".md", []byte(export.markdown()))
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	err = c.documentSender.SendDocumentReply(ctx, message, name+".json", data)
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	l.Debug().Int("turns", len(export.Turns)).Msg("exported conversation")
+
+	return nil
+}
+
+func newConversationExport(chatID int64, messages []domain.Prompt, now time.Time) conversationExport {
+	turns := make([]exportedTurn, 0, len(messages))
+	for _, message := range messages {
+		turns = append(turns, exportedTurn{
+			Author:   message.Author,
+			Prompt:   message.Prompt,
+			Model:    message.Model,
+			Cost:     message.Cost,
+			HasImage: message.ImageURL != "",
+		})
+	}
+
+	return conversationExport{
+		Version:    exportVersion,
+		ChatID:     chatID,
+		ExportedAt: now.UTC(),
+		Turns:      turns,
+	}
+}
+
+// markdown renders the export as a readable document, with the model and cost of each answer.
+func (e conversationExport) markdown() string {
+	var sb strings.Builder
+	var total float64
+
+	fmt.Fprintf(&sb, "# Conversation export\n\nChat %d, exported at %s.\n",
+		e.ChatID, e.ExportedAt.Format(time.RFC3339))
+
+	for _, turn := range e.Turns {
+		total += turn.Cost
+
+		if turn.Author == domain.System {
+			sb.WriteString("\n## assistant")
+			if turn.Model != "" {
+				fmt.Fprintf(&sb, " · %s", turn.Model)
+			}
+			fmt.Fprintf(&sb, " · $%.4f\n\n", turn.Cost)
+		} else {
+			sb.WriteString("\n## user\n\n")
+		}
+
+		if turn.HasImage {
+			sb.WriteString("_[image]_\n\n")
+		}
+
+		sb.WriteString(turn.Prompt)
+		sb.WriteString("\n")
+	}
+
+	fmt.Fprintf(&sb, "\n---\n\nTotal cost: $%.4f\n", total)
+
+	return sb.String()
+}
@@ -0,0 +1,151 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"hsbot/internal/core/domain"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockDocumentSender struct {
+	documents map[string][]byte
+	err       error
+}
+
+func (m *mockDocumentSender) SendDocumentReply(_ context.Context, _ *domain.Message, filename string,
+	data []byte) error {
+	if m.documents == nil {
+		m.documents = map[string][]byte{}
+	}
+	m.documents[filename] = data
+	return m.err
+}
+
+func TestChatExport_Respond(t *testing.T) {
+	chat := &Chat{cache: new(sync.Map)}
+	chat.cache.Store(int64(1), &Conversation{
+		messages: []domain.Prompt{
+			{Author: domain.User, Prompt: "@alice: describe this", ImageURL: "https://example.com/bot123:secret/a.jpg"},
+			{Author: domain.System, Prompt: "a cat", Model: "openai/gpt-4o", Cost: 0.0012},
+		},
+	})
+
+	ts := &mockTextSender{}
+	ds := &mockDocumentSender{}
+	export := NewChatExport(chat, ts, ds, "/export")
+
+	err := export.Respond(t.Context(), time.Second, &domain.Message{ID: 1, ChatID: 1})
+	require.NoError(t, err)
+	require.Len(t, ds.documents, 2)
+	assert.Empty(t, ts.notifyErrCalls)
+
+	var markdown, document []byte
+	for name, data := range ds.documents {
+		switch {
+		case strings.HasSuffix(name, ".md"):
+			markdown = data
+		case strings.HasSuffix(name, ".json"):
+			document = data
+		}
+	}
+
+	assert.Contains(t, string(markdown), "## assistant · openai/gpt-4o · $0.0012\n\na cat")
+	assert.Contains(t, string(markdown), "_[image]_")
+	assert.NotContains(t, string(document), "secret")
+
+	messages, err := parseConversationExport(document)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Prompt{
+		{Author: domain.User, Prompt: "@alice: describe this"},
+		{Author: domain.System, Prompt: "a cat", Model: "openai/gpt-4o", Cost: 0.0012},
+	}, messages)
+}
+
+func TestChatExport_Respond_NoConversation(t *testing.T) {
+	ts := &mockTextSender{}
+	ds := &mockDocumentSender{}
+	export := NewChatExport(&Chat{cache: new(sync.Map)}, ts, ds, "/export")
+
+	_ = export.Respond(t.Context(), time.Second, &domain.Message{ID: 1, ChatID: 1})
+
+	assert.Empty(t, ds.documents)
+	require.Len(t, ts.notifyErrCalls, 1)
+	assert.EqualError(t, ts.notifyErrCalls[0], "no conversation context")
+}
+
+func TestChatImport_Respond(t *testing.T) {
+	valid, err := json.Marshal(conversationExport{
+		Version: exportVersion,
+		Turns: []exportedTurn{
+			{Author: domain.User, Prompt: "@alice: hi"},
+			{Author: domain.System, Prompt: "hello", Model: "openai/gpt-4o", Cost: 0.1},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		document     *domain.Document
+		wantReply    string
+		wantErr      string
+		wantMessages int
+	}{
+		{
+			name:         "valid export",
+			document:     &domain.Document{Name: "export.json", Data: valid},
+			wantReply:    "imported conversation context with 2 messages",
+			wantMessages: 2,
+		},
+		{
+			name:    "no document",
+			wantErr: "reply to an exported JSON conversation with /import",
+		},
+		{
+			name:     "invalid json",
+			document: &domain.Document{Name: "export.md", Data: []byte("# Conversation export")},
+			wantErr: "failed to import conversation: invalid conversation document: " +
+				"invalid character '#' looking for beginning of value",
+		},
+		{
+			name:     "unsupported version",
+			document: &domain.Document{Data: []byte(`{"version": 2, "turns": [{"author": "user", "prompt": "hi"}]}`)},
+			wantErr:  "failed to import conversation: unsupported export version 2",
+		},
+		{
+			name:     "unknown author",
+			document: &domain.Document{Data: []byte(`{"version": 1, "turns": [{"author": "tool", "prompt": "hi"}]}`)},
+			wantErr:  "failed to import conversation: turn 1 has unknown author \"tool\"",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			chat, err := NewChat(ChatParams{CacheDuration: time.Minute})
+			require.NoError(t, err)
+
+			ts := &mockTextSender{}
+			imp := NewChatImport(chat, ts, "/import")
+
+			_ = imp.Respond(t.Context(), time.Second, &domain.Message{ID: 1, ChatID: 1, Document: tc.document})
+
+			if tc.wantErr != "" {
+				require.Len(t, ts.notifyErrCalls, 1)
+				assert.EqualError(t, ts.notifyErrCalls[0], tc.wantErr)
+				_, ok := chat.cache.Load(int64(1))
+				assert.False(t, ok)
+				return
+			}
+
+			assert.Equal(t, []string{tc.wantReply}, ts.replyCalls)
+			conversation, err := chat.loadConversation(1)
+			require.NoError(t, err)
+			assert.Len(t, conversation.messages, tc.wantMessages)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type ChatImport struct {
+	chat       *Chat
+	textSender port.TextSender
+	command    string
+}
+
+func NewChatImport(chat *Chat, sender port.TextSender, command string) *ChatImport {
+	return &ChatImport{chat: chat, textSender: sender, command: command}
+}
+
+func (c *ChatImport) GetCommand() string {
+	return c.command
+}
+
+func (c *ChatImport) GetDescription() string {
+	return "Import an exported JSON conversation"
+}
+
+func (c *ChatImport) GetUsage() string {
+	return fmt.Sprintf("usage: reply to an exported JSON document with %s", c.command)
+}
+
+func (c *ChatImport) ReadsDocuments() bool {
+	return true
+}
+
+func (c *ChatImport) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	l := zerolog.Ctx(ctx)
+
+	if message.Document == nil {
+		return c.textSender.NotifyAndReturnError(ctx,
+			fmt.Errorf("reply to an exported JSON conversation with %s", c.command), message)
+	}
+
+	messages, err := parseConversationExport(message.Document.Data)
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to import conversation: %w", err), message)
+	}
+
+	conversation, err := c.chat.getConversationForMessage(message)
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to get conversation: %w", err), message)
+	}
+
+	conversation.messages = messages
+	conversation.lastReplyID = 0
+	c.chat.resetConversationTimer(conversation)
+	conversation.mu.Unlock()
+
+	l.Debug().Int("messages", len(messages)).Msg("imported conversation")
+
+	_, err = c.textSender.SendMessageReply(ctx, message,
+		fmt.Sprintf("imported conversation context with %d message%s", len(messages), plural(len(messages))))
+	if err != nil {
+		err = fmt.Errorf("error sending import response: %w", err)
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	return nil
+}
+
+// parseConversationExport decodes and validates a JSON conversation export into conversation messages.
+func parseConversationExport(data []byte) ([]domain.Prompt, error) {
+	var export conversationExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid conversation document: %w", err)
+	}
+
+	if export.Version != exportVersion {
+		return nil, fmt.Errorf("unsupported export version %d", export.Version)
+	}
+
+	if len(export.Turns) == 0 {
+		return nil, errors.New("conversation is empty")
+	}
+
+	messages := make([]domain.Prompt, 0, len(export.Turns))
+	for i, turn := range export.Turns {
+		if turn.Author != domain.User && turn.Author != domain.System {
+			return nil, fmt.Errorf("turn %d has unknown author %q", i+1, turn.Author)
+		}
+
+		messages = append(messages, domain.Prompt{
+			Author: turn.Author,
+			Prompt: turn.Prompt,
+			Model:  turn.Model,
+			Cost:   turn.Cost,
+		})
+	}
+
+	return messages, nil
+}
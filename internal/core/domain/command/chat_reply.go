@@ -0,0 +1,83 @@
+package command
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strings"
+	"time"
+)
+
+// ChatReply is a listener continuing the conversation of a Chat without the command prefix. It handles replies to
+// the bot, messages mentioning the bot and any text in private chats, unless disabled for a chat.
+type ChatReply struct {
+	chat     *Chat
+	identity domain.BotIdentity
+	settings port.AutoReplyStore
+}
+
+func NewChatReply(chat *Chat, identity domain.BotIdentity, settings port.AutoReplyStore) *ChatReply {
+	return &ChatReply{chat: chat, identity: identity, settings: settings}
+}
+
+func (c *ChatReply) Matches(message *domain.Message) bool {
+	if message.Text == "" || !c.IsEnabled(message.ChatID) {
+		return false
+	}
+
+	return message.IsReplyToBot || message.MentionsBot || message.IsPrivateChat
+}
+
+// BoundCommand returns the command of the chat, replies are subject to its config and permissions.
+func (c *ChatReply) BoundCommand() string {
+	return c.chat.GetCommand()
+}
+
+func (c *ChatReply) ReadsAudio() bool {
+	return c.chat.ReadsAudio()
+}
+
+func (c *ChatReply) Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+	if message.MentionsBot {
+		stripped := *message
+		stripped.Text = stripMention(message.Text, c.identity.Username)
+		message = &stripped
+	}
+
+	return c.chat.Respond(ctx, timeout, message)
+}
+
+// IsEnabled reports whether messages without command prefix are answered in a chat.
+func (c *ChatReply) IsEnabled(chatID int64) bool {
+	return !c.settings.IsAutoReplyDisabled(chatID)
+}
+
+// SetEnabled toggles answering messages without command prefix in a chat.
+func (c *ChatReply) SetEnabled(chatID int64, enabled bool) error {
+	return c.settings.SetAutoReplyDisabled(chatID, !enabled)
+}
+
+// stripMention removes the mentions of @username from a text, in any case. Like Telegram mentions, they may not be
+// part of a longer username or word, so @usernamex and foo@username.dev are kept.
+func stripMention(text, username string) string {
+	mention := "@" + username
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		end := i + len(mention)
+		if end <= len(text) && strings.EqualFold(text[i:end], mention) && (i == 0 || !isUsernameChar(text[i-1])) &&
+			(end == len(text) || !isUsernameChar(text[end])) {
+			i = end - 1
+			continue
+		}
+
+		b.WriteByte(text[i])
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+// isUsernameChar reports whether a byte may be part of a Telegram username.
+func isUsernameChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
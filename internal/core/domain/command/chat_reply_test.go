@@ -0,0 +1,147 @@
+package command
+
+import (
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockAutoReplyStore keeps the chats with disabled auto replies in memory.
+type mockAutoReplyStore struct {
+	disabled map[int64]bool
+}
+
+func (m *mockAutoReplyStore) IsAutoReplyDisabled(chatID int64) bool {
+	return m.disabled[chatID]
+}
+
+func (m *mockAutoReplyStore) SetAutoReplyDisabled(chatID int64, disabled bool) error {
+	if m.disabled == nil {
+		m.disabled = map[int64]bool{}
+	}
+	m.disabled[chatID] = disabled
+	return nil
+}
+
+func TestChatReply_Matches(t *testing.T) {
+	cr := NewChatReply(&Chat{}, domain.BotIdentity{Username: "hsbot"},
+		&mockAutoReplyStore{disabled: map[int64]bool{2: true}})
+
+	tests := []struct {
+		name    string
+		message *domain.Message
+		want    bool
+	}{
+		{
+			name:    "reply to bot",
+			message: &domain.Message{ChatID: 1, Text: "and then?", IsReplyToBot: true},
+			want:    true,
+		},
+		{
+			name:    "mention",
+			message: &domain.Message{ChatID: 1, Text: "@hsbot hi", MentionsBot: true},
+			want:    true,
+		},
+		{
+			name:    "private chat",
+			message: &domain.Message{ChatID: 1, Text: "hi", IsPrivateChat: true},
+			want:    true,
+		},
+		{
+			name:    "plain group message",
+			message: &domain.Message{ChatID: 1, Text: "hi"},
+			want:    false,
+		},
+		{
+			name:    "reply to bot without text",
+			message: &domain.Message{ChatID: 1, IsReplyToBot: true},
+			want:    false,
+		},
+		{
+			name:    "reply to bot in disabled chat",
+			message: &domain.Message{ChatID: 2, Text: "and then?", IsReplyToBot: true},
+			want:    false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, cr.Matches(tc.message))
+		})
+	}
+}
+
+func TestChatReply_RespondContinuesConversation(t *testing.T) {
+	ms := &MockTextSender{}
+	chat, _ := NewChat(ChatParams{
+		TextGenerator: &MockTextGenerator{response: "mock response"},
+		TextSender:    ms,
+		Transcriber:   &MockTranscriber{},
+		Command:       "/chat",
+		CacheDuration: time.Second * 3,
+		Track:         &MockTracker{withinLimit: true},
+	})
+
+	cr := NewChatReply(chat, domain.BotIdentity{Username: "hsbot"}, &mockAutoReplyStore{})
+
+	err := cr.Respond(t.Context(), time.Minute, &domain.Message{
+		ChatID: 1, ID: 1, Username: "@unit", Text: "plain prompt", IsPrivateChat: true})
+	require.NoError(t, err)
+
+	c, ok := chat.cache.Load(int64(1))
+	require.True(t, ok)
+
+	conversation, ok := c.(*Conversation)
+	require.True(t, ok)
+	assert.Equal(t, "@unit: plain prompt", conversation.messages[0].Prompt)
+	assert.Equal(t, "mock response", ms.Message)
+
+	err = cr.Respond(t.Context(), time.Minute, &domain.Message{
+		ChatID: 1, ID: 2, Username: "@unit", Text: "@HSBot what's up?", MentionsBot: true})
+	require.NoError(t, err)
+	assert.Equal(t, "@unit: what's up?", conversation.messages[2].Prompt)
+}
+
+func TestChatReplyToggle_Respond(t *testing.T) {
+	cr := NewChatReply(&Chat{command: "/chat"}, domain.BotIdentity{Username: "hsbot"}, &mockAutoReplyStore{})
+
+	sender := &mockTextSender{}
+	toggle := NewChatReplyToggle(cr, sender, "/autoreply")
+
+	require.NoError(t, toggle.Respond(t.Context(), time.Second, &domain.Message{ChatID: 1, Text: "/autoreply off"}))
+	assert.False(t, cr.IsEnabled(1))
+	assert.Equal(t, "replies without /chat are disabled in this chat", sender.replyCalls[0])
+
+	require.NoError(t, toggle.Respond(t.Context(), time.Second, &domain.Message{ChatID: 1, Text: "/autoreply"}))
+	assert.Equal(t, "replies without /chat are disabled in this chat", sender.replyCalls[1])
+
+	require.NoError(t, toggle.Respond(t.Context(), time.Second, &domain.Message{ChatID: 1, Text: "/autoreply on"}))
+	assert.True(t, cr.IsEnabled(1))
+	assert.Equal(t, "replies without /chat are enabled in this chat", sender.replyCalls[2])
+
+	require.NoError(t, toggle.Respond(t.Context(), time.Second, &domain.Message{ChatID: 1, Text: "/autoreply maybe"}))
+	assert.Len(t, sender.notifyErrCalls, 1)
+}
+
+func TestStripMention(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "leading mention", text: "@hsbot hi", want: "hi"},
+		{name: "mention in any case", text: "hi @HSBot, how are you?", want: "hi , how are you?"},
+		{name: "longer username", text: "@hsbotx hi", want: "@hsbotx hi"},
+		{name: "part of a word", text: "mail foo@hsbot.dev @hsbot", want: "mail foo@hsbot.dev"},
+		{name: "multi-byte text", text: "grüße @hsbot", want: "grüße"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, stripMention(tt.text, "hsbot"))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var chatReplyToggleArgs = ArgSpec{
+	Args: []Arg{{Name: "state", Help: "turn replies without command on or off", Choices: []string{"on", "off"}}},
+}
+
+type ChatReplyToggle struct {
+	chatReply  *ChatReply
+	textSender port.TextSender
+	command    string
+}
+
+func NewChatReplyToggle(chatReply *ChatReply, sender port.TextSender, command string) *ChatReplyToggle {
+	return &ChatReplyToggle{chatReply: chatReply, textSender: sender, command: command}
+}
+
+func (c *ChatReplyToggle) GetCommand() string {
+	return c.command
+}
+
+func (c *ChatReplyToggle) GetDescription() string {
+	return "Toggle chat replies without command"
+}
+
+func (c *ChatReplyToggle) GetUsage() string {
+	return chatReplyToggleArgs.Usage(c.command)
+}
+
+func (c *ChatReplyToggle) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	l := zerolog.Ctx(ctx)
+
+	args, err := chatReplyToggleArgs.Parse(c.command, message.Text)
+	if err != nil {
+		_ = c.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	if args.Has("state") {
+		err = c.chatReply.SetEnabled(message.ChatID, args.String("state") == "on")
+		if err != nil {
+			err = fmt.Errorf("failed to save auto reply setting: %w", err)
+			return c.textSender.NotifyAndReturnError(ctx, err, message)
+		}
+	}
+
+	state := "disabled"
+	if c.chatReply.IsEnabled(message.ChatID) {
+		state = "enabled"
+	}
+
+	l.Debug().Str("state", state).Msg("auto replies toggled")
+
+	_, err = c.textSender.SendMessageReply(ctx, message,
+		fmt.Sprintf("replies without %s are %s in this chat", c.chatReply.chat.GetCommand(), state))
+	if err != nil {
+		err = fmt.Errorf("error sending toggle response: %w", err)
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var retryArgs = ArgSpec{
+	Args: []Arg{{Name: "model", Help: "#keyword of the model to regenerate with"}},
+}
+
+type ChatRetry struct {
+	chat       *Chat
+	textSender port.TextSender
+	command    string
+}
+
+func NewChatRetry(chat *Chat, sender port.TextSender, command string) *ChatRetry {
+	return &ChatRetry{chat: chat, textSender: sender, command: command}
+}
+
+func (c *ChatRetry) GetCommand() string {
+	return c.command
+}
+
+func (c *ChatRetry) GetDescription() string {
+	return "Regenerate the last chat answer"
+}
+
+func (c *ChatRetry) GetUsage() string {
+	return retryArgs.Usage(c.command)
+}
+
+func (c *ChatRetry) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	l := zerolog.Ctx(ctx)
+
+	args, err := retryArgs.Parse(c.command, message.Text)
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	keyword, err := c.findKeyword(args.String("model"))
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	conversation, err := c.chat.loadConversation(message.ChatID)
+	if err != nil {
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	l.Debug().Str("keyword", keyword).Msg("regenerating last answer")
+
+	return c.chat.regenerate(ctx, conversation, message, anyReply, keyword)
+}
+
+// findKeyword returns the configured keyword of a model, given with or without the leading #.
+func (c *ChatRetry) findKeyword(model string) (string, error) {
+	if model == "" {
+		return "", nil
+	}
+
+	keyword := strings.TrimPrefix(model, "#")
+	if c.chat.modelProvider != nil {
+		for _, model := range c.chat.modelProvider.GetModels() {
+			if strings.EqualFold(model.Keyword, keyword) {
+				return model.Keyword, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("unknown model #%s, see the available models with /models", keyword)
+}
@@ -0,0 +1,99 @@
+package command
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type costRecordingTracker struct {
+	MockTracker
+	costs []float64
+}
+
+func (m *costRecordingTracker) AddCost(_ context.Context, entry domain.LedgerEntry) {
+	m.costs = append(m.costs, entry.Cost)
+}
+
+func (m *costRecordingTracker) CheckLimit(_ context.Context, _, _ int64, _ float64) bool {
+	return true
+}
+
+func TestChatRetry_Respond(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		seed        bool
+		wantMessage string
+		wantModel   string
+		wantCosts   []float64
+	}{
+		{
+			name:        "retry with same model",
+			text:        "/retry",
+			seed:        true,
+			wantMessage: "mock response",
+			wantCosts:   []float64{0.42, 0.42},
+		},
+		{
+			name:        "retry with other model",
+			text:        "/retry #Two",
+			seed:        true,
+			wantMessage: "mock response",
+			wantModel:   "two",
+			wantCosts:   []float64{0.42, 0.42},
+		},
+		{
+			name:        "unknown model",
+			text:        "/retry #three",
+			seed:        true,
+			wantMessage: "unknown model #three, see the available models with /models",
+			wantCosts:   []float64{0.42},
+		},
+		{
+			name:        "no conversation",
+			text:        "/retry",
+			wantMessage: "no conversation context",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ms := &MockTextSender{}
+			tracker := &costRecordingTracker{}
+
+			chat, err := NewChat(ChatParams{
+				TextGenerator: &MockTextGenerator{response: "mock response"},
+				TextSender:    ms,
+				Transcriber:   &MockTranscriber{},
+				Command:       "/chat",
+				CacheDuration: time.Second * 3,
+				Track:         tracker,
+				ModelProvider: &MockModelProvider{models: []domain.Model{{Keyword: "one"}, {Keyword: "two"}}},
+			})
+			require.NoError(t, err)
+
+			if tc.seed {
+				err = chat.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, ID: 1, Text: "/chat prompt"})
+				require.NoError(t, err)
+			}
+
+			retry := NewChatRetry(chat, ms, "/retry")
+			_ = retry.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, ID: 2, Text: tc.text})
+
+			assert.Equal(t, tc.wantMessage, ms.Message)
+			assert.Equal(t, tc.wantCosts, tracker.costs)
+
+			if tc.seed {
+				conversation, err := chat.loadConversation(1)
+				require.NoError(t, err)
+				require.Len(t, conversation.messages, 2)
+				assert.Equal(t, tc.wantModel, conversation.messages[0].Model)
+			}
+		})
+	}
+}
@@ -37,8 +37,10 @@ func (m *MockTextGenerator) ThinkFromPrompt(_ context.Context, _ domain.Prompt)
 }
 
 type MockTextSender struct {
-	err     error
-	Message string
+	err            error
+	Message        string
+	Keyboard       domain.Keyboard
+	CallbackAnswer string
 }
 
 func (m *MockTextSender) SendMessageReply(_ context.Context, _ *domain.Message, message string) (int, error) {
@@ -54,6 +56,27 @@ func (m *MockTextSender) NotifyAndReturnError(_ context.Context, err error, _ *d
 	return err
 }
 
+func (m *MockTextSender) SendMessageReplyWithKeyboard(ctx context.Context, message *domain.Message, text string,
+	keyboard domain.Keyboard) (int, error) {
+	m.Keyboard = keyboard
+	return m.SendMessageReply(ctx, message, text)
+}
+
+func (m *MockTextSender) EditMessageKeyboard(_ context.Context, _ int64, _ int, keyboard domain.Keyboard) error {
+	m.Keyboard = keyboard
+	return m.err
+}
+
+func (m *MockTextSender) AnswerCallbackQuery(_ context.Context, _ string, text string) error {
+	m.CallbackAnswer = text
+	return m.err
+}
+
+func (m *MockTextSender) EditMessageText(_ context.Context, _ int64, _ int, text string) error {
+	m.Message = text
+	return m.err
+}
+
 func (m *MockTextSender) SendChatAction(_ context.Context, _ int64, _ domain.Action) {}
 
 type MockTracker struct {
@@ -64,10 +87,26 @@ func (m MockTracker) GetSpent(_ int64) float64 {
 	return 0.0
 }
 
-func (m MockTracker) AddCost(_ int64, _ float64) {
+func (m MockTracker) GetUserSpent(_, _ int64) float64 {
+	return 0.0
+}
+
+func (m MockTracker) AddCost(_ context.Context, _ domain.LedgerEntry) {
+}
+
+func (m MockTracker) WindowStart(_ string) time.Time {
+	return time.Time{}
+}
+
+func (m MockTracker) CheckLimit(_ context.Context, _, _ int64, _ float64) bool {
+	return m.withinLimit
+}
+
+func (m MockTracker) CheckCost(_ context.Context, _, _ int64, _, _ float64) bool {
+	return m.withinLimit
 }
 
-func (m MockTracker) CheckLimit(_ context.Context, _ int64) bool {
+func (m MockTracker) AllowsCost(_, _ int64, _, _ float64) bool {
 	return m.withinLimit
 }
 
@@ -0,0 +1,114 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var undoArgs = ArgSpec{
+	Args: []Arg{{Name: "n", Type: ArgInt, Help: "exchanges to remove", Default: "1", Min: 1, Max: 1000}},
+}
+
+type ChatUndo struct {
+	chat       *Chat
+	textSender port.TextSender
+	command    string
+}
+
+func NewChatUndo(chat *Chat, sender port.TextSender, command string) *ChatUndo {
+	return &ChatUndo{chat: chat, textSender: sender, command: command}
+}
+
+func (c *ChatUndo) GetCommand() string {
+	return c.command
+}
+
+func (c *ChatUndo) GetDescription() string {
+	return "Remove the last chat exchanges"
+}
+
+func (c *ChatUndo) GetUsage() string {
+	return undoArgs.Usage(c.command)
+}
+
+func (c *ChatUndo) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	l := zerolog.Ctx(ctx)
+
+	args, err := undoArgs.Parse(c.command, message.Text)
+	if err != nil {
+		_ = c.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	conv, ok := c.chat.cache.Load(message.ChatID)
+	if !ok {
+		l.Debug().Msg("no conversation in cache")
+
+		_, err := c.textSender.SendMessageReply(ctx, message, "no conversation context")
+		if err != nil {
+			err = fmt.Errorf("error sending undo response: %w", err)
+			return c.textSender.NotifyAndReturnError(ctx, err, message)
+		}
+
+		return nil
+	}
+
+	conversation, ok := conv.(*Conversation)
+	if !ok {
+		return errors.New("conversation type error")
+	}
+
+	conversation.mu.Lock()
+	var removed int
+	conversation.messages, removed = removeExchanges(conversation.messages, args.Int("n"))
+	// the answer buttons of removed exchanges must not regenerate the remaining context
+	conversation.lastReplyID = 0
+	left := len(conversation.messages)
+	conversation.mu.Unlock()
+
+	l.Debug().Int("removed", removed).Int("left", left).Msg("removed exchanges from conversation")
+
+	_, err = c.textSender.SendMessageReply(ctx, message,
+		fmt.Sprintf("removed %d exchange%s, %d message%s left", removed, plural(removed), left, plural(left)))
+	if err != nil {
+		err = fmt.Errorf("error sending undo response: %w", err)
+		return c.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	return nil
+}
+
+// removeExchanges drops up to n trailing exchanges, each being the user prompts followed by the answer to them.
+// It returns the remaining messages and the number of removed exchanges.
+func removeExchanges(messages []domain.Prompt, n int) ([]domain.Prompt, int) {
+	var removed int
+
+	for removed < n && len(messages) > 0 {
+		end := len(messages)
+		if messages[end-1].Author == domain.System {
+			end--
+		}
+
+		for end > 0 && messages[end-1].Author == domain.User {
+			end--
+		}
+
+		messages = messages[:end]
+		removed++
+	}
+
+	return messages, removed
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return ""
+	}
+	return "s"
+}
@@ -0,0 +1,100 @@
+package command
+
+import (
+	"hsbot/internal/core/domain"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatUndo_Respond(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		seed         bool
+		wantReply    string
+		wantMessages int
+	}{
+		{
+			name:         "undo last exchange",
+			text:         "/undo",
+			seed:         true,
+			wantReply:    "removed 1 exchange, 2 messages left",
+			wantMessages: 2,
+		},
+		{
+			name:         "undo more exchanges than available",
+			text:         "/undo 5",
+			seed:         true,
+			wantReply:    "removed 2 exchanges, 0 messages left",
+			wantMessages: 0,
+		},
+		{
+			name:         "invalid count",
+			text:         "/undo foo",
+			seed:         true,
+			wantMessages: 5,
+		},
+		{
+			name:      "no conversation",
+			text:      "/undo",
+			wantReply: "no conversation context",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			chat := &Chat{cache: new(sync.Map)}
+			conversation := &Conversation{
+				lastReplyID: 42,
+				messages: []domain.Prompt{
+					{Author: domain.User, Prompt: "@alice: first"},
+					{Author: domain.System, Prompt: "first answer"},
+					{Author: domain.User, Prompt: "@bob: quoted"},
+					{Author: domain.User, Prompt: "@alice: second"},
+					{Author: domain.System, Prompt: "second answer"},
+				},
+			}
+			if tc.seed {
+				chat.cache.Store(int64(1), conversation)
+			}
+
+			sender := &mockTextSender{}
+			undo := NewChatUndo(chat, sender, "/undo")
+
+			err := undo.Respond(t.Context(), time.Second, &domain.Message{ID: 1, ChatID: 1, Text: tc.text})
+			require.NoError(t, err)
+
+			if tc.wantReply == "" {
+				assert.Empty(t, sender.replyCalls)
+				assert.Len(t, sender.notifyErrCalls, 1)
+			} else {
+				require.Len(t, sender.replyCalls, 1)
+				assert.Equal(t, tc.wantReply, sender.replyCalls[0])
+			}
+
+			if tc.seed {
+				assert.Len(t, conversation.messages, tc.wantMessages)
+			}
+		})
+	}
+}
+
+func Test_removeExchanges(t *testing.T) {
+	messages := []domain.Prompt{
+		{Author: domain.User, Prompt: "first"},
+		{Author: domain.System, Prompt: "first answer"},
+		{Author: domain.User, Prompt: "unanswered"},
+	}
+
+	remaining, removed := removeExchanges(messages, 1)
+	assert.Equal(t, 1, removed)
+	assert.Equal(t, messages[:2], remaining)
+
+	remaining, removed = removeExchanges(messages, 2)
+	assert.Equal(t, 2, removed)
+	assert.Empty(t, remaining)
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strings"
+	"time"
+)
+
+// Chats lists the allowed and invited chats. Register it with the AdminOnly middleware.
+type Chats struct {
+	chats      port.ChatStore
+	invites    port.InviteStore
+	textSender port.TextSender
+	command    string
+}
+
+func NewChats(chats port.ChatStore, invites port.InviteStore, sender port.TextSender, command string) *Chats {
+	return &Chats{chats: chats, invites: invites, textSender: sender, command: command}
+}
+
+func (c *Chats) IsAdminOnly() bool {
+	return true
+}
+
+func (c *Chats) GetCommand() string {
+	return c.command
+}
+
+func (c *Chats) GetDescription() string {
+	return "List the allowed chats (admins only)"
+}
+
+func (c *Chats) GetUsage() string {
+	return "usage: " + c.command
+}
+
+func (c *Chats) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	_, err := c.textSender.SendMessageReply(ctx, message, listChats(c.chats.AllowedChats(), c.invites.InvitedChats()))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func listChats(allowed, invited []int64) string {
+	if len(allowed) == 0 && len(invited) == 0 {
+		return "no chats are allowed"
+	}
+
+	var sections []string
+	if len(allowed) > 0 {
+		sections = append(sections, listChatSection("allowed", allowed))
+	}
+	if len(invited) > 0 {
+		sections = append(sections, listChatSection("invited", invited))
+	}
+
+	return strings.Join(sections, "\n\n")
+}
+
+func listChatSection(kind string, chatIDs []int64) string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "%d %s chat%s:", len(chatIDs), kind, plural(len(chatIDs)))
+	for _, chatID := range chatIDs {
+		fmt.Fprintf(&sb, "\n%d", chatID)
+	}
+
+	return sb.String()
+}
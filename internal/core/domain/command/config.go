@@ -0,0 +1,50 @@
+package command
+
+import (
+	"fmt"
+	"hsbot/internal/core/domain"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaultRateInterval is the interval of rate limits configured without one.
+const defaultRateInterval = time.Minute
+
+// LoadCommandConfig reads the [commands.<name>] config section of a command, named without the leading slash.
+// Commands without section are enabled in every chat with the default timeout.
+func LoadCommandConfig(command string) (domain.CommandConfig, error) {
+	key := "commands." + strings.TrimPrefix(command, "/")
+
+	config := domain.CommandConfig{
+		Disabled: viper.IsSet(key+".enabled") && !viper.GetBool(key+".enabled"),
+		Timeout:  viper.GetDuration(key + ".timeout"),
+	}
+
+	for _, alias := range viper.GetStringSlice(key + ".aliases") {
+		config.Aliases = append(config.Aliases, "/"+strings.ToLower(strings.TrimPrefix(alias, "/")))
+	}
+
+	err := viper.UnmarshalKey(key+".chat_ids", &config.ChatIDs)
+	if err != nil {
+		return domain.CommandConfig{}, fmt.Errorf("invalid chat IDs of %s: %w", command, err)
+	}
+
+	if viper.IsSet(key + ".rate_limit") {
+		config.RateLimit = domain.RateLimit{
+			Requests: viper.GetInt(key + ".rate_limit"),
+			Interval: defaultRateInterval,
+		}
+
+		if viper.IsSet(key + ".rate_interval") {
+			config.RateLimit.Interval = viper.GetDuration(key + ".rate_interval")
+		}
+
+		if !config.RateLimit.IsSet() {
+			return domain.CommandConfig{}, fmt.Errorf("invalid rate limit of %s", command)
+		}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,140 @@
+package command
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const confirmNamespace = "confirm"
+
+const (
+	callbackConfirm = "yes"
+	callbackCancel  = "no"
+)
+
+// confirmationTTL is how long a request waits for its confirmation.
+const confirmationTTL = 10 * time.Minute
+
+// Confirmations holds back expensive requests until the requesting user confirms them with an inline button.
+type Confirmations struct {
+	textSender port.TextSender
+	threshold  float64
+	pending    map[string]pendingRequest
+	mu         sync.Mutex
+	now        func() time.Time
+}
+
+// pendingRequest is a request waiting for the confirmation of its user.
+type pendingRequest struct {
+	userID  int64
+	cost    float64
+	run     func(ctx context.Context) error
+	expires time.Time
+}
+
+// NewConfirmations asks for a confirmation of requests estimated to cost more than telegram.confirm_cost dollars,
+// never if it is zero.
+func NewConfirmations(textSender port.TextSender) *Confirmations {
+	return &Confirmations{
+		textSender: textSender,
+		threshold:  viper.GetFloat64("telegram.confirm_cost"),
+		pending:    make(map[string]pendingRequest),
+		now:        time.Now,
+	}
+}
+
+// Required reports whether a request of the estimated cost needs a confirmation.
+func (c *Confirmations) Required(cost float64) bool {
+	return c.threshold > 0 && cost > c.threshold
+}
+
+const confirmQuestion = "This request will cost about $%.2f. Do you want to continue?"
+
+// Ask replies to the message with confirm and cancel buttons. The request is run once its user confirms it.
+func (c *Confirmations) Ask(ctx context.Context, message *domain.Message, cost float64,
+	run func(ctx context.Context) error) error {
+	id := newConfirmationID()
+
+	c.mu.Lock()
+	now := c.now()
+	for key, request := range c.pending {
+		if now.After(request.expires) {
+			delete(c.pending, key)
+		}
+	}
+	c.pending[id] = pendingRequest{userID: message.UserID, cost: cost, run: run, expires: now.Add(confirmationTTL)}
+	c.mu.Unlock()
+
+	keyboard := domain.Keyboard{{
+		{Text: fmt.Sprintf("✅ Spend $%.2f", cost), Data: CallbackData(confirmNamespace, callbackConfirm, id)},
+		{Text: "❌ Cancel", Data: CallbackData(confirmNamespace, callbackCancel, id)},
+	}}
+
+	_, err := c.textSender.SendMessageReplyWithKeyboard(ctx, message, fmt.Sprintf(confirmQuestion, cost), keyboard)
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return c.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to ask for confirmation: %w", err), message)
+	}
+
+	return nil
+}
+
+func (c *Confirmations) GetCallbackNamespace() string {
+	return confirmNamespace
+}
+
+func (c *Confirmations) HandleCallback(ctx context.Context, _ time.Duration, callback *domain.Callback) error {
+	action, id, _ := strings.Cut(callback.Data, ":")
+
+	c.mu.Lock()
+	request, ok := c.pending[id]
+	if ok && request.userID != callback.UserID {
+		c.mu.Unlock()
+		return c.textSender.AnswerCallbackQuery(ctx, callback.ID, "only the requesting user can decide")
+	}
+	delete(c.pending, id)
+	c.mu.Unlock()
+
+	if !ok || c.now().After(request.expires) {
+		return c.textSender.AnswerCallbackQuery(ctx, callback.ID, "this request expired")
+	}
+
+	text := "Cancelled."
+	if action == callbackConfirm {
+		text = fmt.Sprintf("Confirmed, spending about $%.2f.", request.cost)
+	}
+
+	err := c.textSender.EditMessageText(ctx, callback.ChatID, callback.Message.ID, text)
+	if err != nil {
+		log.Warn().Err(err).Int64("chatId", callback.ChatID).Msg("failed to update confirmation")
+	}
+
+	err = c.textSender.AnswerCallbackQuery(ctx, callback.ID, "")
+	if err != nil {
+		log.Warn().Err(err).Int64("chatId", callback.ChatID).Msg("failed to answer confirmation")
+	}
+
+	if action != callbackConfirm {
+		return nil
+	}
+
+	return request.run(ctx)
+}
+
+func newConfirmationID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
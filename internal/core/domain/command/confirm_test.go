@@ -0,0 +1,97 @@
+package command
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmations_Required(t *testing.T) {
+	c := &Confirmations{threshold: 0.1}
+	assert.False(t, c.Required(0.1))
+	assert.True(t, c.Required(0.11))
+
+	never := &Confirmations{}
+	assert.False(t, never.Required(100))
+}
+
+func TestConfirmations_HandleCallback(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		action      string
+		userID      int64
+		age         time.Duration
+		wantRun     bool
+		wantPending bool
+		wantAnswer  string
+		wantMessage string
+	}{
+		{
+			name:        "confirm",
+			action:      callbackConfirm,
+			userID:      7,
+			wantRun:     true,
+			wantMessage: "Confirmed, spending about $0.50.",
+		},
+		{
+			name:        "cancel",
+			action:      callbackCancel,
+			userID:      7,
+			wantMessage: "Cancelled.",
+		},
+		{
+			name:        "other user",
+			action:      callbackConfirm,
+			userID:      8,
+			wantPending: true,
+			wantAnswer:  "only the requesting user can decide",
+			wantMessage: "This request will cost about $0.50. Do you want to continue?",
+		},
+		{
+			name:        "expired",
+			action:      callbackConfirm,
+			userID:      7,
+			age:         confirmationTTL + time.Second,
+			wantAnswer:  "this request expired",
+			wantMessage: "This request will cost about $0.50. Do you want to continue?",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := &MockTextSender{}
+			clock := now
+			c := NewConfirmations(ts)
+			c.now = func() time.Time { return clock }
+
+			run := false
+			err := c.Ask(t.Context(), &domain.Message{ChatID: 1, UserID: 7}, 0.5, func(context.Context) error {
+				run = true
+				return nil
+			})
+			require.NoError(t, err)
+			require.Len(t, ts.Keyboard, 1)
+			require.Len(t, ts.Keyboard[0], 2)
+
+			_, payload := ParseCallbackData(ts.Keyboard[0][0].Data)
+			_, id, _ := strings.Cut(payload, ":")
+
+			clock = clock.Add(tc.age)
+			err = c.HandleCallback(t.Context(), time.Second, &domain.Callback{ChatID: 1, UserID: tc.userID,
+				Data: tc.action + ":" + id, Message: &domain.Message{ID: 2}})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantRun, run)
+			assert.Equal(t, tc.wantAnswer, ts.CallbackAnswer)
+			assert.Equal(t, tc.wantMessage, ts.Message)
+			assert.Equal(t, tc.wantPending, len(c.pending) == 1)
+		})
+	}
+}
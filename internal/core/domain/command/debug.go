@@ -26,6 +26,14 @@ func (d *Debug) GetCommand() string {
 	return d.command
 }
 
+func (d *Debug) GetDescription() string {
+	return "Show runtime debug information"
+}
+
+func (d *Debug) GetUsage() string {
+	return "usage: " + d.command
+}
+
 const kb = 1024
 const debugTemplate = `allocated mem: %d KB
 threads running: %d
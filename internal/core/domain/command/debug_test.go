@@ -15,6 +15,26 @@ type MockSender struct {
 	mock.Mock
 }
 
+func (m *MockSender) SendMessageReplyWithKeyboard(ctx context.Context, message *domain.Message, text string,
+	_ domain.Keyboard) (int, error) {
+	return m.SendMessageReply(ctx, message, text)
+}
+
+func (m *MockSender) EditMessageKeyboard(_ context.Context, _ int64, _ int, _ domain.Keyboard) error {
+	// mocked
+	return nil
+}
+
+func (m *MockSender) AnswerCallbackQuery(_ context.Context, _ string, _ string) error {
+	// mocked
+	return nil
+}
+
+func (m *MockSender) EditMessageText(_ context.Context, _ int64, _ int, _ string) error {
+	// mocked
+	return nil
+}
+
 func (m *MockSender) SendChatAction(_ context.Context, _ int64, _ domain.Action) {
 	// mocked
 }
@@ -0,0 +1,72 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Deny removes a chat from the allowlist and revokes its invites. Register it with the AdminOnly middleware.
+type Deny struct {
+	chats      port.ChatStore
+	invites    port.InviteStore
+	textSender port.TextSender
+	command    string
+}
+
+func NewDeny(chats port.ChatStore, invites port.InviteStore, sender port.TextSender, command string) *Deny {
+	return &Deny{chats: chats, invites: invites, textSender: sender, command: command}
+}
+
+func (d *Deny) IsAdminOnly() bool {
+	return true
+}
+
+func (d *Deny) GetCommand() string {
+	return d.command
+}
+
+func (d *Deny) GetDescription() string {
+	return "Remove a chat from the allowed chats (admins only)"
+}
+
+func (d *Deny) GetUsage() string {
+	return chatIDArgs.Usage(d.command)
+}
+
+func (d *Deny) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	args, err := chatIDArgs.Parse(d.command, message.Text)
+	if err != nil {
+		_ = d.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	chatID := int64(args.Int("chatID"))
+
+	removed, err := d.chats.DenyChat(chatID)
+	if err != nil {
+		return d.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to deny chat: %w", err), message)
+	}
+
+	revoked, err := d.invites.RevokeChat(chatID)
+	if err != nil {
+		return d.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to revoke invites: %w", err), message)
+	}
+
+	reply := fmt.Sprintf("chat %d is not allowed", chatID)
+	if removed || revoked {
+		zerolog.Ctx(ctx).Info().Int64("deniedChatId", chatID).Msg("denied chat")
+		reply = fmt.Sprintf("chat %d is no longer allowed", chatID)
+	}
+
+	_, err = d.textSender.SendMessageReply(ctx, message, reply)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
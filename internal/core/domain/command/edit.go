@@ -10,8 +10,6 @@ import (
 	"time"
 
 	"github.com/spf13/viper"
-
-	"github.com/rs/zerolog/log"
 )
 
 type Edit struct {
@@ -20,6 +18,7 @@ type Edit struct {
 	textSender     port.TextSender
 	track          service.Tracker
 	cost           float64
+	model          string
 	command        string
 }
 
@@ -32,6 +31,7 @@ func NewEdit(imageGenerator port.ImageGenerator,
 		imageSender: imageSender,
 		textSender:  textSender,
 		cost:        viper.GetFloat64("fal.image_edit_cost"),
+		model:       viper.GetString("fal.image_edit_model"),
 		track:       track,
 		command:     command}
 }
@@ -40,26 +40,20 @@ func (e *Edit) GetCommand() string {
 	return e.command
 }
 
-func (e *Edit) Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error {
-	l := log.With().
-		Int("messageId", message.ID).
-		Int64("chatId", message.ChatID).
-		Str("imageURL", message.ImageURL).
-		Str("command", e.GetCommand()).
-		Logger()
-
-	l.Info().Msg("handling request")
-
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+func (e *Edit) GetDescription() string {
+	return "Edit an image with a prompt"
+}
 
-	if !e.track.CheckLimit(ctx, message.ChatID) {
-		l.Debug().Msg("spending limit reached")
-		return nil
-	}
+func (e *Edit) GetUsage() string {
+	return fmt.Sprintf("usage: reply to an image with %s <prompt>", e.command)
+}
 
-	go e.textSender.SendChatAction(ctx, message.ChatID, domain.SendingPhoto)
+// EstimateCost returns the fixed price of the edited image.
+func (e *Edit) EstimateCost(_ context.Context, _ *domain.Message) float64 {
+	return e.cost
+}
 
+func (e *Edit) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
 	prompt := ParseCommandArgs(message.Text)
 	if prompt == "" {
 		_ = e.textSender.NotifyAndReturnError(ctx, errors.New("empty prompt"), message)
@@ -71,13 +65,19 @@ func (e *Edit) Respond(ctx context.Context, timeout time.Duration, message *doma
 		return nil
 	}
 
+	if role := domain.RoleFromContext(ctx); !role.AllowsModel(e.model) {
+		_ = e.textSender.NotifyAndReturnError(ctx, &domain.ModelNotAllowedError{Model: e.model, Role: role.Name}, message)
+		return nil
+	}
+
 	imageURL, err := e.imageGenerator.EditFromPrompt(ctx, domain.Prompt{Prompt: prompt, ImageURL: message.ImageURL})
 	if err != nil {
 		err = fmt.Errorf("error creating edited image: %w", err)
 		return e.textSender.NotifyAndReturnError(ctx, err, message)
 	}
 
-	e.track.AddCost(message.ChatID, e.cost)
+	e.track.AddCost(ctx, domain.LedgerEntry{ChatID: message.ChatID, UserID: message.UserID, Username: message.Username,
+		Command: e.command, Model: e.model, Cost: e.cost})
 
 	err = e.imageSender.SendImageURLReply(ctx, message, imageURL)
 	if err != nil {
@@ -0,0 +1,92 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"hsbot/internal/core/service"
+	"strings"
+	"time"
+)
+
+var helpArgs = ArgSpec{
+	Args: []Arg{{Name: "command", Help: "command to show the usage of"}},
+}
+
+type Help struct {
+	registry   port.CommandRegistry
+	auth       service.Authorizer
+	textSender port.TextSender
+	command    string
+}
+
+// NewHelp lists the commands available in a chat, the commands of admins only to admins.
+func NewHelp(registry port.CommandRegistry, auth service.Authorizer, sender port.TextSender, command string) *Help {
+	return &Help{registry: registry, auth: auth, textSender: sender, command: command}
+}
+
+func (h *Help) GetCommand() string {
+	return h.command
+}
+
+func (h *Help) GetDescription() string {
+	return "List the available commands or show the usage of one"
+}
+
+func (h *Help) GetUsage() string {
+	return helpArgs.Usage(h.command)
+}
+
+func (h *Help) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	args, err := helpArgs.Parse(h.command, message.Text)
+	if err != nil {
+		_ = h.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	commands := h.registry.DescribeCommands(message.ChatID, h.auth.IsAdmin(message.UserID))
+
+	var text string
+	if args.Has("command") {
+		text, err = commandHelp(commands, args.String("command"))
+		if err != nil {
+			return h.textSender.NotifyAndReturnError(ctx, err, message)
+		}
+	} else {
+		text = commandList(commands, h.command)
+	}
+
+	_, err = h.textSender.SendMessageReply(ctx, message, text)
+	if err != nil {
+		err = fmt.Errorf("error sending help response: %w", err)
+		return h.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	return nil
+}
+
+func commandList(commands []domain.CommandInfo, helpCommand string) string {
+	sb := &strings.Builder{}
+
+	sb.WriteString("available commands:\n")
+	for _, command := range commands {
+		sb.WriteString(command.Command + " - " + command.Description + "\n")
+	}
+
+	sb.WriteString("\nuse " + helpCommand + " <command> for details")
+
+	return sb.String()
+}
+
+func commandHelp(commands []domain.CommandInfo, name string) (string, error) {
+	name = "/" + strings.TrimPrefix(strings.ToLower(name), "/")
+
+	for _, command := range commands {
+		if command.Command == name {
+			return command.Command + " - " + command.Description + "\n\n" + command.Usage, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown command %s", name)
+}
@@ -0,0 +1,79 @@
+package command
+
+import (
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHelp_Respond(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		userID    int64
+		wantReply string
+		wantErr   string
+	}{
+		{
+			name: "list commands",
+			text: "/help",
+			wantReply: "available commands:\n/help - List the available commands or show the usage of one\n" +
+				"/scale - description of /scale\n\nuse /help <command> for details",
+		},
+		{
+			name:   "list commands to admins",
+			text:   "/help",
+			userID: 7,
+			wantReply: "available commands:\n/allow - description of /allow\n" +
+				"/help - List the available commands or show the usage of one\n" +
+				"/scale - description of /scale\n\nuse /help <command> for details",
+		},
+		{
+			name:    "admin command usage",
+			text:    "/help allow",
+			wantErr: "unknown command /allow",
+		},
+		{
+			name:      "command usage",
+			text:      "/help Scale",
+			wantReply: "/scale - description of /scale\n\nusage: /scale",
+		},
+		{
+			name:      "command usage with slash",
+			text:      "/help /scale",
+			wantReply: "/scale - description of /scale\n\nusage: /scale",
+		},
+		{
+			name:    "unknown command",
+			text:    "/help foo",
+			wantErr: "unknown command /foo",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			registry := &Registry{}
+			sender := &mockTextSender{}
+			registry.Register(NewHelp(registry, mockAuthorizer{adminID: 7}, sender, "/help"))
+			registry.Register(&MockDescribedResponder{MockResponder: MockResponder{command: "/scale"}})
+			registry.Register(&MockDescribedResponder{MockResponder: MockResponder{command: "/allow"}, adminOnly: true})
+
+			help, err := registry.Get("/help")
+			require.NoError(t, err)
+
+			_ = help.Respond(t.Context(), time.Second, &domain.Message{ID: 1, ChatID: 1, UserID: tc.userID,
+				Text: tc.text})
+
+			if tc.wantErr != "" {
+				require.Len(t, sender.notifyErrCalls, 1)
+				assert.EqualError(t, sender.notifyErrCalls[0], tc.wantErr)
+				return
+			}
+
+			assert.Equal(t, []string{tc.wantReply}, sender.replyCalls)
+		})
+	}
+}
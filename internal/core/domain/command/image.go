@@ -10,8 +10,6 @@ import (
 	"time"
 
 	"github.com/spf13/viper"
-
-	"github.com/rs/zerolog/log"
 )
 
 type Image struct {
@@ -20,6 +18,7 @@ type Image struct {
 	textSender     port.TextSender
 	track          service.Tracker
 	cost           float64
+	model          string
 	command        string
 }
 
@@ -32,6 +31,7 @@ func NewImage(imageGenerator port.ImageGenerator,
 		imageSender: imageSender,
 		textSender:  textSender,
 		cost:        viper.GetFloat64("fal.image_gen_cost"),
+		model:       viper.GetString("fal.image_gen_model"),
 		track:       track,
 		command:     command}
 }
@@ -40,39 +40,39 @@ func (i *Image) GetCommand() string {
 	return i.command
 }
 
-func (i *Image) Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error {
-	l := log.With().
-		Int("messageId", message.ID).
-		Int64("chatId", message.ChatID).
-		Str("imageURL", message.ImageURL).
-		Str("command", i.GetCommand()).
-		Logger()
-
-	l.Info().Msg("handling request")
-
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+func (i *Image) GetDescription() string {
+	return "Generate an image from a prompt"
+}
 
-	if !i.track.CheckLimit(ctx, message.ChatID) {
-		l.Debug().Msg("spending limit reached")
-		return nil
-	}
+func (i *Image) GetUsage() string {
+	return fmt.Sprintf("usage: %s <prompt>", i.command)
+}
 
-	go i.textSender.SendChatAction(ctx, message.ChatID, domain.SendingPhoto)
+// EstimateCost returns the fixed price of the generated image.
+func (i *Image) EstimateCost(_ context.Context, _ *domain.Message) float64 {
+	return i.cost
+}
 
+func (i *Image) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
 	prompt := ParseCommandArgs(message.Text)
 	if prompt == "" {
 		_ = i.textSender.NotifyAndReturnError(ctx, errors.New("missing image prompt"), message)
 		return nil
 	}
 
+	if role := domain.RoleFromContext(ctx); !role.AllowsModel(i.model) {
+		_ = i.textSender.NotifyAndReturnError(ctx, &domain.ModelNotAllowedError{Model: i.model, Role: role.Name}, message)
+		return nil
+	}
+
 	imageURL, err := i.imageGenerator.GenerateFromPrompt(ctx, prompt)
 	if err != nil {
 		err = fmt.Errorf("error generating image: %w", err)
 		return i.textSender.NotifyAndReturnError(ctx, err, message)
 	}
 
-	i.track.AddCost(message.ChatID, i.cost)
+	i.track.AddCost(ctx, domain.LedgerEntry{ChatID: message.ChatID, UserID: message.UserID, Username: message.Username,
+		Command: i.command, Model: i.model, Cost: i.cost})
 
 	err = i.imageSender.SendImageURLReply(ctx, message, imageURL)
 	if err != nil {
@@ -84,6 +84,22 @@ func TestImageRepondSendFailed(t *testing.T) {
 	require.Equal(t, "error sending edited image: mock error", mt.Message)
 }
 
+func TestImageRespondModelNotAllowed(t *testing.T) {
+	mg := &MockImageGenerator{response: "https://example.org/image.png"}
+	mi := &MockImageSender{}
+	mt := &MockTextSender{}
+
+	imageHandler := NewImage(mg, mi, mt, &MockTracker{withinLimit: true}, "/image")
+	imageHandler.model = "imagen3"
+
+	ctx := domain.WithRole(t.Context(), domain.Role{Name: domain.RoleGuest, Models: []string{"gpt"}})
+	err := imageHandler.Respond(ctx, time.Minute, &domain.Message{ChatID: 1, ID: 1, Text: "/image prompt"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "the model imagen3 is not available to guests, see /models", mt.Message)
+	assert.False(t, mi.called)
+}
+
 func TestImageRepondErrorEmptyPrompt(t *testing.T) {
 	mg := &MockImageGenerator{}
 	mi := &MockImageSender{}
@@ -0,0 +1,133 @@
+package command
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+var inviteArgs = ArgSpec{
+	Args: []Arg{
+		{Name: "uses", Type: ArgInt, Help: "number of chats that can redeem the code", Default: "1", Min: 1, Max: 100},
+		{Name: "expiry", Type: ArgString, Help: "validity of the code, like 12h or 7d", Default: "7d"},
+	},
+}
+
+// Invite mints invite codes, which authorize the chats opening the bot with them. Register it with the AdminOnly
+// middleware.
+type Invite struct {
+	invites     port.InviteStore
+	textSender  port.TextSender
+	botUsername string
+	command     string
+	now         func() time.Time
+}
+
+func NewInvite(invites port.InviteStore, sender port.TextSender, botUsername, command string) *Invite {
+	return &Invite{
+		invites:     invites,
+		textSender:  sender,
+		botUsername: botUsername,
+		command:     command,
+		now:         time.Now,
+	}
+}
+
+func (i *Invite) IsAdminOnly() bool {
+	return true
+}
+
+func (i *Invite) GetCommand() string {
+	return i.command
+}
+
+func (i *Invite) GetDescription() string {
+	return "Create an invite code for new chats (admins only)"
+}
+
+func (i *Invite) GetUsage() string {
+	return inviteArgs.Usage(i.command)
+}
+
+func (i *Invite) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	args, err := inviteArgs.Parse(i.command, message.Text)
+	if err != nil {
+		_ = i.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	expiry, err := parseExpiry(args.String("expiry"))
+	if err != nil {
+		_ = i.textSender.NotifyAndReturnError(ctx, &UsageError{Err: err, Usage: i.GetUsage()}, message)
+		return nil
+	}
+
+	now := i.now()
+	invite := domain.Invite{
+		Code:      newInviteCode(),
+		CreatedBy: message.UserID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(expiry),
+		Uses:      args.Int("uses"),
+	}
+
+	err = i.invites.CreateInvite(invite)
+	if err != nil {
+		return i.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to create invite: %w", err), message)
+	}
+
+	zerolog.Ctx(ctx).Info().Str("code", invite.Code).Int("uses", invite.Uses).Time("expiresAt", invite.ExpiresAt).
+		Msg("created invite")
+
+	_, err = i.textSender.SendMessageReply(ctx, message, fmt.Sprintf(
+		"Invite code %s for %d chat%s, valid until %s\nPrivate chat: https://t.me/%s?start=%s\n"+
+			"Group: https://t.me/%s?startgroup=%s",
+		invite.Code, invite.Uses, plural(invite.Uses), invite.ExpiresAt.Format("2006-01-02 15:04 MST"),
+		i.botUsername, invite.Code, i.botUsername, invite.Code))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// parseExpiry parses a duration, which additionally may be given in days, like 7d.
+func parseExpiry(value string) (time.Duration, error) {
+	var expiry time.Duration
+
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid expiry: %q is not a duration", value)
+		}
+		expiry = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		expiry, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid expiry: %q is not a duration", value)
+		}
+	}
+
+	if expiry <= 0 {
+		return 0, errors.New("invalid expiry: must be positive")
+	}
+
+	return expiry, nil
+}
+
+// newInviteCode returns a random code, using only characters allowed in telegram deep links.
+func newInviteCode() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
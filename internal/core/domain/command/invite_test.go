@@ -0,0 +1,115 @@
+package command
+
+import (
+	"errors"
+	"hsbot/internal/core/domain"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockInviteStore struct {
+	invites []domain.Invite
+	invited []int64
+	err     error
+}
+
+func (m *mockInviteStore) CreateInvite(invite domain.Invite) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.invites = append(m.invites, invite)
+	return nil
+}
+
+func (m *mockInviteStore) RedeemInvite(code string, redemption domain.Redemption) (domain.Invite, error) {
+	if m.err != nil {
+		return domain.Invite{}, m.err
+	}
+
+	i := slices.IndexFunc(m.invites, func(invite domain.Invite) bool { return invite.Code == code })
+	if i < 0 || !m.invites[i].IsValid(redemption.RedeemedAt) {
+		return domain.Invite{}, domain.ErrInvalidInvite
+	}
+
+	m.invites[i].Redemptions = append(m.invites[i].Redemptions, redemption)
+	m.invited = append(m.invited, redemption.ChatID)
+	return m.invites[i], nil
+}
+
+func (m *mockInviteStore) IsChatInvited(chatID int64) bool {
+	return slices.Contains(m.invited, chatID)
+}
+
+func (m *mockInviteStore) InvitedChats() []int64 {
+	return m.invited
+}
+
+func (m *mockInviteStore) RevokeChat(chatID int64) (bool, error) {
+	if m.err != nil || !m.IsChatInvited(chatID) {
+		return false, m.err
+	}
+
+	m.invited = slices.DeleteFunc(m.invited, func(id int64) bool { return id == chatID })
+	return true, nil
+}
+
+func TestInvite(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		text        string
+		wantUses    int
+		wantExpires time.Time
+		wantErr     string
+	}{
+		{name: "defaults", text: "/invite", wantUses: 1, wantExpires: now.Add(7 * 24 * time.Hour)},
+		{name: "uses and days", text: "/invite 3 2d", wantUses: 3, wantExpires: now.Add(48 * time.Hour)},
+		{name: "duration", text: "/invite 1 90m", wantUses: 1, wantExpires: now.Add(90 * time.Minute)},
+		{name: "invalid expiry", text: "/invite 1 soon", wantErr: "invalid expiry: \"soon\" is not a duration"},
+		{name: "negative expiry", text: "/invite 1 -1h", wantErr: "invalid expiry: must be positive"},
+		{name: "too many uses", text: "/invite 500", wantErr: "invalid uses"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockInviteStore{}
+			ts := &MockTextSender{}
+			invite := NewInvite(store, ts, "hsbot", "/invite")
+			invite.now = func() time.Time { return now }
+
+			err := invite.Respond(t.Context(), time.Minute, &domain.Message{Text: tc.text, UserID: 7})
+			require.NoError(t, err)
+
+			if tc.wantErr != "" {
+				assert.Contains(t, ts.Message, tc.wantErr)
+				assert.Empty(t, store.invites)
+				return
+			}
+
+			require.Len(t, store.invites, 1)
+			created := store.invites[0]
+			assert.Len(t, created.Code, 12)
+			assert.Equal(t, int64(7), created.CreatedBy)
+			assert.Equal(t, tc.wantUses, created.Uses)
+			assert.Equal(t, tc.wantExpires, created.ExpiresAt)
+			assert.Contains(t, ts.Message, "https://t.me/hsbot?start="+created.Code)
+			assert.Contains(t, ts.Message, "https://t.me/hsbot?startgroup="+created.Code)
+		})
+	}
+}
+
+func TestInviteStoreError(t *testing.T) {
+	ts := &MockTextSender{}
+	invite := NewInvite(&mockInviteStore{err: errors.New("disk full")}, ts, "hsbot", "/invite")
+
+	err := invite.Respond(t.Context(), time.Minute, &domain.Message{Text: "/invite"})
+	require.Error(t, err)
+
+	assert.Equal(t, "failed to create invite: disk full", ts.Message)
+}
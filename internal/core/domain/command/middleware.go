@@ -0,0 +1,242 @@
+package command
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"hsbot/internal/core/service"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Chain wraps a respond function with middlewares, the first one being the outermost.
+func Chain(name string, respond port.RespondFunc, middlewares ...port.Middleware) port.RespondFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		respond = middlewares[i](name, respond)
+	}
+
+	return respond
+}
+
+// Recover turns a panic while responding into an error, instead of crashing the bot.
+func Recover() port.Middleware {
+	return func(name string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					zerolog.Ctx(ctx).Error().
+						Str("command", name).
+						Interface("panic", r).
+						Bytes("stack", debug.Stack()).
+						Msg("recovered from panic")
+					err = fmt.Errorf("panic in %s: %v", name, r)
+				}
+			}()
+
+			return next(ctx, timeout, message)
+		}
+	}
+}
+
+// Logging logs each request with a correlation ID. The request logger is passed on in the context, commands get it
+// with zerolog.Ctx.
+func Logging() port.Middleware {
+	return func(name string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			l := log.With().
+				Str("correlationId", newCorrelationID()).
+				Int("messageId", message.ID).
+				Int64("chatId", message.ChatID).
+				Str("command", name).
+				Logger()
+
+			l.Info().Msg("handling request")
+			start := time.Now()
+
+			err := next(l.WithContext(ctx), timeout, message)
+
+			event := l.Debug()
+			if err != nil {
+				event = l.Warn().Err(err)
+			}
+			event.Dur("duration", time.Since(start)).Msg("handled request")
+
+			return err
+		}
+	}
+}
+
+// Timeout cancels the context of a request after its timeout.
+func Timeout() port.Middleware {
+	return func(_ string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			return next(ctx, timeout, message)
+		}
+	}
+}
+
+// Authorize drops requests of chats that aren't authorized to use the bot. Admins are authorized in every chat. Public
+// commands, like /start, are passed through and handle unauthorized chats themselves. Only commands tell the chat how
+// to get access, messages for listeners are dropped silently.
+func Authorize(auth service.Authorizer, public ...string) port.Middleware {
+	return func(name string, next port.RespondFunc) port.RespondFunc {
+		if slices.Contains(public, name) {
+			return next
+		}
+
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			if !auth.IsAdmin(message.UserID) && !isAuthorized(ctx, auth, message) {
+				zerolog.Ctx(ctx).Debug().Msg("not authorized")
+				return nil
+			}
+
+			return next(ctx, timeout, message)
+		}
+	}
+}
+
+// isAuthorized reports whether the chat of a message may use the bot, telling the chat how to get access only if the
+// message is a command.
+func isAuthorized(ctx context.Context, auth service.Authorizer, message *domain.Message) bool {
+	if !strings.HasPrefix(message.Text, "/") {
+		return auth.IsChatAuthorized(message.ChatID)
+	}
+
+	return auth.IsAuthorized(ctx, message.ChatID)
+}
+
+// AdminOnly restricts a command to admins, replying to everyone else.
+func AdminOnly(auth service.Authorizer, sender port.TextSender) port.Middleware {
+	return func(name string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			if !auth.IsAdmin(message.UserID) {
+				zerolog.Ctx(ctx).Debug().Int64("userId", message.UserID).Msg("not an admin")
+				_, err := sender.SendMessageReply(ctx, message, name+" is only available to admins")
+				return err
+			}
+
+			return next(ctx, timeout, message)
+		}
+	}
+}
+
+// Permissions resolves the role of the requesting user, passed on in the context, and drops commands the role may not
+// use. Listeners that aren't bound to a command always pass.
+func Permissions(roles service.RoleResolver, sender port.TextSender) port.Middleware {
+	return func(name string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			role := roles.Role(message.UserID, message.ChatID)
+
+			if name != ListenerName && !role.AllowsCommand(name) {
+				zerolog.Ctx(ctx).Debug().Int64("userId", message.UserID).Str("role", role.Name).
+					Msg("command not permitted")
+				_, err := sender.SendMessageReply(ctx, message, fmt.Sprintf("%s is not available to %ss", name, role.Name))
+				return err
+			}
+
+			return next(domain.WithRole(ctx, role), timeout, message)
+		}
+	}
+}
+
+// SpendLimit drops requests of chats that reached their spending limit and of users that reached the spending cap
+// of their role.
+func SpendLimit(track service.Tracker) port.Middleware {
+	return func(_ string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			spendCap := domain.RoleFromContext(ctx).SpendCap
+			if !track.CheckLimit(ctx, message.ChatID, message.UserID, spendCap) {
+				zerolog.Ctx(ctx).Debug().Msg("spending limit reached")
+				return nil
+			}
+
+			return next(ctx, timeout, message)
+		}
+	}
+}
+
+// CostCheck drops requests whose estimated cost exceeds what is left of a spending limit, like SpendLimit for
+// requests of unknown cost. Requests estimated above the confirmation threshold wait for the user to confirm them,
+// checking the limits again once confirmed.
+func CostCheck(track service.Tracker, estimator port.CostEstimator, confirmations *Confirmations) port.Middleware {
+	return func(_ string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			l := zerolog.Ctx(ctx)
+			cost := estimator.EstimateCost(ctx, message)
+			spendCap := domain.RoleFromContext(ctx).SpendCap
+
+			if !track.CheckCost(ctx, message.ChatID, message.UserID, spendCap, cost) {
+				l.Debug().Float64("estimate", cost).Msg("spending limit reached")
+				return nil
+			}
+
+			if !confirmations.Required(cost) {
+				return next(ctx, timeout, message)
+			}
+
+			l.Debug().Float64("estimate", cost).Msg("asking for confirmation")
+
+			return confirmations.Ask(ctx, message, cost, func(confirmed context.Context) error {
+				ctx, cancel := context.WithTimeout(l.WithContext(confirmed), timeout)
+				defer cancel()
+
+				if !track.CheckCost(ctx, message.ChatID, message.UserID, spendCap, cost) {
+					l.Debug().Float64("estimate", cost).Msg("spending limit reached after confirmation")
+					return nil
+				}
+
+				return next(ctx, timeout, message)
+			})
+		}
+	}
+}
+
+// RateLimit drops requests of users exceeding the rate limit configured for the command.
+func RateLimit(limiter service.RateLimiter, registry port.CommandRegistry) port.Middleware {
+	return func(name string, next port.RespondFunc) port.RespondFunc {
+		limit := registry.GetConfig(name).RateLimit
+		if !limit.IsSet() {
+			return next
+		}
+
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			if !limiter.Allow(ctx, message, name, limit) {
+				zerolog.Ctx(ctx).Debug().Int64("userId", message.UserID).Msg("rate limited")
+				return nil
+			}
+
+			return next(ctx, timeout, message)
+		}
+	}
+}
+
+// ChatAction shows a chat action, like typing, while a request is handled.
+func ChatAction(sender port.TextSender, action domain.Action) port.Middleware {
+	return func(_ string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+
+			go sender.SendChatAction(ctx, message.ChatID, action)
+
+			return next(ctx, timeout, message)
+		}
+	}
+}
+
+func newCorrelationID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,369 @@
+package command
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAuthorizer struct {
+	authorized bool
+	adminID    int64
+}
+
+func (m mockAuthorizer) IsAuthorized(_ context.Context, _ int64) bool {
+	return m.authorized
+}
+
+func (m mockAuthorizer) IsChatAuthorized(_ int64) bool {
+	return m.authorized
+}
+
+func (m mockAuthorizer) IsAdmin(userID int64) bool {
+	return userID == m.adminID
+}
+
+// recordingMiddleware appends its label to calls before and after the next respond function.
+func recordingMiddleware(label string, calls *[]string) port.Middleware {
+	return func(_ string, next port.RespondFunc) port.RespondFunc {
+		return func(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+			*calls = append(*calls, label+" before")
+			err := next(ctx, timeout, message)
+			*calls = append(*calls, label+" after")
+			return err
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	respond := func(_ context.Context, _ time.Duration, _ *domain.Message) error {
+		calls = append(calls, "respond")
+		return nil
+	}
+
+	chained := Chain("/test", respond, recordingMiddleware("outer", &calls), recordingMiddleware("inner", &calls))
+
+	err := chained(t.Context(), time.Second, &domain.Message{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"outer before", "inner before", "respond", "inner after", "outer after"}, calls)
+}
+
+func TestRecover(t *testing.T) {
+	respond := func(_ context.Context, _ time.Duration, _ *domain.Message) error {
+		panic("boom")
+	}
+
+	err := Chain("/test", respond, Recover())(t.Context(), time.Second, &domain.Message{})
+
+	require.EqualError(t, err, "panic in /test: boom")
+}
+
+func TestLogging(t *testing.T) {
+	var logger *zerolog.Logger
+	respond := func(ctx context.Context, _ time.Duration, _ *domain.Message) error {
+		logger = zerolog.Ctx(ctx)
+		return nil
+	}
+
+	err := Chain("/test", respond, Logging())(t.Context(), time.Second, &domain.Message{ID: 1, ChatID: 2})
+	require.NoError(t, err)
+
+	assert.NotSame(t, zerolog.DefaultContextLogger, logger)
+	assert.NotEqual(t, zerolog.Disabled, logger.GetLevel())
+}
+
+func TestTimeout(t *testing.T) {
+	var deadline time.Time
+	var ok bool
+	respond := func(ctx context.Context, _ time.Duration, _ *domain.Message) error {
+		deadline, ok = ctx.Deadline()
+		return nil
+	}
+
+	err := Chain("/test", respond, Timeout())(t.Context(), time.Minute, &domain.Message{})
+	require.NoError(t, err)
+
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, time.Second)
+}
+
+func TestAccessMiddlewares(t *testing.T) {
+	tests := []struct {
+		name       string
+		middleware port.Middleware
+		wantCalled bool
+	}{
+		{name: "authorized", middleware: Authorize(mockAuthorizer{authorized: true}), wantCalled: true},
+		{name: "not authorized", middleware: Authorize(mockAuthorizer{authorized: false}), wantCalled: false},
+		{name: "admin in unauthorized chat", middleware: Authorize(mockAuthorizer{adminID: 7}), wantCalled: true},
+		{name: "public command", middleware: Authorize(mockAuthorizer{}, "/test"), wantCalled: true},
+		{name: "admin", middleware: AdminOnly(mockAuthorizer{adminID: 7}, &MockTextSender{}), wantCalled: true},
+		{name: "not an admin", middleware: AdminOnly(mockAuthorizer{adminID: 8}, &MockTextSender{}), wantCalled: false},
+		{name: "within limit", middleware: SpendLimit(MockTracker{withinLimit: true}), wantCalled: true},
+		{name: "limit reached", middleware: SpendLimit(MockTracker{withinLimit: false}), wantCalled: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			called := false
+			respond := func(_ context.Context, _ time.Duration, _ *domain.Message) error {
+				called = true
+				return nil
+			}
+
+			err := Chain("/test", respond, tc.middleware)(t.Context(), time.Second, &domain.Message{ChatID: 1, UserID: 7})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantCalled, called)
+		})
+	}
+}
+
+// notifyingAuthorizer counts the unauthorized chats told how to get access.
+type notifyingAuthorizer struct {
+	mockAuthorizer
+	notified int
+}
+
+func (m *notifyingAuthorizer) IsAuthorized(_ context.Context, _ int64) bool {
+	m.notified++
+	return m.authorized
+}
+
+func TestAuthorize_Listener(t *testing.T) {
+	auth := &notifyingAuthorizer{}
+	respond := func(_ context.Context, _ time.Duration, _ *domain.Message) error {
+		t.Fatal("unauthorized chats must not get a response")
+		return nil
+	}
+
+	chain := Chain(ListenerName, respond, Authorize(auth))
+	require.NoError(t, chain(t.Context(), time.Second, &domain.Message{ChatID: 1, UserID: 7, Text: "hello"}))
+	assert.Zero(t, auth.notified, "messages for listeners are dropped silently")
+
+	chain = Chain("/test", respond, Authorize(auth))
+	require.NoError(t, chain(t.Context(), time.Second, &domain.Message{ChatID: 1, UserID: 7, Text: "/test"}))
+	assert.Equal(t, 1, auth.notified, "commands tell the chat how to get access")
+}
+
+type mockRateLimiter struct {
+	allow   bool
+	command string
+	limit   domain.RateLimit
+}
+
+func (m *mockRateLimiter) Allow(_ context.Context, _ *domain.Message, command string, limit domain.RateLimit) bool {
+	m.command = command
+	m.limit = limit
+	return m.allow
+}
+
+func (m *mockRateLimiter) Wait(_ *domain.Message, _ string, _ domain.RateLimit) time.Duration {
+	return 0
+}
+
+func TestRateLimit(t *testing.T) {
+	viper.Set("commands.image.rate_limit", 3)
+	t.Cleanup(func() { viper.Set("commands", nil) })
+
+	cr := &Registry{}
+	cr.Register(&MockResponder{command: "/image"})
+	cr.Register(&MockResponder{command: "/chat"})
+
+	tests := []struct {
+		name        string
+		command     string
+		allow       bool
+		wantCalled  bool
+		wantLimited bool
+	}{
+		{name: "within limit", command: "/image", allow: true, wantCalled: true, wantLimited: true},
+		{name: "limit reached", command: "/image", allow: false, wantCalled: false, wantLimited: true},
+		{name: "no limit configured", command: "/chat", allow: false, wantCalled: true, wantLimited: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := &mockRateLimiter{allow: tc.allow}
+			called := false
+			respond := func(_ context.Context, _ time.Duration, _ *domain.Message) error {
+				called = true
+				return nil
+			}
+
+			err := Chain(tc.command, respond, RateLimit(limiter, cr))(t.Context(), time.Second, &domain.Message{})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantCalled, called)
+			if tc.wantLimited {
+				assert.Equal(t, tc.command, limiter.command)
+				assert.Equal(t, domain.RateLimit{Requests: 3, Interval: time.Minute}, limiter.limit)
+			} else {
+				assert.Empty(t, limiter.command)
+			}
+		})
+	}
+}
+
+type mockRoles struct {
+	role domain.Role
+}
+
+func (m mockRoles) Role(_, _ int64) domain.Role {
+	return m.role
+}
+
+func TestPermissions(t *testing.T) {
+	guest := domain.Role{Name: domain.RoleGuest, Commands: []string{"/chat"}, SpendCap: 0.1}
+
+	tests := []struct {
+		name       string
+		command    string
+		wantCalled bool
+		wantReply  string
+	}{
+		{name: "allowed command", command: "/chat", wantCalled: true},
+		{name: "disallowed command", command: "/image", wantReply: "/image is not available to guests"},
+		{name: "listener", command: ListenerName, wantCalled: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := &MockTextSender{}
+			var role domain.Role
+			respond := func(ctx context.Context, _ time.Duration, _ *domain.Message) error {
+				role = domain.RoleFromContext(ctx)
+				return nil
+			}
+
+			err := Chain(tc.command, respond, Permissions(mockRoles{role: guest}, ts))(t.Context(), time.Second,
+				&domain.Message{})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantReply, ts.Message)
+			if tc.wantCalled {
+				assert.Equal(t, guest, role, "the role is passed on in the context")
+			} else {
+				assert.Empty(t, role.Name)
+			}
+		})
+	}
+}
+
+// capRecordingTracker records the personal spending cap it was asked to check.
+type capRecordingTracker struct {
+	MockTracker
+	userCap float64
+}
+
+func (m *capRecordingTracker) CheckLimit(_ context.Context, _, _ int64, userCap float64) bool {
+	m.userCap = userCap
+	return true
+}
+
+func TestSpendLimitUsesCapOfRole(t *testing.T) {
+	track := &capRecordingTracker{}
+	respond := func(_ context.Context, _ time.Duration, _ *domain.Message) error { return nil }
+
+	ctx := domain.WithRole(t.Context(), domain.Role{Name: domain.RoleGuest, SpendCap: 0.25})
+	err := Chain("/chat", respond, SpendLimit(track))(ctx, time.Second, &domain.Message{})
+	require.NoError(t, err)
+
+	assert.InDelta(t, 0.25, track.userCap, 0.001)
+}
+
+// budgetTracker allows costs up to the remaining budget and records the estimates it was asked to check.
+type budgetTracker struct {
+	MockTracker
+	remaining float64
+	costs     []float64
+}
+
+func (m *budgetTracker) CheckCost(_ context.Context, _, _ int64, _, cost float64) bool {
+	m.costs = append(m.costs, cost)
+	return cost <= m.remaining
+}
+
+type fixedEstimator float64
+
+func (e fixedEstimator) EstimateCost(_ context.Context, _ *domain.Message) float64 {
+	return float64(e)
+}
+
+func TestCostCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		estimate   float64
+		remaining  float64
+		wantCalled bool
+		wantReply  string
+	}{
+		{name: "cheap request", estimate: 0.05, remaining: 1, wantCalled: true},
+		{name: "exceeds remaining budget", estimate: 0.5, remaining: 0.2},
+		{name: "expensive request asks for confirmation", estimate: 0.5, remaining: 1,
+			wantReply: "This request will cost about $0.50. Do you want to continue?"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := &MockTextSender{}
+			track := &budgetTracker{remaining: tc.remaining}
+			confirmations := &Confirmations{textSender: ts, threshold: 0.1, pending: map[string]pendingRequest{},
+				now: time.Now}
+
+			called := false
+			respond := func(_ context.Context, _ time.Duration, _ *domain.Message) error {
+				called = true
+				return nil
+			}
+
+			check := CostCheck(track, fixedEstimator(tc.estimate), confirmations)
+			err := Chain("/image", respond, check)(t.Context(), time.Second, &domain.Message{ChatID: 1, UserID: 7})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantCalled, called)
+			assert.Equal(t, []float64{tc.estimate}, track.costs)
+			assert.Equal(t, tc.wantReply, ts.Message)
+			assert.Equal(t, tc.wantReply != "", len(confirmations.pending) == 1)
+		})
+	}
+}
+
+func TestCostCheck_Confirmed(t *testing.T) {
+	ts := &MockTextSender{}
+	track := &budgetTracker{remaining: 1}
+	confirmations := &Confirmations{textSender: ts, threshold: 0.1, pending: map[string]pendingRequest{},
+		now: time.Now}
+
+	called := false
+	respond := func(_ context.Context, _ time.Duration, _ *domain.Message) error {
+		called = true
+		return nil
+	}
+
+	check := CostCheck(track, fixedEstimator(0.5), confirmations)
+	err := Chain("/image", respond, check)(t.Context(), time.Second, &domain.Message{ChatID: 1, UserID: 7})
+	require.NoError(t, err)
+	require.False(t, called)
+
+	var id string
+	for key := range confirmations.pending {
+		id = key
+	}
+
+	err = confirmations.HandleCallback(t.Context(), time.Second, &domain.Callback{ChatID: 1, UserID: 7,
+		Data: callbackConfirm + ":" + id, Message: &domain.Message{ID: 2}})
+	require.NoError(t, err)
+
+	assert.True(t, called)
+	assert.Equal(t, []float64{0.5, 0.5}, track.costs, "the limits are checked again once confirmed")
+}
@@ -3,24 +3,25 @@ package command
 import (
 	"context"
 	"fmt"
-	"hsbot/internal/adapters/generator"
 	"hsbot/internal/core/domain"
 	"hsbot/internal/core/port"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type Models struct {
-	or      *generator.OpenRouter
-	ts      port.TextSender
-	command string
+	modelProvider port.ModelProvider
+	ts            port.TextSender
+	command       string
 }
 
-func NewModels(or *generator.OpenRouter, ts port.TextSender, command string) *Models {
+func NewModels(modelProvider port.ModelProvider, ts port.TextSender, command string) *Models {
 	return &Models{
-		or:      or,
-		ts:      ts,
-		command: command,
+		modelProvider: modelProvider,
+		ts:            ts,
+		command:       command,
 	}
 }
 
@@ -28,33 +29,81 @@ func (m *Models) GetCommand() string {
 	return m.command
 }
 
-func (m *Models) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
-	models := m.or.Models
+func (m *Models) GetDescription() string {
+	return "List the available chat models"
+}
+
+func (m *Models) GetUsage() string {
+	return "usage: " + m.command
+}
 
+func (m *Models) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
 	sb := &strings.Builder{}
 
-	_, err := sb.WriteString("hsbot is multimodal. You can choose the LLM you want to interact with by " +
-		"adding a #keyword to your prompts in /chat mode. Here's a list of currently active models:\n\n")
-	if err != nil {
-		return fmt.Errorf("failed to construct response: %w", err)
-	}
+	sb.WriteString("hsbot is multimodal. You can choose the LLM you want to interact with by " +
+		"adding a #keyword to your prompts in /chat mode. Here's a list of currently active models:\n")
 
-	for _, model := range models {
-		_, err = fmt.Fprintf(sb, " - Model: %s, Keyword: %s\n", model.Identifier, model.Keyword)
-		if err != nil {
-			return fmt.Errorf("failed to construct response: %w", err)
+	role := domain.RoleFromContext(ctx)
+	for _, model := range m.modelProvider.GetModels() {
+		if !role.AllowsModel(model.Keyword) {
+			continue
 		}
-	}
 
-	_, err = sb.WriteString("\nKeep in mind that not every model has image recognition capabilities.")
-	if err != nil {
-		return fmt.Errorf("failed to construct response: %w", err)
+		fmt.Fprintf(sb, "\n#%s: %s", model.Keyword, model.Identifier)
+		if model.Default > 0 {
+			sb.WriteString(" (default)")
+		}
+
+		if details := modelDetails(model); details != "" {
+			sb.WriteString("\n   " + details)
+		}
+		sb.WriteString("\n")
 	}
 
-	_, err = m.ts.SendMessageReply(ctx, message, sb.String())
+	sb.WriteString("\nModels with image input can answer questions about a photo sent along with the prompt.")
+
+	_, err := m.ts.SendMessageReply(ctx, message, sb.String())
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 
 	return nil
 }
+
+// modelDetails describes the input modalities, context length and prices of a model, as far as they are known.
+func modelDetails(model domain.Model) string {
+	var details []string
+
+	if len(model.InputModalities) > 0 {
+		details = append(details, strings.Join(model.InputModalities, ", ")+" input")
+	}
+
+	if model.ContextLength > 0 {
+		details = append(details, formatTokens(model.ContextLength)+" context")
+	}
+
+	if model.PromptPrice > 0 || model.CompletionPrice > 0 {
+		details = append(details, fmt.Sprintf("$%s in, $%s out per 1M tokens",
+			formatPrice(model.PromptPrice), formatPrice(model.CompletionPrice)))
+	}
+
+	return strings.Join(details, " | ")
+}
+
+// formatTokens shortens a number of tokens to thousands or millions, like 128K or 1M.
+func formatTokens(tokens int) string {
+	if tokens >= 1_000_000 {
+		return strconv.FormatFloat(math.Round(float64(tokens)/100_000)/10, 'f', -1, 64) + "M"
+	}
+
+	if tokens >= 1000 {
+		return strconv.Itoa(tokens/1000) + "K"
+	}
+
+	return strconv.Itoa(tokens)
+}
+
+// formatPrice formats a price in dollars with up to three decimals, dropping trailing zeros.
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(math.Round(price*1000)/1000, 'f', -1, 64)
+}
@@ -0,0 +1,53 @@
+package command
+
+import (
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModels(t *testing.T) {
+	provider := &MockModelProvider{models: []domain.Model{
+		{Keyword: "claude", Identifier: "anthropic/claude-sonnet-4", Default: 1,
+			InputModalities: []string{"text", "image"}, ContextLength: 200000, PromptPrice: 3, CompletionPrice: 15},
+		{Keyword: "gemini", Identifier: "google/gemini-2.5-flash", InputModalities: []string{"text", "image", "file"},
+			ContextLength: 1048576, PromptPrice: 0.3, CompletionPrice: 2.5},
+		{Keyword: "deepseek", Identifier: "deepseek/deepseek-chat-v3-0324", InputModalities: []string{"text"},
+			ContextLength: 163840, PromptPrice: 0.2800000001, CompletionPrice: 0.88},
+		{Keyword: "unslop", Identifier: "thedrummer/unslopnemo-12b"},
+	}}
+
+	intro := "hsbot is multimodal. You can choose the LLM you want to interact with by adding a #keyword to your " +
+		"prompts in /chat mode. Here's a list of currently active models:\n"
+	outro := "\nModels with image input can answer questions about a photo sent along with the prompt."
+
+	t.Run("all models", func(t *testing.T) {
+		ts := &MockTextSender{}
+		models := NewModels(provider, ts, "/models")
+
+		require.NoError(t, models.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1}))
+
+		assert.Equal(t, intro+
+			"\n#claude: anthropic/claude-sonnet-4 (default)\n"+
+			"   text, image input | 200K context | $3 in, $15 out per 1M tokens\n"+
+			"\n#gemini: google/gemini-2.5-flash\n"+
+			"   text, image, file input | 1M context | $0.3 in, $2.5 out per 1M tokens\n"+
+			"\n#deepseek: deepseek/deepseek-chat-v3-0324\n"+
+			"   text input | 163K context | $0.28 in, $0.88 out per 1M tokens\n"+
+			"\n#unslop: thedrummer/unslopnemo-12b\n"+
+			outro, ts.Message)
+	})
+
+	t.Run("models of the role", func(t *testing.T) {
+		ts := &MockTextSender{}
+		models := NewModels(provider, ts, "/models")
+		ctx := domain.WithRole(t.Context(), domain.Role{Name: domain.RoleGuest, Models: []string{"unslop"}})
+
+		require.NoError(t, models.Respond(ctx, time.Minute, &domain.Message{ChatID: 1}))
+
+		assert.Equal(t, intro+"\n#unslop: thedrummer/unslopnemo-12b\n"+outro, ts.Message)
+	})
+}
@@ -0,0 +1,58 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+const progressMessage = "transcribing: %d/%d segments done"
+
+// withProgressReply returns a copy of ctx reporting progress of long-running operations to the chat. The first update
+// is sent as reply to the message, subsequent updates edit that reply.
+func withProgressReply(ctx context.Context, sender port.TextSender, message *domain.Message) context.Context {
+	var (
+		mutex    sync.Mutex
+		replyID  = -1
+		reported int
+	)
+
+	return domain.WithProgress(ctx, func(done, total int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if done <= reported {
+			return
+		}
+		reported = done
+
+		text := fmt.Sprintf(progressMessage, done, total)
+
+		var err error
+		if replyID == -1 {
+			replyID, err = sender.SendMessageReply(ctx, message, text)
+		} else {
+			err = sender.EditMessageText(ctx, message.ChatID, replyID, text)
+		}
+
+		if err != nil {
+			log.Warn().Err(err).Int64("chatID", message.ChatID).Msg("failed to report progress")
+		}
+	})
+}
+
+// withTranscriptionContext returns a copy of ctx for transcribing the audio of the message, reporting progress to the
+// chat. The duration of a voice message is passed along, unless the audio may come from a replied-to message.
+func withTranscriptionContext(ctx context.Context, sender port.TextSender, message *domain.Message) context.Context {
+	ctx = withProgressReply(ctx, sender, message)
+
+	if message.VoiceDuration > 0 && (message.ReplyToMessageID == nil || *message.ReplyToMessageID == 0) {
+		ctx = domain.WithAudioDuration(ctx, message.VoiceDuration)
+	}
+
+	return ctx
+}
@@ -0,0 +1,23 @@
+package command
+
+import (
+	"hsbot/internal/core/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithProgressReply(t *testing.T) {
+	sender := &mockTextSender{}
+	ctx := withProgressReply(t.Context(), sender, &domain.Message{ID: 1, ChatID: 2})
+
+	domain.ReportProgress(ctx, 1, 3)
+	domain.ReportProgress(ctx, 3, 3)
+	// outdated updates from slower workers are dropped
+	domain.ReportProgress(ctx, 2, 3)
+
+	assert.Equal(t, []string{
+		"transcribing: 1/3 segments done",
+		"transcribing: 3/3 segments done",
+	}, sender.replyCalls)
+}
@@ -1,24 +1,110 @@
 package command
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
 	"hsbot/internal/core/port"
+	"slices"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/rs/zerolog/log"
 )
 
 type Registry struct {
-	commands map[string]port.Command
+	// commands holds the registered commands by name and alias.
+	commands  map[string]registeredCommand
+	listeners []port.Listener
+	callbacks map[string]port.CallbackHandler
 }
 
-func (r *Registry) Register(handler port.Command) {
+type registeredCommand struct {
+	command     port.Command
+	config      domain.CommandConfig
+	middlewares []port.Middleware
+}
+
+// chainedCommand responds through the middlewares registered with a command.
+type chainedCommand struct {
+	port.Command
+	respond port.RespondFunc
+}
+
+func (c *chainedCommand) Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+	return c.respond(ctx, timeout, message)
+}
+
+func (c *chainedCommand) ReadsDocuments() bool {
+	reader, ok := c.Command.(port.DocumentReader)
+	return ok && reader.ReadsDocuments()
+}
+
+func (c *chainedCommand) ReadsAudio() bool {
+	reader, ok := c.Command.(port.AudioReader)
+	return ok && reader.ReadsAudio()
+}
+
+// chainedListener responds through the middlewares registered with a listener.
+type chainedListener struct {
+	port.Listener
+	respond port.RespondFunc
+}
+
+func (c *chainedListener) Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error {
+	return c.respond(ctx, timeout, message)
+}
+
+func (c *chainedListener) BoundCommand() string {
+	bound, ok := c.Listener.(port.CommandBound)
+	if !ok {
+		return ""
+	}
+
+	return bound.BoundCommand()
+}
+
+func (c *chainedListener) ReadsAudio() bool {
+	reader, ok := c.Listener.(port.AudioReader)
+	return ok && reader.ReadsAudio()
+}
+
+// Register adds a command with the settings of its config section. Disabled commands are skipped.
+func (r *Registry) Register(handler port.Command, middlewares ...port.Middleware) {
 	if r.commands == nil {
-		r.commands = make(map[string]port.Command)
+		r.commands = make(map[string]registeredCommand)
 	}
 
-	log.Info().Str("handler", handler.GetCommand()).Msg("adding command handler to registry")
-	r.commands[handler.GetCommand()] = handler
+	name := handler.GetCommand()
+	l := log.With().Str("handler", name).Logger()
+
+	config, err := LoadCommandConfig(name)
+	if err != nil {
+		l.Error().Err(err).Msg("failed loading command config, skipping command")
+		return
+	}
+
+	if config.Disabled {
+		l.Info().Msg("command disabled in config")
+		return
+	}
+
+	l.Info().Strs("aliases", config.Aliases).Msg("adding command handler to registry")
+
+	entry := registeredCommand{command: handler, config: config, middlewares: middlewares}
+	r.commands[name] = entry
+
+	for _, alias := range config.Aliases {
+		if existing, ok := r.commands[alias]; ok && existing.command != handler {
+			l.Warn().Str("alias", alias).Msg("alias already taken, skipping")
+			continue
+		}
+
+		r.commands[alias] = entry
+	}
 }
 
 func (r *Registry) Get(command string) (port.Command, error) {
@@ -29,11 +115,93 @@ func (r *Registry) Get(command string) (port.Command, error) {
 		return nil, err
 	}
 
-	handler, ok := r.commands[command]
+	entry, ok := r.commands[command]
 	if !ok {
 		return nil, errors.New("command not found")
 	}
 
+	if len(entry.middlewares) == 0 {
+		return entry.command, nil
+	}
+
+	return &chainedCommand{
+		Command: entry.command,
+		respond: Chain(entry.command.GetCommand(), entry.command.Respond, entry.middlewares...),
+	}, nil
+}
+
+func (r *Registry) GetConfig(command string) domain.CommandConfig {
+	return r.commands[command].config
+}
+
+func (r *Registry) DescribeCommands(chatID int64, admin bool) []domain.CommandInfo {
+	var infos []domain.CommandInfo
+
+	for _, name := range r.ListCommands() {
+		entry := r.commands[name]
+
+		describer, ok := entry.command.(port.CommandDescriber)
+		if !ok || !entry.config.AllowsChat(chatID) {
+			continue
+		}
+
+		if restricted, ok := entry.command.(port.AdminCommand); ok && restricted.IsAdminOnly() && !admin {
+			continue
+		}
+
+		infos = append(infos, domain.CommandInfo{
+			Command:     name,
+			Description: describer.GetDescription(),
+			Usage:       describer.GetUsage(),
+		})
+	}
+
+	slices.SortFunc(infos, func(a, b domain.CommandInfo) int { return strings.Compare(a.Command, b.Command) })
+
+	return infos
+}
+
+// ListenerName is the name the global middlewares get for listeners, which aren't bound to a command.
+const ListenerName = "listener"
+
+func (r *Registry) RegisterListener(listener port.Listener, middlewares ...port.Middleware) {
+	log.Info().Type("listener", listener).Msg("adding listener to registry")
+
+	if len(middlewares) > 0 {
+		listener = &chainedListener{
+			Listener: listener,
+			respond:  Chain(fmt.Sprintf("%T", listener), listener.Respond, middlewares...),
+		}
+	}
+
+	r.listeners = append(r.listeners, listener)
+}
+
+func (r *Registry) GetListener(message *domain.Message) (port.Listener, error) {
+	for _, listener := range r.listeners {
+		if listener.Matches(message) {
+			return listener, nil
+		}
+	}
+
+	return nil, errors.New("no matching listener")
+}
+
+func (r *Registry) RegisterCallback(handler port.CallbackHandler) {
+	if r.callbacks == nil {
+		r.callbacks = make(map[string]port.CallbackHandler)
+	}
+
+	log.Info().Str("namespace", handler.GetCallbackNamespace()).Msg("adding callback handler to registry")
+	r.callbacks[handler.GetCallbackNamespace()] = handler
+}
+
+func (r *Registry) GetCallback(namespace string) (port.CallbackHandler, error) {
+	handler, ok := r.callbacks[namespace]
+	if !ok {
+		return nil, errors.New("callback handler not found")
+	}
+
 	return handler, nil
 }
 
@@ -42,29 +210,60 @@ func (r *Registry) ListCommands() []string {
 		return []string{}
 	}
 
-	keys := make([]string, len(r.commands))
+	keys := make([]string, 0, len(r.commands))
 
-	i := 0
-	for k := range r.commands {
-		keys[i] = k
-		i++
+	for k, entry := range r.commands {
+		// aliases share the entry of their command
+		if entry.command.GetCommand() == k {
+			keys = append(keys, k)
+		}
 	}
 
 	return keys
 }
 
-// ParseCommandArgs extracts the portion of the string after the first space, or returns an empty string if none exists.
+// ParseCommandArgs extracts the portion of the string after the first whitespace, or returns an empty string if none
+// exists.
 func ParseCommandArgs(args string) string {
-	idx := strings.IndexByte(args, ' ')
+	_, rest := splitCommand(args)
+	return rest
+}
+
+// splitCommand cuts a command text at its first whitespace, like the space or line break after a command.
+func splitCommand(args string) (string, string) {
+	idx := strings.IndexFunc(args, unicode.IsSpace)
 	if idx == -1 {
-		return ""
+		return args, ""
 	}
-	return args[idx+1:]
+
+	_, size := utf8.DecodeRuneInString(args[idx:])
+	return args[:idx], args[idx+size:]
+}
+
+// IsAddressedTo reports whether a command is meant for the bot with the given username. Commands without a handle are
+// addressed to every bot in the chat.
+func IsAddressedTo(args, username string) bool {
+	command, _ := splitCommand(args)
+	_, handle, ok := strings.Cut(command, "@")
+	return !ok || strings.EqualFold(handle, username)
+}
+
+const callbackSeparator = ":"
+
+// CallbackData builds inline keyboard callback data routed to the handler of the given namespace.
+func CallbackData(namespace string, args ...string) string {
+	return strings.Join(append([]string{namespace}, args...), callbackSeparator)
+}
+
+// ParseCallbackData splits callback data into its namespace and the remaining payload.
+func ParseCallbackData(data string) (string, string) {
+	namespace, payload, _ := strings.Cut(data, callbackSeparator)
+	return namespace, payload
 }
 
 // ParseCommand extracts the primary command from the input string, discarding arguments and handle, returning it in lowercase.
 func ParseCommand(args string) string {
-	command := strings.Split(args, " ")[0]
+	command, _ := splitCommand(args)
 	if strings.Contains(command, "@") {
 		command = strings.Split(command, "@")[0]
 	}
@@ -3,9 +3,11 @@ package command
 import (
 	"context"
 	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
 	"testing"
 	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -22,6 +24,84 @@ func (m *MockResponder) GetCommand() string {
 	return m.command
 }
 
+type MockDescribedResponder struct {
+	MockResponder
+	adminOnly bool
+}
+
+func (m *MockDescribedResponder) GetDescription() string {
+	return "description of " + m.command
+}
+
+func (m *MockDescribedResponder) GetUsage() string {
+	return "usage: " + m.command
+}
+
+func (m *MockDescribedResponder) IsAdminOnly() bool {
+	return m.adminOnly
+}
+
+func TestDescribeCommands(t *testing.T) {
+	cr := &Registry{}
+	cr.Register(&MockDescribedResponder{MockResponder: MockResponder{command: "/zeta"}})
+	cr.Register(&MockDescribedResponder{MockResponder: MockResponder{command: "/alpha"}})
+	cr.Register(&MockDescribedResponder{MockResponder: MockResponder{command: "/allow"}, adminOnly: true})
+	cr.Register(&MockResponder{command: "/undescribed"})
+
+	names := func(infos []domain.CommandInfo) []string {
+		var result []string
+		for _, info := range infos {
+			result = append(result, info.Command)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"/alpha", "/zeta"}, names(cr.DescribeCommands(0, false)))
+	assert.Equal(t, []string{"/alpha", "/zeta"}, names(cr.DescribeCommands(1, false)), "admin commands are hidden")
+	assert.Equal(t, []string{"/allow", "/alpha", "/zeta"}, names(cr.DescribeCommands(1, true)))
+	assert.Equal(t, domain.CommandInfo{Command: "/alpha", Description: "description of /alpha",
+		Usage: "usage: /alpha"}, cr.DescribeCommands(0, false)[0])
+}
+
+func TestRegisterWithConfig(t *testing.T) {
+	viper.Set("commands.image.aliases", []string{"/img", "pic"})
+	viper.Set("commands.image.timeout", "2m")
+	viper.Set("commands.image.chat_ids", []int64{1})
+	viper.Set("commands.debug.enabled", false)
+	viper.Set("commands.spent.enabled", true)
+	viper.Set("commands.image.rate_limit", 3)
+	viper.Set("commands.spent.rate_limit", 2)
+	viper.Set("commands.spent.rate_interval", "1h")
+	t.Cleanup(func() { viper.Set("commands", nil) })
+
+	cr := &Registry{}
+	image := &MockDescribedResponder{MockResponder: MockResponder{command: "/image"}}
+	cr.Register(image)
+	cr.Register(&MockResponder{command: "/debug"})
+	cr.Register(&MockResponder{command: "/spent"})
+
+	for _, name := range []string{"/image", "/img", "/pic"} {
+		cmd, err := cr.Get(name)
+		require.NoError(t, err)
+		assert.Same(t, image, cmd)
+		assert.Equal(t, 2*time.Minute, cr.GetConfig(name).Timeout)
+	}
+
+	_, err := cr.Get("/debug")
+	require.Error(t, err)
+
+	_, err = cr.Get("/spent")
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"/image", "/spent"}, cr.ListCommands())
+	assert.Empty(t, cr.DescribeCommands(0, false))
+	assert.Len(t, cr.DescribeCommands(1, false), 1)
+	assert.True(t, cr.GetConfig("/image").AllowsChat(1))
+	assert.False(t, cr.GetConfig("/image").AllowsChat(2))
+	assert.Equal(t, domain.RateLimit{Requests: 3, Interval: time.Minute}, cr.GetConfig("/image").RateLimit)
+	assert.Equal(t, domain.RateLimit{Requests: 2, Interval: time.Hour}, cr.GetConfig("/spent").RateLimit)
+}
+
 func TestRegister(t *testing.T) {
 	cr := &Registry{}
 	mr := &MockResponder{command: "/test"}
@@ -62,6 +142,47 @@ func TestGetCommandFound(t *testing.T) {
 	assert.Equal(t, "/test", cmd.GetCommand())
 }
 
+func TestGetCommandReadsDocuments(t *testing.T) {
+	cr := &Registry{}
+	cr.Register(NewChatImport(&Chat{}, &MockTextSender{}, "/import"), Recover())
+	cr.Register(&MockResponder{command: "/test"}, Recover())
+
+	cmd, err := cr.Get("/import")
+	require.NoError(t, err)
+	reader, ok := cmd.(port.DocumentReader)
+	require.True(t, ok)
+	assert.True(t, reader.ReadsDocuments(), "middlewares keep the document reader")
+
+	cmd, err = cr.Get("/test")
+	require.NoError(t, err)
+	reader, ok = cmd.(port.DocumentReader)
+	assert.False(t, ok && reader.ReadsDocuments())
+}
+
+func TestRegistryReadsAudio(t *testing.T) {
+	cr := &Registry{}
+	cr.Register(NewTranscribe(&MockTranscriber{}, &MockTextSender{}, "/transcribe"), Recover())
+	cr.Register(&MockResponder{command: "/test"}, Recover())
+	cr.RegisterListener(NewChatReply(&Chat{}, domain.BotIdentity{}, &mockAutoReplyStore{}), Recover())
+
+	cmd, err := cr.Get("/transcribe")
+	require.NoError(t, err)
+	reader, ok := cmd.(port.AudioReader)
+	require.True(t, ok)
+	assert.True(t, reader.ReadsAudio(), "middlewares keep the audio reader")
+
+	cmd, err = cr.Get("/test")
+	require.NoError(t, err)
+	reader, ok = cmd.(port.AudioReader)
+	assert.False(t, ok && reader.ReadsAudio())
+
+	listener, err := cr.GetListener(&domain.Message{Text: "hi", IsPrivateChat: true})
+	require.NoError(t, err)
+	reader, ok = listener.(port.AudioReader)
+	require.True(t, ok)
+	assert.True(t, reader.ReadsAudio())
+}
+
 func TestListServices(t *testing.T) {
 	cr := &Registry{}
 	mr1 := &MockResponder{command: "/foo"}
@@ -96,6 +217,11 @@ func TestParseCommandArgs(t *testing.T) {
 			args:        "/scale 12 13",
 			want:        "12 13",
 		},
+		{
+			description: "should discard first word before a line break",
+			args:        "/chat\nhello\nworld",
+			want:        "hello\nworld",
+		},
 		{
 			description: "empty on no args",
 			args:        "/scale",
@@ -140,6 +266,11 @@ func TestParseCommand(t *testing.T) {
 			args:        "/chat prompt something",
 			want:        "/chat",
 		},
+		{
+			description: "should discard words after a line break",
+			args:        "/chat@hsbot\nprompt",
+			want:        "/chat",
+		},
 		{
 			description: "empty on no input",
 			args:        "",
@@ -155,3 +286,95 @@ func TestParseCommand(t *testing.T) {
 		})
 	}
 }
+
+type MockListener struct {
+	chatID int64
+}
+
+func (m *MockListener) Matches(message *domain.Message) bool {
+	return message.ChatID == m.chatID
+}
+
+func (m *MockListener) Respond(_ context.Context, _ time.Duration, _ *domain.Message) error {
+	return nil
+}
+
+func TestGetListener(t *testing.T) {
+	cr := &Registry{}
+	ml1 := &MockListener{chatID: 1}
+	ml2 := &MockListener{chatID: 2}
+
+	cr.RegisterListener(ml1)
+	cr.RegisterListener(ml2)
+
+	listener, err := cr.GetListener(&domain.Message{ChatID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, ml2, listener)
+
+	_, err = cr.GetListener(&domain.Message{ChatID: 3})
+	require.Error(t, err)
+}
+
+func TestIsAddressedTo(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want bool
+	}{
+		{name: "no handle", args: "/chat hello", want: true},
+		{name: "own handle", args: "/chat@hsbot hello", want: true},
+		{name: "own handle, different case", args: "/chat@HSBot", want: true},
+		{name: "other bot", args: "/chat@otherbot hello", want: false},
+		{name: "mention in arguments", args: "/chat hello @otherbot", want: true},
+		{name: "other bot before a line break", args: "/chat@otherbot\nhello", want: false},
+		{name: "mention after a line break", args: "/chat\nhello @otherbot", want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsAddressedTo(tc.args, "hsbot"))
+		})
+	}
+}
+
+type MockCallbackHandler struct {
+	namespace string
+}
+
+func (m *MockCallbackHandler) HandleCallback(_ context.Context, _ time.Duration, _ *domain.Callback) error {
+	return nil
+}
+
+func (m *MockCallbackHandler) GetCallbackNamespace() string {
+	return m.namespace
+}
+
+func TestGetCallback(t *testing.T) {
+	cr := &Registry{}
+	mc := &MockCallbackHandler{namespace: "chat"}
+
+	_, err := cr.GetCallback("chat")
+	require.Error(t, err)
+
+	cr.RegisterCallback(mc)
+
+	handler, err := cr.GetCallback("chat")
+	require.NoError(t, err)
+	assert.Equal(t, mc, handler)
+
+	_, err = cr.GetCallback("image")
+	require.Error(t, err)
+}
+
+func TestCallbackData(t *testing.T) {
+	data := CallbackData("chat", "model", "gpt")
+	assert.Equal(t, "chat:model:gpt", data)
+
+	namespace, payload := ParseCallbackData(data)
+	assert.Equal(t, "chat", namespace)
+	assert.Equal(t, "model:gpt", payload)
+
+	namespace, payload = ParseCallbackData("chat")
+	assert.Equal(t, "chat", namespace)
+	assert.Empty(t, payload)
+}
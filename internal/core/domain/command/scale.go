@@ -6,12 +6,13 @@ import (
 	"fmt"
 	"hsbot/internal/core/domain"
 	"hsbot/internal/core/port"
-	"strconv"
 	"time"
-
-	"github.com/rs/zerolog/log"
 )
 
+var scaleArgs = ArgSpec{
+	Args: []Arg{{Name: "power", Type: ArgFloat, Help: "rescale power", Default: "50", Min: 1, Max: 100}},
+}
+
 type Scale struct {
 	imageConverter port.ImageConverter
 	textSender     port.TextSender
@@ -29,40 +30,27 @@ func (s *Scale) GetCommand() string {
 	return s.command
 }
 
-func (s *Scale) Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error {
-	l := log.With().
-		Int("messageId", message.ID).
-		Int64("chatId", message.ChatID).
-		Str("command", s.GetCommand()).
-		Logger()
-
-	l.Info().Msg("handling request")
-
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+func (s *Scale) GetDescription() string {
+	return "Liquid rescale an image"
+}
 
-	go s.textSender.SendChatAction(ctx, message.ChatID, domain.SendingPhoto)
+func (s *Scale) GetUsage() string {
+	return scaleArgs.Usage(s.command)
+}
 
+func (s *Scale) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
 	if message.ImageURL == "" {
 		_ = s.textSender.NotifyAndReturnError(ctx, errors.New("missing image"), message)
 		return nil
 	}
 
-	args := ParseCommandArgs(message.Text)
-
-	var power float64
-	var err error
-	if args == "" {
-		power = 50
-	} else {
-		power, err = strconv.ParseFloat(args, 32)
-		if err != nil {
-			_ = s.textSender.NotifyAndReturnError(ctx, errors.New("usage: /scale or /scale <power>, 1-100"), message)
-			return nil
-		}
+	args, err := scaleArgs.Parse(s.command, message.Text)
+	if err != nil {
+		_ = s.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
 	}
 
-	rescaled, err := s.imageConverter.Scale(ctx, message.ImageURL, float32(power))
+	rescaled, err := s.imageConverter.Scale(ctx, message.ImageURL, float32(args.Float("power")))
 	if err != nil {
 		return s.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to scale image: %w", err), message)
 	}
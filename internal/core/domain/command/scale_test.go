@@ -73,7 +73,8 @@ func TestScaleRespondInvalidParam(t *testing.T) {
 	_ = scaleHandler.Respond(t.Context(), time.Minute, &domain.Message{ImageURL: "foo", ReplyToMessageID: id,
 		Text: "/scale foo"})
 
-	assert.Equal(t, "usage: /scale or /scale <power>, 1-100", ts.Message)
+	assert.Equal(t, "invalid power: \"foo\" is not a number\nusage: /scale [power]\n"+
+		"  power: rescale power, 1-100, default 50", ts.Message)
 }
 
 func TestScaleRespondInvalidParamAndErrorSending(t *testing.T) {
@@ -88,7 +89,8 @@ func TestScaleRespondInvalidParamAndErrorSending(t *testing.T) {
 	_ = scaleHandler.Respond(t.Context(), time.Minute, &domain.Message{ImageURL: "foo", ReplyToMessageID: id,
 		Text: "/scale foo"})
 
-	assert.Equal(t, "usage: /scale or /scale <power>, 1-100", ts.Message)
+	assert.Equal(t, "invalid power: \"foo\" is not a number\nusage: /scale [power]\n"+
+		"  power: rescale power, 1-100, default 50", ts.Message)
 }
 
 func TestScaleRespondErrorScaleFailed(t *testing.T) {
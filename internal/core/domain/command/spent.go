@@ -1,25 +1,55 @@
 package command
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"hsbot/internal/core/domain"
 	"hsbot/internal/core/port"
 	"hsbot/internal/core/service"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/rs/zerolog"
 )
 
+const spentExport = "export"
+
+var spentArgs = ArgSpec{
+	Args: []Arg{{Name: "period", Help: "the spending to break down, export sends admins the ledger CSV privately",
+		Default: "today", Choices: []string{"today", "week", "month", spentExport}}},
+}
+
+// spentPeriods maps the periods of /spent to their budget window and the name used in the reply.
+var spentPeriods = map[string]struct{ window, name string }{
+	"today": {service.Daily, "today"},
+	"week":  {service.Weekly, "this week"},
+	"month": {service.Monthly, "this month"},
+}
+
 type Spent struct {
-	tracker service.Tracker
-	sender  port.TextSender
-	command string
+	tracker        service.Tracker
+	ledger         port.Ledger
+	auth           service.Authorizer
+	sender         port.TextSender
+	documentSender port.DocumentSender
+	command        string
 }
 
-func NewSpent(tracker service.Tracker, ts port.TextSender, command string) *Spent {
+func NewSpent(tracker service.Tracker, ledger port.Ledger, auth service.Authorizer, ts port.TextSender,
+	documentSender port.DocumentSender, command string) *Spent {
 	return &Spent{
-		tracker: tracker,
-		sender:  ts,
-		command: command,
+		tracker:        tracker,
+		ledger:         ledger,
+		auth:           auth,
+		sender:         ts,
+		documentSender: documentSender,
+		command:        command,
 	}
 }
 
@@ -27,14 +57,153 @@ func (s *Spent) GetCommand() string {
 	return s.command
 }
 
-const spentMessage = "Spent today within ChatID %d: $%.2f."
+func (s *Spent) GetDescription() string {
+	return "Show the spending of today, the week or the month"
+}
+
+func (s *Spent) GetUsage() string {
+	return spentArgs.Usage(s.command)
+}
+
+const spentMessage = "Spent %s within ChatID %d: $%.2f, your share: $%.2f."
 
 func (s *Spent) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
-	_, err := s.sender.SendMessageReply(ctx, message, fmt.Sprintf(spentMessage,
-		message.ChatID, s.tracker.GetSpent(message.ChatID)))
+	args, err := spentArgs.Parse(s.command, message.Text)
+	if err != nil {
+		_ = s.sender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	period := strings.ToLower(args.String("period"))
+	if period == spentExport {
+		return s.export(ctx, message)
+	}
+
+	p := spentPeriods[period]
+	entries, err := s.ledger.Entries(s.tracker.WindowStart(p.window))
+	if err != nil {
+		return s.sender.NotifyAndReturnError(ctx, fmt.Errorf("failed to read the ledger: %w", err), message)
+	}
+
+	var total, share float64
+	byCommand, byModel, byUser := map[string]float64{}, map[string]float64{}, map[string]float64{}
+	for _, entry := range entries {
+		if entry.ChatID != message.ChatID {
+			continue
+		}
+
+		total += entry.Cost
+		if entry.UserID == message.UserID {
+			share += entry.Cost
+		}
+
+		byCommand[entry.Command] += entry.Cost
+		byModel[cmp.Or(entry.Model, "unknown")] += entry.Cost
+		byUser[cmp.Or(entry.Username, strconv.FormatInt(entry.UserID, 10))] += entry.Cost
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString(fmt.Sprintf(spentMessage, p.name, message.ChatID, total, share))
+	writeBreakdown(sb, "By command", byCommand)
+	writeBreakdown(sb, "By model", byModel)
+	writeBreakdown(sb, "By user", byUser)
+
+	_, err = s.sender.SendMessageReply(ctx, message, sb.String())
 	if err != nil {
 		return fmt.Errorf("failed to send message: %w", err)
 	}
 
 	return nil
 }
+
+// writeBreakdown lists the costs by name below the title, the highest first.
+func writeBreakdown(sb *strings.Builder, title string, costs map[string]float64) {
+	if len(costs) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(costs))
+	for name := range costs {
+		names = append(names, name)
+	}
+
+	slices.SortFunc(names, func(a, b string) int {
+		if c := cmp.Compare(costs[b], costs[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+
+	sb.WriteString("\n\n" + title + ":")
+	for _, name := range names {
+		sb.WriteString(fmt.Sprintf("\n%s: $%.2f", name, costs[name]))
+	}
+}
+
+// export sends the whole ledger of all chats as CSV document, to admins in private chats only. The ledger reveals the
+// spending of every chat, so it is kept out of group chats.
+func (s *Spent) export(ctx context.Context, message *domain.Message) error {
+	if !s.auth.IsAdmin(message.UserID) {
+		_ = s.sender.NotifyAndReturnError(ctx, errors.New("only admins can export the ledger"), message)
+		return nil
+	}
+
+	if !message.IsPrivateChat {
+		err := fmt.Errorf("the ledger can only be exported in a private chat, send %s %s to me directly", s.command,
+			spentExport)
+		_ = s.sender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	entries, err := s.ledger.Entries(time.Time{})
+	if err != nil {
+		return s.sender.NotifyAndReturnError(ctx, fmt.Errorf("failed to read the ledger: %w", err), message)
+	}
+
+	if len(entries) == 0 {
+		return s.sender.NotifyAndReturnError(ctx, errors.New("the ledger is empty"), message)
+	}
+
+	data, err := ledgerCSV(entries)
+	if err != nil {
+		return s.sender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	name := fmt.Sprintf("ledger-%s.csv", time.Now().Format("20060102-150405"))
+	err = s.documentSender.SendDocumentReply(ctx, message, name, data)
+	if err != nil {
+		return s.sender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	zerolog.Ctx(ctx).Debug().Int("entries", len(entries)).Msg("exported ledger")
+
+	return nil
+}
+
+func ledgerCSV(entries []domain.LedgerEntry) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+
+	records := [][]string{{"time", "chat_id", "user_id", "username", "command", "model", "tokens", "cost",
+		"credit"}}
+	for _, entry := range entries {
+		records = append(records, []string{
+			entry.Time.Format(time.RFC3339),
+			strconv.FormatInt(entry.ChatID, 10),
+			strconv.FormatInt(entry.UserID, 10),
+			entry.Username,
+			entry.Command,
+			entry.Model,
+			strconv.Itoa(entry.Tokens),
+			strconv.FormatFloat(entry.Cost, 'f', -1, 64),
+			strconv.FormatFloat(entry.Credit, 'f', -1, 64),
+		})
+	}
+
+	err := w.WriteAll(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the ledger: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,161 @@
+package command
+
+import (
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/service"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// windowTracker starts each window at a fixed time.
+type windowTracker struct {
+	MockTracker
+	starts map[string]time.Time
+}
+
+func (m windowTracker) WindowStart(window string) time.Time {
+	return m.starts[window]
+}
+
+// mockLedger returns its entries recorded at or after since.
+type mockLedger struct {
+	entries []domain.LedgerEntry
+	err     error
+}
+
+func (m *mockLedger) Record(entry domain.LedgerEntry) error {
+	m.entries = append(m.entries, entry)
+	return m.err
+}
+
+func (m *mockLedger) Entries(since time.Time) ([]domain.LedgerEntry, error) {
+	var entries []domain.LedgerEntry
+	for _, entry := range m.entries {
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, m.err
+}
+
+func TestSpent(t *testing.T) {
+	monday := time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)
+	today := monday.AddDate(0, 0, 2)
+	ledger := &mockLedger{entries: []domain.LedgerEntry{
+		{Time: monday.AddDate(0, 0, -3), ChatID: -100, UserID: 7, Command: "/chat", Model: "openai/gpt-4.1", Cost: 5},
+		{Time: monday.Add(time.Hour), ChatID: -100, UserID: 8, Username: "bob", Command: "/image", Model: "imagen3",
+			Cost: 0.5},
+		{Time: today.Add(time.Hour), ChatID: -100, UserID: 7, Username: "alice", Command: "/chat",
+			Model: "openai/gpt-4.1", Tokens: 1000, Cost: 1},
+		{Time: today.Add(2 * time.Hour), ChatID: -100, UserID: 8, Username: "bob", Command: "/chat",
+			Model: "anthropic/claude-sonnet-4", Tokens: 500, Cost: 0.25},
+		{Time: today.Add(time.Hour), ChatID: 1, UserID: 7, Command: "/chat", Cost: 9},
+	}}
+	tracker := windowTracker{starts: map[string]time.Time{
+		service.Daily: today, service.Weekly: monday, service.Monthly: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "today",
+			text: "/spent",
+			want: "Spent today within ChatID -100: $1.25, your share: $1.00.\n\n" +
+				"By command:\n/chat: $1.25\n\n" +
+				"By model:\nopenai/gpt-4.1: $1.00\nanthropic/claude-sonnet-4: $0.25\n\n" +
+				"By user:\nalice: $1.00\nbob: $0.25",
+		},
+		{
+			name: "week",
+			text: "/spent week",
+			want: "Spent this week within ChatID -100: $1.75, your share: $1.00.\n\n" +
+				"By command:\n/chat: $1.25\n/image: $0.50\n\n" +
+				"By model:\nopenai/gpt-4.1: $1.00\nimagen3: $0.50\nanthropic/claude-sonnet-4: $0.25\n\n" +
+				"By user:\nalice: $1.00\nbob: $0.75",
+		},
+		{
+			name: "month",
+			text: "/spent Month",
+			want: "Spent this month within ChatID -100: $6.75, your share: $6.00.\n\n" +
+				"By command:\n/chat: $6.25\n/image: $0.50\n\n" +
+				"By model:\nopenai/gpt-4.1: $6.00\nimagen3: $0.50\nanthropic/claude-sonnet-4: $0.25\n\n" +
+				"By user:\n7: $5.00\nalice: $1.00\nbob: $0.75",
+		},
+		{
+			name: "invalid period",
+			text: "/spent year",
+			want: "invalid period: \"year\" is not one of today, week, month, export\n" +
+				spentArgs.Usage("/spent"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := &MockTextSender{}
+			spent := NewSpent(tracker, ledger, mockAuthorizer{}, ts, &mockDocumentSender{}, "/spent")
+
+			err := spent.Respond(t.Context(), time.Minute, &domain.Message{ChatID: -100, UserID: 7, Text: tc.text})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, ts.Message)
+		})
+	}
+}
+
+func TestSpent_Export(t *testing.T) {
+	ledger := &mockLedger{entries: []domain.LedgerEntry{
+		{Time: time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC), ChatID: -100, UserID: 7, Username: "alice, the admin",
+			Command: "/chat", Model: "openai/gpt-4.1", Tokens: 1000, Cost: 0.0125},
+		{Time: time.Date(2025, 1, 15, 13, 0, 0, 0, time.UTC), ChatID: 1, UserID: 8, Command: "/image",
+			Model: "imagen3", Cost: 0.025, Credit: 0.02},
+	}}
+
+	t.Run("admin", func(t *testing.T) {
+		ts := &MockTextSender{}
+		ds := &mockDocumentSender{}
+		spent := NewSpent(MockTracker{}, ledger, mockAuthorizer{adminID: 7}, ts, ds, "/spent")
+
+		err := spent.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 7, UserID: 7, Text: "/spent export",
+			IsPrivateChat: true})
+		require.NoError(t, err)
+
+		require.Len(t, ds.documents, 1)
+		for name, data := range ds.documents {
+			assert.Regexp(t, `^ledger-\d{8}-\d{6}\.csv$`, name)
+			assert.Equal(t, "time,chat_id,user_id,username,command,model,tokens,cost,credit\n"+
+				"2025-01-15T12:00:00Z,-100,7,\"alice, the admin\",/chat,openai/gpt-4.1,1000,0.0125,0\n"+
+				"2025-01-15T13:00:00Z,1,8,,/image,imagen3,0,0.025,0.02\n", string(data))
+		}
+	})
+
+	t.Run("admin in group chat", func(t *testing.T) {
+		ts := &MockTextSender{}
+		ds := &mockDocumentSender{}
+		spent := NewSpent(MockTracker{}, ledger, mockAuthorizer{adminID: 7}, ts, ds, "/spent")
+
+		err := spent.Respond(t.Context(), time.Minute, &domain.Message{ChatID: -100, UserID: 7, Text: "/spent export"})
+		require.NoError(t, err)
+
+		assert.Empty(t, ds.documents)
+		assert.Equal(t, "the ledger can only be exported in a private chat, send /spent export to me directly",
+			ts.Message)
+	})
+
+	t.Run("not an admin", func(t *testing.T) {
+		ts := &MockTextSender{}
+		ds := &mockDocumentSender{}
+		spent := NewSpent(MockTracker{}, ledger, mockAuthorizer{adminID: 1}, ts, ds, "/spent")
+
+		err := spent.Respond(t.Context(), time.Minute, &domain.Message{ChatID: -100, UserID: 7, Text: "/spent export"})
+		require.NoError(t, err)
+
+		assert.Empty(t, ds.documents)
+		assert.Equal(t, "only admins can export the ledger", ts.Message)
+	})
+}
@@ -0,0 +1,85 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"hsbot/internal/core/service"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Start greets chats opening the bot. Opened with an invite code, by the deep links of /invite, it authorizes the
+// chat. It handles unauthorized chats itself, so exempt it from the Authorize middleware.
+type Start struct {
+	invites    port.InviteStore
+	auth       service.Authorizer
+	textSender port.TextSender
+	command    string
+	now        func() time.Time
+}
+
+func NewStart(invites port.InviteStore, auth service.Authorizer, sender port.TextSender, command string) *Start {
+	return &Start{invites: invites, auth: auth, textSender: sender, command: command, now: time.Now}
+}
+
+func (s *Start) GetCommand() string {
+	return s.command
+}
+
+func (s *Start) GetDescription() string {
+	return "Start using the bot, optionally with an invite code"
+}
+
+func (s *Start) GetUsage() string {
+	return fmt.Sprintf("usage: %s [invite code]", s.command)
+}
+
+func (s *Start) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	code := strings.TrimSpace(ParseCommandArgs(message.Text))
+
+	if code == "" {
+		if !s.auth.IsAdmin(message.UserID) && !s.auth.IsAuthorized(ctx, message.ChatID) {
+			return nil
+		}
+
+		return s.reply(ctx, message, "Hi! See /help for what I can do.")
+	}
+
+	// codes opened in chats that can use the bot anyway would use up invites for nothing
+	if s.auth.IsChatAuthorized(message.ChatID) {
+		return s.reply(ctx, message, "This chat can already use the bot, see /help for what I can do.")
+	}
+
+	invite, err := s.invites.RedeemInvite(code, domain.Redemption{
+		ChatID:     message.ChatID,
+		UserID:     message.UserID,
+		RedeemedAt: s.now(),
+	})
+	if errors.Is(err, domain.ErrInvalidInvite) {
+		zerolog.Ctx(ctx).Info().Str("code", code).Msg("rejected invite code")
+		_ = s.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+	if err != nil {
+		return s.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to redeem invite: %w", err), message)
+	}
+
+	zerolog.Ctx(ctx).Info().Str("code", code).Int64("userId", message.UserID).Int("remaining", invite.Remaining()).
+		Msg("redeemed invite")
+
+	return s.reply(ctx, message, "Welcome! This chat can use the bot now, see /help to get started.")
+}
+
+func (s *Start) reply(ctx context.Context, message *domain.Message, text string) error {
+	_, err := s.textSender.SendMessageReply(ctx, message, text)
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
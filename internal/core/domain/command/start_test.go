@@ -0,0 +1,65 @@
+package command
+
+import (
+	"errors"
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStart(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		text        string
+		auth        mockAuthorizer
+		want        string
+		wantInvited []int64
+	}{
+		{name: "authorized chat", text: "/start", auth: mockAuthorizer{authorized: true},
+			want: "Hi! See /help for what I can do."},
+		{name: "unauthorized chat", text: "/start"},
+		{name: "admin in unauthorized chat", text: "/start", auth: mockAuthorizer{adminID: 5},
+			want: "Hi! See /help for what I can do."},
+		{name: "invite code", text: "/start abc",
+			want: "Welcome! This chat can use the bot now, see /help to get started.", wantInvited: []int64{-100}},
+		{name: "invite code in group", text: "/start@hsbot abc",
+			want: "Welcome! This chat can use the bot now, see /help to get started.", wantInvited: []int64{-100}},
+		{name: "invite code in authorized chat", text: "/start abc", auth: mockAuthorizer{authorized: true},
+			want: "This chat can already use the bot, see /help for what I can do."},
+		{name: "expired code", text: "/start old", want: "invalid or expired invite code"},
+		{name: "unknown code", text: "/start xyz", want: "invalid or expired invite code"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockInviteStore{invites: []domain.Invite{
+				{Code: "abc", ExpiresAt: now.Add(time.Hour), Uses: 1},
+				{Code: "old", ExpiresAt: now.Add(-time.Hour), Uses: 1},
+			}}
+			ts := &MockTextSender{}
+			start := NewStart(store, tc.auth, ts, "/start")
+			start.now = func() time.Time { return now }
+
+			err := start.Respond(t.Context(), time.Minute, &domain.Message{Text: tc.text, ChatID: -100, UserID: 5})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.want, ts.Message)
+			assert.Equal(t, tc.wantInvited, store.invited)
+		})
+	}
+}
+
+func TestStartStoreError(t *testing.T) {
+	ts := &MockTextSender{}
+	start := NewStart(&mockInviteStore{err: errors.New("disk full")}, mockAuthorizer{}, ts, "/start")
+
+	err := start.Respond(t.Context(), time.Minute, &domain.Message{Text: "/start abc"})
+	require.Error(t, err)
+
+	assert.Equal(t, "failed to redeem invite: disk full", ts.Message)
+}
@@ -0,0 +1,203 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+)
+
+// topUpPayload prefixes the invoice payload of top-ups, followed by the user ID and the stars.
+const topUpPayload = "topup"
+
+const (
+	defaultTopUpStars = 100
+	maxTopUpStars     = 10000
+)
+
+// TopUp sells prepaid credit for Telegram Stars. The tracker draws from the balance once a user spent the free
+// daily allowance.
+type TopUp struct {
+	wallet     port.Wallet
+	textSender port.TextSender
+	invoices   port.InvoiceSender
+	starPrice  float64
+	args       ArgSpec
+	command    string
+}
+
+// NewTopUp credits payments.star_price dollars per star. Invoices are of payments.default_stars unless the user asks
+// for up to payments.max_stars. Payments need a daily allowance of users, of telegram.daily_user_spend_limit or the
+// spend_cap of a role.
+func NewTopUp(wallet port.Wallet, textSender port.TextSender, invoices port.InvoiceSender,
+	command string) (*TopUp, error) {
+	starPrice := viper.GetFloat64("payments.star_price")
+	if starPrice <= 0 {
+		return nil, errors.New("payments.star_price must be a positive dollar amount")
+	}
+
+	if !hasUserAllowance() {
+		return nil, errors.New("payments need a per-user allowance, set telegram.daily_user_spend_limit or the " +
+			"spend_cap of a role")
+	}
+
+	maxStars := viper.GetInt("payments.max_stars")
+	if maxStars <= 0 {
+		maxStars = maxTopUpStars
+	}
+
+	defaultStars := viper.GetInt("payments.default_stars")
+	if defaultStars <= 0 {
+		defaultStars = min(defaultTopUpStars, maxStars)
+	}
+
+	return &TopUp{
+		wallet:     wallet,
+		textSender: textSender,
+		invoices:   invoices,
+		starPrice:  starPrice,
+		args: ArgSpec{Args: []Arg{{Name: "stars", Type: ArgInt, Help: "the number of Telegram Stars to pay",
+			Default: strconv.Itoa(defaultStars), Min: 1, Max: float64(maxStars)}}},
+		command: command,
+	}, nil
+}
+
+// hasUserAllowance reports whether users have a daily allowance, beyond which they pay with their balance.
+func hasUserAllowance() bool {
+	if viper.GetFloat64("telegram.daily_user_spend_limit") > 0 {
+		return true
+	}
+
+	for _, role := range []string{domain.RoleAdmin, domain.RoleMember, domain.RoleGuest} {
+		if viper.GetFloat64("roles."+role+".spend_cap") > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *TopUp) GetCommand() string {
+	return t.command
+}
+
+func (t *TopUp) GetDescription() string {
+	return "Buy prepaid credit with Telegram Stars for requests beyond your daily allowance"
+}
+
+func (t *TopUp) GetUsage() string {
+	return t.args.Usage(t.command)
+}
+
+const topUpDescription = "$%.2f of credit for requests beyond your daily allowance. Your balance is $%.2f."
+
+func (t *TopUp) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
+	args, err := t.args.Parse(t.command, message.Text)
+	if err != nil {
+		_ = t.textSender.NotifyAndReturnError(ctx, err, message)
+		return nil
+	}
+
+	stars := args.Int("stars")
+	invoice := domain.Invoice{
+		Title:       "Prepaid credit",
+		Description: fmt.Sprintf(topUpDescription, t.credit(stars), t.wallet.Balance(message.UserID)),
+		Payload:     fmt.Sprintf("%s:%d:%d", topUpPayload, message.UserID, stars),
+		Stars:       stars,
+	}
+
+	err = t.invoices.SendInvoice(ctx, message, invoice)
+	if err != nil {
+		return t.textSender.NotifyAndReturnError(ctx, err, message)
+	}
+
+	return nil
+}
+
+// HandlePreCheckout accepts payments of top-up invoices by the user they were sent to, with the invoiced stars.
+func (t *TopUp) HandlePreCheckout(ctx context.Context, query *domain.PreCheckout) error {
+	var reason string
+
+	userID, stars, ok := parseTopUpPayload(query.Payload)
+	switch {
+	case !ok || query.Currency != domain.CurrencyStars || query.Amount != stars:
+		reason = "This invoice is invalid, please request a new one with " + t.command + "."
+	case userID != query.UserID:
+		reason = "This invoice belongs to another user, please request your own with " + t.command + "."
+	}
+
+	zerolog.Ctx(ctx).Debug().Int64("userId", query.UserID).Int("stars", query.Amount).Str("declined", reason).
+		Msg("pre-checkout query")
+
+	err := t.invoices.AnswerPreCheckoutQuery(ctx, query.ID, reason)
+	if err != nil {
+		return fmt.Errorf("failed to answer pre-checkout query: %w", err)
+	}
+
+	return nil
+}
+
+const topUpReceipt = "Thanks! $%.2f were added to your prepaid balance, which is now $%.2f."
+
+// HandlePayment credits a paid top-up to the balance of the paying user. Payments of a charge credited before are
+// ignored, as telegram may deliver an update twice.
+func (t *TopUp) HandlePayment(ctx context.Context, message *domain.Message, payment *domain.Payment) error {
+	l := zerolog.Ctx(ctx).With().Int64("userId", payment.UserID).Str("chargeId", payment.ChargeID).Logger()
+
+	if _, _, ok := parseTopUpPayload(payment.Payload); !ok || payment.Currency != domain.CurrencyStars {
+		l.Warn().Str("payload", payment.Payload).Str("currency", payment.Currency).Msg("unknown payment")
+		return nil
+	}
+
+	credit := t.credit(payment.Amount)
+	balance, err := t.wallet.Deposit(domain.CreditTransaction{UserID: payment.UserID, ChatID: payment.ChatID,
+		Amount: credit, Stars: payment.Amount, ChargeID: payment.ChargeID})
+	if errors.Is(err, domain.ErrDuplicateCharge) {
+		l.Debug().Msg("payment already credited")
+		return nil
+	}
+	if err != nil {
+		return t.textSender.NotifyAndReturnError(ctx,
+			fmt.Errorf("failed to credit payment %s, please contact an admin: %w", payment.ChargeID, err), message)
+	}
+
+	l.Info().Int("stars", payment.Amount).Float64("credit", credit).Msg("credited top-up")
+
+	_, err = t.textSender.SendMessageReply(ctx, message, fmt.Sprintf(topUpReceipt, credit, balance))
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+func (t *TopUp) credit(stars int) float64 {
+	return float64(stars) * t.starPrice
+}
+
+// parseTopUpPayload returns the user ID and stars of a top-up invoice payload.
+func parseTopUpPayload(payload string) (int64, int, bool) {
+	parts := strings.Split(payload, ":")
+	if len(parts) != 3 || parts[0] != topUpPayload {
+		return 0, 0, false
+	}
+
+	userID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	stars, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return userID, stars, true
+}
@@ -0,0 +1,115 @@
+package command
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockInvoiceSender records the sent invoices and the answers to pre-checkout queries.
+type mockInvoiceSender struct {
+	invoices []domain.Invoice
+	answers  map[string]string
+}
+
+func (m *mockInvoiceSender) SendInvoice(_ context.Context, _ *domain.Message, invoice domain.Invoice) error {
+	m.invoices = append(m.invoices, invoice)
+	return nil
+}
+
+func (m *mockInvoiceSender) AnswerPreCheckoutQuery(_ context.Context, queryID string, errorMessage string) error {
+	if m.answers == nil {
+		m.answers = map[string]string{}
+	}
+	m.answers[queryID] = errorMessage
+	return nil
+}
+
+// mockWallet keeps the balances of users in memory.
+type mockWallet struct {
+	balances map[int64]float64
+}
+
+func (m *mockWallet) Balance(userID int64) float64 {
+	return m.balances[userID]
+}
+
+func (m *mockWallet) Deposit(transaction domain.CreditTransaction) (float64, error) {
+	m.balances[transaction.UserID] += transaction.Amount
+	return m.balances[transaction.UserID], nil
+}
+
+func (m *mockWallet) Withdraw(_, _ int64, _ float64) (float64, error) {
+	return 0, nil
+}
+
+func TestNewTopUp(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	_, err := NewTopUp(&mockWallet{}, &MockTextSender{}, &mockInvoiceSender{}, "/topup")
+	require.Error(t, err, "payments need a star price")
+
+	viper.Set("payments.star_price", 0.013)
+	_, err = NewTopUp(&mockWallet{}, &MockTextSender{}, &mockInvoiceSender{}, "/topup")
+	require.Error(t, err, "payments need a user allowance")
+
+	viper.Set("roles.guest.spend_cap", 0.1)
+	_, err = NewTopUp(&mockWallet{}, &MockTextSender{}, &mockInvoiceSender{}, "/topup")
+	require.NoError(t, err)
+}
+
+func TestTopUp_Respond(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("payments.star_price", 0.013)
+	viper.Set("telegram.daily_user_spend_limit", 0.5)
+	viper.Set("payments.default_stars", 50)
+	viper.Set("payments.max_stars", 1000)
+
+	wallet := &mockWallet{balances: map[int64]float64{7: 0.2}}
+	invoices := &mockInvoiceSender{}
+	ts := &MockTextSender{}
+	topUp, err := NewTopUp(wallet, ts, invoices, "/topup")
+	require.NoError(t, err)
+
+	require.NoError(t, topUp.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, UserID: 7, Text: "/topup"}))
+	require.Len(t, invoices.invoices, 1)
+	assert.Equal(t, domain.Invoice{Title: "Prepaid credit", Payload: "topup:7:50", Stars: 50,
+		Description: "$0.65 of credit for requests beyond your daily allowance. Your balance is $0.20."},
+		invoices.invoices[0])
+
+	require.NoError(t, topUp.Respond(t.Context(), time.Minute, &domain.Message{ChatID: 1, UserID: 7,
+		Text: "/topup 5000"}))
+	assert.Len(t, invoices.invoices, 1)
+	assert.Contains(t, ts.Message, "invalid stars")
+}
+
+func TestTopUp_HandlePayment(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("payments.star_price", 0.01)
+	viper.Set("telegram.daily_user_spend_limit", 0.5)
+
+	wallet := &mockWallet{balances: map[int64]float64{}}
+	ts := &MockTextSender{}
+	topUp, err := NewTopUp(wallet, ts, &mockInvoiceSender{}, "/topup")
+	require.NoError(t, err)
+
+	message := &domain.Message{ChatID: 1, UserID: 7}
+	err = topUp.HandlePayment(t.Context(), message, &domain.Payment{UserID: 7, Currency: "XTR", Amount: 100,
+		Payload: "subscription:7", ChargeID: "charge-1"})
+	require.NoError(t, err)
+	assert.Empty(t, wallet.balances, "payments of other invoices aren't credited")
+
+	err = topUp.HandlePayment(t.Context(), message, &domain.Payment{UserID: 7, Currency: "XTR", Amount: 100,
+		Payload: "topup:7:100", ChargeID: "charge-2"})
+	require.NoError(t, err)
+	assert.InDelta(t, 1.0, wallet.balances[7], 1e-9)
+	assert.Equal(t, "Thanks! $1.00 were added to your prepaid balance, which is now $1.00.", ts.Message)
+}
@@ -7,8 +7,6 @@ import (
 	"hsbot/internal/core/domain"
 	"hsbot/internal/core/port"
 	"time"
-
-	"github.com/rs/zerolog/log"
 )
 
 type Transcribe struct {
@@ -25,27 +23,25 @@ func (h *Transcribe) GetCommand() string {
 	return h.command
 }
 
-func (h *Transcribe) Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error {
-	l := log.With().
-		Int("messageId", message.ID).
-		Int64("chatId", message.ChatID).
-		Str("audioURL", message.AudioURL).
-		Str("command", h.GetCommand()).
-		Logger()
-
-	l.Info().Msg("handling request")
+func (h *Transcribe) ReadsAudio() bool {
+	return true
+}
 
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+func (h *Transcribe) GetDescription() string {
+	return "Transcribe audio, voice messages and videos"
+}
 
-	go h.textSender.SendChatAction(ctx, message.ChatID, domain.Typing)
+func (h *Transcribe) GetUsage() string {
+	return fmt.Sprintf("usage: reply to audio or video with %s", h.command)
+}
 
+func (h *Transcribe) Respond(ctx context.Context, _ time.Duration, message *domain.Message) error {
 	if message.AudioURL == "" {
 		_ = h.textSender.NotifyAndReturnError(ctx, errors.New("reply to an audio"), message)
 		return nil
 	}
 
-	resp, err := h.transcriber.GenerateFromAudio(ctx, message.AudioURL)
+	resp, err := h.transcriber.GenerateFromAudio(withTranscriptionContext(ctx, h.textSender, message), message.AudioURL)
 	if err != nil {
 		return h.textSender.NotifyAndReturnError(ctx, fmt.Errorf("failed to generate audio: %w", err), message)
 	}
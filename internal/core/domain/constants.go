@@ -2,6 +2,12 @@ package domain
 
 import "errors"
 
+// CurrencyStars is the currency of payments in Telegram Stars.
+const CurrencyStars = "XTR"
+
 var (
-	ErrEmptyPrompt = errors.New("empty prompt")
+	ErrEmptyPrompt        = errors.New("empty prompt")
+	ErrInvalidInvite      = errors.New("invalid or expired invite code")
+	ErrDuplicateCharge    = errors.New("payment already credited")
+	ErrModelsNotInCatalog = errors.New("models not in the openrouter catalog")
 )
@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"slices"
+	"time"
+)
+
 type Author string
 
 const (
@@ -11,19 +16,106 @@ type Prompt struct {
 	Prompt   string
 	ImageURL string
 	Author   Author
+	// Model is the keyword of the model requested for a user prompt. It is resolved from a #keyword in the prompt,
+	// if not set explicitly. For answers, it is the model that generated the answer.
+	Model string
+	// Cost is the cost of generating an answer, zero for user prompts.
+	Cost float64
 }
 
 type Message struct {
-	ID               int
-	ChatID           int64
+	ID     int
+	ChatID int64
+	// ChatTitle is the title of group chats, empty for private chats.
+	ChatTitle string
+	// UserID is the numeric ID of the sender, Username only the display name.
+	UserID           int64
 	Username         string
 	ReplyToMessageID *int
 	ReplyToUsername  string
 	IsReplyToBot     bool
+	IsPrivateChat    bool
+	MentionsBot      bool
 	QuotedText       string
 	ImageURL         string
 	AudioURL         string
+	VoiceDuration    time.Duration
 	Text             string
+	// Document is the attached document of the message, or of the message being replied to.
+	Document *Document
+}
+
+// Document is a small file attached to a message.
+type Document struct {
+	Name     string
+	MimeType string
+	Data     []byte
+}
+
+// CommandConfig holds the settings of a command from its [commands.<name>] config section.
+type CommandConfig struct {
+	Disabled bool
+	// Aliases are additional command names, like /c for /chat.
+	Aliases []string
+	// Timeout overrides the default handler timeout, if set.
+	Timeout time.Duration
+	// ChatIDs restricts the command to the listed chats, if set.
+	ChatIDs []int64
+	// RateLimit limits the requests of each user in a chat, if set.
+	RateLimit RateLimit
+}
+
+// RateLimit allows Requests per Interval, in bursts of up to Requests.
+type RateLimit struct {
+	Requests int
+	Interval time.Duration
+}
+
+// IsSet reports whether the rate limit restricts requests at all.
+func (r RateLimit) IsSet() bool {
+	return r.Requests > 0 && r.Interval > 0
+}
+
+// AllowsChat reports whether the command can be used in the chat. The zero chat ID asks whether the command is
+// available in every chat.
+func (c CommandConfig) AllowsChat(chatID int64) bool {
+	return len(c.ChatIDs) == 0 || slices.Contains(c.ChatIDs, chatID)
+}
+
+// CommandInfo describes a command for help texts and command menus.
+type CommandInfo struct {
+	Command     string
+	Description string
+	Usage       string
+}
+
+// Button is an inline keyboard button. Pressing it sends its callback data back to the bot.
+type Button struct {
+	Text string
+	Data string
+}
+
+// Keyboard is an inline keyboard attached to a message, as rows of buttons.
+type Keyboard [][]Button
+
+// Callback is a button press on an inline keyboard of a message sent by the bot.
+type Callback struct {
+	ID     string
+	ChatID int64
+	// UserID is the numeric ID of the user pressing the button.
+	UserID   int64
+	Username string
+	// Data is the callback data without its namespace.
+	Data string
+	// Message is the bot message carrying the keyboard.
+	Message *Message
+}
+
+// BotIdentity describes the telegram user the bot is running as.
+type BotIdentity struct {
+	ID        int64
+	Username  string
+	FirstName string
 }
 
 type Action string
@@ -42,6 +134,21 @@ type Model struct {
 	Keyword    string `json:"keyword"`
 	Identifier string `json:"identifier"`
 	Default    int    `json:"default"`
+
+	// The remaining fields are filled from the OpenRouter model catalog, zero if unknown.
+
+	// InputModalities are the kinds of input the model accepts, like text and image.
+	InputModalities []string `json:"input_modalities,omitempty" mapstructure:"-"`
+	// ContextLength is the maximum number of tokens of a request and its completion.
+	ContextLength int `json:"context_length,omitempty" mapstructure:"-"`
+	// PromptPrice and CompletionPrice are the prices of a million prompt and completion tokens in dollars.
+	PromptPrice     float64 `json:"prompt_price,omitempty" mapstructure:"-"`
+	CompletionPrice float64 `json:"completion_price,omitempty" mapstructure:"-"`
+}
+
+// AcceptsImages reports whether the model accepts images as input.
+func (m Model) AcceptsImages() bool {
+	return slices.Contains(m.InputModalities, "image")
 }
 
 type ResponseMetadata struct {
@@ -51,3 +158,88 @@ type ResponseMetadata struct {
 	Cost             float64
 	Retries          int
 }
+
+// Invite is a code, minted by an admin, that authorizes the chats redeeming it.
+type Invite struct {
+	Code        string       `json:"code"`
+	CreatedBy   int64        `json:"createdBy"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	ExpiresAt   time.Time    `json:"expiresAt"`
+	Uses        int          `json:"uses"`
+	Redemptions []Redemption `json:"redemptions"`
+}
+
+// Redemption records a chat that redeemed an invite. Revoked redemptions no longer authorize the chat.
+type Redemption struct {
+	ChatID     int64     `json:"chatId"`
+	UserID     int64     `json:"userId"`
+	RedeemedAt time.Time `json:"redeemedAt"`
+	Revoked    bool      `json:"revoked,omitempty"`
+}
+
+// Remaining returns the number of chats that can still redeem the invite.
+func (i Invite) Remaining() int {
+	return max(i.Uses-len(i.Redemptions), 0)
+}
+
+// IsValid reports whether the invite can still be redeemed at the time.
+func (i Invite) IsValid(now time.Time) bool {
+	return i.Remaining() > 0 && now.Before(i.ExpiresAt)
+}
+
+// LedgerEntry is a single cost added to the spending of a chat and user.
+type LedgerEntry struct {
+	Time     time.Time `json:"time"`
+	ChatID   int64     `json:"chatId"`
+	UserID   int64     `json:"userId"`
+	Username string    `json:"username,omitempty"`
+	Command  string    `json:"command"`
+	// Model is the model or endpoint keyword that caused the cost.
+	Model  string  `json:"model,omitempty"`
+	Tokens int     `json:"tokens,omitempty"`
+	Cost   float64 `json:"cost"`
+	// Credit is the part of the cost paid from the prepaid balance of the user.
+	Credit float64 `json:"credit,omitempty"`
+}
+
+// Invoice asks a user to pay for a top-up of their balance in Telegram Stars.
+type Invoice struct {
+	Title       string
+	Description string
+	// Payload identifies the top-up in the pre-checkout query and the payment.
+	Payload string
+	Stars   int
+}
+
+// PreCheckout is the last chance to decline a payment before the user is charged.
+type PreCheckout struct {
+	ID       string
+	UserID   int64
+	Currency string
+	Amount   int
+	Payload  string
+}
+
+// Payment is a successful payment of an invoice.
+type Payment struct {
+	ChatID   int64
+	UserID   int64
+	Username string
+	Currency string
+	Amount   int
+	Payload  string
+	// ChargeID is the telegram payment charge ID, needed to refund the payment.
+	ChargeID string
+}
+
+// CreditTransaction is a change of the prepaid balance of a user, either a top-up or a request paid from it.
+type CreditTransaction struct {
+	Time   time.Time `json:"time"`
+	UserID int64     `json:"userId"`
+	ChatID int64     `json:"chatId"`
+	// Amount is the change of the balance in dollars, negative for requests.
+	Amount float64 `json:"amount"`
+	// Stars and ChargeID are the price and the telegram payment charge ID of a top-up.
+	Stars    int    `json:"stars,omitempty"`
+	ChargeID string `json:"chargeId,omitempty"`
+}
@@ -0,0 +1,23 @@
+package domain
+
+import "context"
+
+// ProgressFunc receives status updates of long-running operations, e.g. the number of processed audio segments.
+type ProgressFunc func(done, total int)
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx carrying the given ProgressFunc.
+func WithProgress(ctx context.Context, progress ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// ReportProgress passes a status update to the ProgressFunc carried by ctx, if any.
+func ReportProgress(ctx context.Context, done, total int) {
+	progress, ok := ctx.Value(progressKey{}).(ProgressFunc)
+	if !ok || progress == nil {
+		return
+	}
+
+	progress(done, total)
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleGuest  = "guest"
+)
+
+// Role restricts what its users may do. Empty lists allow everything.
+type Role struct {
+	Name string
+	// Models lists the keywords of the allowed models.
+	Models []string
+	// Commands lists the allowed commands, with or without leading slash.
+	Commands []string
+	// SpendCap is the daily spending limit of each user in a chat in dollars, zero for none.
+	SpendCap float64
+}
+
+// AllowsModel reports whether the role may use the model with the keyword.
+func (r Role) AllowsModel(keyword string) bool {
+	return len(r.Models) == 0 || slices.ContainsFunc(r.Models, func(m string) bool {
+		return strings.EqualFold(m, keyword)
+	})
+}
+
+// AllowsCommand reports whether the role may use the command.
+func (r Role) AllowsCommand(command string) bool {
+	return len(r.Commands) == 0 || slices.ContainsFunc(r.Commands, func(c string) bool {
+		return strings.EqualFold(strings.TrimPrefix(c, "/"), strings.TrimPrefix(command, "/"))
+	})
+}
+
+// ModelNotAllowedError is returned for models the role of the user may not use.
+type ModelNotAllowedError struct {
+	Model string
+	Role  string
+}
+
+func (e *ModelNotAllowedError) Error() string {
+	return fmt.Sprintf("the model %s is not available to %ss, see /models", e.Model, e.Role)
+}
+
+type roleKey struct{}
+
+// WithRole returns a context carrying the role of the requesting user.
+func WithRole(ctx context.Context, role Role) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFromContext returns the role of the requesting user, or an unrestricted role if the context has none.
+func RoleFromContext(ctx context.Context) Role {
+	role, _ := ctx.Value(roleKey{}).(Role)
+	return role
+}
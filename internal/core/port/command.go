@@ -6,6 +6,12 @@ import (
 	"time"
 )
 
+// RespondFunc processes a message within a timeout, like Command.Respond.
+type RespondFunc func(ctx context.Context, timeout time.Duration, message *domain.Message) error
+
+// Middleware wraps the respond function of the named command or listener with shared behavior.
+type Middleware func(name string, next RespondFunc) RespondFunc
+
 type Command interface {
 	// Respond processes a given message within a specified timeout and responds to the originating context.
 	Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error
@@ -13,11 +19,102 @@ type Command interface {
 	GetCommand() string
 }
 
+// CommandDescriber is optionally implemented by commands to be listed in /help and the telegram command menu.
+type CommandDescriber interface {
+	// GetDescription returns a short, single line description of the command.
+	GetDescription() string
+	// GetUsage returns how to use the command, including its arguments.
+	GetUsage() string
+}
+
+// AdminCommand is optionally implemented by commands only admins may use. They are only listed to admins.
+type AdminCommand interface {
+	// IsAdminOnly reports whether the command is restricted to admins.
+	IsAdminOnly() bool
+}
+
+// CostEstimator is implemented by commands that can estimate the cost of a request before spending it.
+type CostEstimator interface {
+	// EstimateCost returns the expected cost of responding to the message in dollars, zero if unknown.
+	EstimateCost(ctx context.Context, message *domain.Message) float64
+}
+
+// DocumentReader is optionally implemented by commands reading the document attached to the message. Documents are
+// only downloaded for these commands.
+type DocumentReader interface {
+	// ReadsDocuments reports whether the command needs the document of the message.
+	ReadsDocuments() bool
+}
+
+// AudioReader is optionally implemented by commands and listeners reading the audio attached to the message. Audio
+// is only resolved, and extracted from videos, for these.
+type AudioReader interface {
+	// ReadsAudio reports whether the handler needs the audio of the message.
+	ReadsAudio() bool
+}
+
+type Listener interface {
+	// Matches reports whether the listener wants to handle a message that isn't addressed to a command.
+	Matches(message *domain.Message) bool
+	// Respond processes a given message within a specified timeout and responds to the originating context.
+	Respond(ctx context.Context, timeout time.Duration, message *domain.Message) error
+}
+
+type CallbackHandler interface {
+	// HandleCallback processes a button press with callback data in the handler's namespace.
+	HandleCallback(ctx context.Context, timeout time.Duration, callback *domain.Callback) error
+	// GetCallbackNamespace retrieves the callback data namespace routed to this handler.
+	GetCallbackNamespace() string
+}
+
+// RateLimitedCallbackHandler is optionally implemented by callback handlers bound to a command whose buttons don't all
+// count towards its rate limit, like buttons only swapping a keyboard. Without it, every press counts.
+type RateLimitedCallbackHandler interface {
+	// IsRateLimited reports whether a button press with the callback data counts towards the rate limit.
+	IsRateLimited(data string) bool
+}
+
+// PublicCallbackHandler is optionally implemented by callback handlers that also handle button presses in chats that
+// aren't authorized, checking permissions themselves.
+type PublicCallbackHandler interface {
+	// IsPublic reports whether the handler receives callbacks of unauthorized chats.
+	IsPublic() bool
+}
+
+// CommandBound is optionally implemented by listeners and callback handlers acting for a command, like replies and
+// buttons continuing /chat. The config, permissions and rate limit of the command apply to them.
+type CommandBound interface {
+	// BoundCommand returns the command the listener or callback handler acts for.
+	BoundCommand() string
+}
+
 type CommandRegistry interface {
-	// Register adds a new command handler to the command registry.
-	Register(handler Command)
-	// Get retrieves a registered Command based on its string identifier or returns an error if not found.
+	// Register adds a new command handler to the command registry, responding through the given middlewares.
+	Register(handler Command, middlewares ...Middleware)
+	// Get retrieves a registered Command based on its string identifier or alias or returns an error if not found.
 	Get(command string) (Command, error)
+	// GetConfig retrieves the configuration of a registered Command based on its string identifier or alias.
+	GetConfig(command string) domain.CommandConfig
 	// ListCommands returns a list of all command identifiers currently registered in the command registry.
 	ListCommands() []string
+	// DescribeCommands returns the described commands available in a chat, sorted by command. The zero chat ID
+	// returns the commands available in every chat. Commands of admins are only included for admins.
+	DescribeCommands(chatID int64, admin bool) []domain.CommandInfo
+	// RegisterListener adds a new listener for messages that aren't addressed to a command, responding through the
+	// given middlewares.
+	RegisterListener(listener Listener, middlewares ...Middleware)
+	// GetListener retrieves the first registered Listener matching the message or returns an error if none matches.
+	GetListener(message *domain.Message) (Listener, error)
+	// RegisterCallback adds a new handler for callback data in its namespace.
+	RegisterCallback(handler CallbackHandler)
+	// GetCallback retrieves the CallbackHandler registered for a namespace or returns an error if not found.
+	GetCallback(namespace string) (CallbackHandler, error)
+}
+
+// PaymentHandler is implemented by commands sending invoices, to process their payments.
+type PaymentHandler interface {
+	// HandlePreCheckout accepts or declines a payment before the user is charged.
+	HandlePreCheckout(ctx context.Context, query *domain.PreCheckout) error
+	// HandlePayment processes a successful payment, the message is the one carrying it.
+	HandlePayment(ctx context.Context, message *domain.Message, payment *domain.Payment) error
 }
@@ -1,9 +1,20 @@
 package port
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type ImageConverter interface {
 	// Scale transforms an image with liquid rescaling specified by the imageURL based on the given strength factor and
 	// returns the processed image as bytes.
 	Scale(ctx context.Context, imageURL string, power float32) ([]byte, error)
 }
+
+type AudioConverter interface {
+	// ExtractAudio downloads the video located at videoURL and returns its audio track as MP3 encoded bytes.
+	ExtractAudio(ctx context.Context, videoURL string) ([]byte, error)
+	// SplitAudio downloads the audio located at audioURL and splits it into MP3 encoded segments of the given length,
+	// each overlapping the previous one. Audio fitting into a single segment is returned as one MP3 encoded segment.
+	SplitAudio(ctx context.Context, audioURL string, segment, overlap time.Duration) ([][]byte, error)
+}
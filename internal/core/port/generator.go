@@ -11,6 +11,11 @@ type TextGenerator interface {
 	GenerateFromPrompt(ctx context.Context, prompts []domain.Prompt) (domain.ModelResponse, error)
 }
 
+type ModelProvider interface {
+	// GetModels returns all models available for text generation.
+	GetModels() []domain.Model
+}
+
 type Transcriber interface {
 	// GenerateFromAudio generates a text transcription from the audio file located at the provided URL.
 	// It returns the transcribed text or an error if the transcription fails.
@@ -9,15 +9,42 @@ type TextSender interface {
 	// SendMessageReply sends a reply to a specified message with the given text and returns the sent message ID and
 	// an error if any.
 	SendMessageReply(ctx context.Context, message *domain.Message, text string) (int, error)
+	// SendMessageReplyWithKeyboard sends a reply like SendMessageReply, attaching an inline keyboard to it.
+	SendMessageReplyWithKeyboard(ctx context.Context, message *domain.Message, text string,
+		keyboard domain.Keyboard) (int, error)
+	// EditMessageKeyboard replaces the inline keyboard of a previously sent message. An empty keyboard removes it.
+	EditMessageKeyboard(ctx context.Context, chatID int64, messageID int, keyboard domain.Keyboard) error
+	// AnswerCallbackQuery acknowledges a button press, optionally showing a short notification to the user.
+	AnswerCallbackQuery(ctx context.Context, callbackID string, text string) error
+	// EditMessageText replaces the text of a previously sent message in the given chat.
+	EditMessageText(ctx context.Context, chatID int64, messageID int, text string) error
 	// SendChatAction sends a specified chat action (e.g., typing, sending photo) to indicate activity in a given chat.
 	SendChatAction(ctx context.Context, chatID int64, action domain.Action)
 	// NotifyAndReturnError sends an error notification based on the provided message context and returns the error.
 	NotifyAndReturnError(ctx context.Context, err error, message *domain.Message) error
 }
 
+type DocumentSender interface {
+	// SendDocumentReply sends a file with the given name as a reply to the provided message.
+	SendDocumentReply(ctx context.Context, message *domain.Message, filename string, data []byte) error
+}
+
+type CommandMenu interface {
+	// SetCommands replaces the command menu of a chat, or the default menu for the zero chat ID. Without commands,
+	// the menu of the chat falls back to the default one.
+	SetCommands(ctx context.Context, commands []domain.CommandInfo, chatID int64) error
+}
+
 type ImageSender interface {
 	// SendImageURLReply sends an image to the chat as a reply using a URL in response to the provided message.
 	SendImageURLReply(ctx context.Context, message *domain.Message, url string) error
 	// SendImageFileReply sends an image as a file in response to the provided message within the specified context.
 	SendImageFileReply(ctx context.Context, message *domain.Message, file []byte) error
 }
+
+type InvoiceSender interface {
+	// SendInvoice replies to the message with an invoice payable in Telegram Stars.
+	SendInvoice(ctx context.Context, message *domain.Message, invoice domain.Invoice) error
+	// AnswerPreCheckoutQuery accepts a pending payment, or declines it with the error message if it isn't empty.
+	AnswerPreCheckoutQuery(ctx context.Context, queryID string, errorMessage string) error
+}
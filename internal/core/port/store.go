@@ -0,0 +1,57 @@
+package port
+
+import (
+	"hsbot/internal/core/domain"
+	"time"
+)
+
+type ChatStore interface {
+	// IsChatAllowed reports whether the chat is on the allowlist.
+	IsChatAllowed(chatID int64) bool
+	// AllowedChats returns the IDs of all allowed chats, sorted ascending.
+	AllowedChats() []int64
+	// AllowChat adds a chat to the allowlist and reports whether it was missing before.
+	AllowChat(chatID int64) (bool, error)
+	// DenyChat removes a chat from the allowlist and reports whether it was allowed before.
+	DenyChat(chatID int64) (bool, error)
+}
+
+type AutoReplyStore interface {
+	// IsAutoReplyDisabled reports whether messages without command prefix are ignored in the chat.
+	IsAutoReplyDisabled(chatID int64) bool
+	// SetAutoReplyDisabled toggles ignoring messages without command prefix in the chat.
+	SetAutoReplyDisabled(chatID int64, disabled bool) error
+}
+
+type InviteStore interface {
+	// CreateInvite stores a new invite, failing if its code is taken.
+	CreateInvite(invite domain.Invite) error
+	// RedeemInvite authorizes the chat of the redemption with the invite code. It fails with
+	// domain.ErrInvalidInvite if the code is unknown, expired or used up. Redeeming a code again in the same chat
+	// doesn't use it up.
+	RedeemInvite(code string, redemption domain.Redemption) (domain.Invite, error)
+	// IsChatInvited reports whether the chat redeemed an invite that wasn't revoked.
+	IsChatInvited(chatID int64) bool
+	// InvitedChats returns the IDs of all invited chats, sorted ascending.
+	InvitedChats() []int64
+	// RevokeChat revokes the redemptions of a chat and reports whether it was invited before.
+	RevokeChat(chatID int64) (bool, error)
+}
+
+type Ledger interface {
+	// Record appends the entry to the ledger.
+	Record(entry domain.LedgerEntry) error
+	// Entries returns the entries recorded at or after since, oldest first.
+	Entries(since time.Time) ([]domain.LedgerEntry, error)
+}
+
+type Wallet interface {
+	// Balance returns the prepaid credit of the user in dollars.
+	Balance(userID int64) float64
+	// Deposit adds a top-up to the balance of its user and returns the new balance. It fails with
+	// domain.ErrDuplicateCharge if the charge ID of the top-up was already deposited.
+	Deposit(transaction domain.CreditTransaction) (float64, error)
+	// Withdraw draws up to the amount from the balance of the user for a request in the chat and returns the amount
+	// drawn.
+	Withdraw(userID, chatID int64, amount float64) (float64, error)
+}
@@ -6,49 +6,72 @@ import (
 	"fmt"
 	"hsbot/internal/core/domain"
 	"hsbot/internal/core/port"
+	"slices"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
 type Authorizer interface {
+	// IsAuthorized reports whether the chat may use the bot, telling unauthorized chats how to get access.
 	IsAuthorized(ctx context.Context, chatID int64) bool
+	// IsChatAuthorized reports whether the chat may use the bot without sending anything to the chat.
+	IsChatAuthorized(chatID int64) bool
+	// IsAdmin reports whether the user may administrate the bot.
+	IsAdmin(userID int64) bool
 }
 
 type ChatAuthorizer struct {
-	allowlist []int64
-	sender    port.TextSender
+	chats    port.ChatStore
+	invites  port.InviteStore
+	adminIDs []int64
+	sender   port.TextSender
+	keyboard domain.Keyboard
 }
 
-func NewAuthorizer(sender port.TextSender) (*ChatAuthorizer, error) {
-	var list []int64
+// NewAuthorizer authorizes the chats of the allowlist and the chats that redeemed an invite. Admins are configured by
+// user ID in telegram.admin_ids. The keyboard is attached to the reply of unauthorized chats, like a button to
+// request access.
+func NewAuthorizer(sender port.TextSender, chats port.ChatStore, invites port.InviteStore,
+	keyboard domain.Keyboard) (*ChatAuthorizer, error) {
+	var adminIDs []int64
 
-	err := viper.UnmarshalKey("telegram.allowed_chat_ids", &list)
+	err := viper.UnmarshalKey("telegram.admin_ids", &adminIDs)
 	if err != nil {
-		return nil, errors.New("failed to load allowed chat IDs")
+		return nil, errors.New("failed to load admin user IDs")
 	}
 
 	return &ChatAuthorizer{
-		allowlist: list,
-		sender:    sender,
+		chats:    chats,
+		invites:  invites,
+		adminIDs: adminIDs,
+		sender:   sender,
+		keyboard: keyboard,
 	}, nil
 }
 
 const forbidden = "You are not authorized to use this bot. Please contact @%s with this ID to get access: %d"
 
 func (a *ChatAuthorizer) IsAuthorized(ctx context.Context, chatID int64) bool {
-	for _, id := range a.allowlist {
-		if id == chatID {
-			return true
-		}
+	if a.IsChatAuthorized(chatID) {
+		return true
 	}
 
-	_, err := a.sender.SendMessageReply(ctx,
+	_, err := a.sender.SendMessageReplyWithKeyboard(ctx,
 		&domain.Message{ChatID: chatID},
-		fmt.Sprintf(forbidden, viper.GetString("telegram.admin_username"), chatID))
+		fmt.Sprintf(forbidden, viper.GetString("telegram.admin_username"), chatID),
+		a.keyboard)
 	if err != nil {
 		log.Err(err).Msg("failed to send unauthorized warning")
 	}
 
 	return false
 }
+
+func (a *ChatAuthorizer) IsChatAuthorized(chatID int64) bool {
+	return a.chats.IsChatAllowed(chatID) || a.invites.IsChatInvited(chatID)
+}
+
+func (a *ChatAuthorizer) IsAdmin(userID int64) bool {
+	return userID != 0 && slices.Contains(a.adminIDs, userID)
+}
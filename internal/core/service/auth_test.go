@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -18,6 +19,25 @@ type mockTextSender struct {
 	callCount   int
 	sendReplies []string
 	sendError   error
+	keyboard    domain.Keyboard
+}
+
+func (m *mockTextSender) SendMessageReplyWithKeyboard(ctx context.Context, message *domain.Message, text string,
+	keyboard domain.Keyboard) (int, error) {
+	m.keyboard = keyboard
+	return m.SendMessageReply(ctx, message, text)
+}
+
+func (m *mockTextSender) EditMessageKeyboard(_ context.Context, _ int64, _ int, _ domain.Keyboard) error {
+	panic("implement me")
+}
+
+func (m *mockTextSender) AnswerCallbackQuery(_ context.Context, _ string, _ string) error {
+	panic("implement me")
+}
+
+func (m *mockTextSender) EditMessageText(_ context.Context, _ int64, _ int, _ string) error {
+	panic("implement me")
 }
 
 func (m *mockTextSender) SendChatAction(_ context.Context, _ int64, _ domain.Action) {
@@ -38,6 +58,46 @@ func (m *mockTextSender) SendMessageReply(_ context.Context, _ *domain.Message,
 	return len(text), nil
 }
 
+type fakeChatStore []int64
+
+func (f fakeChatStore) IsChatAllowed(chatID int64) bool {
+	return slices.Contains(f, chatID)
+}
+
+func (f fakeChatStore) AllowedChats() []int64 {
+	return f
+}
+
+func (f fakeChatStore) AllowChat(_ int64) (bool, error) {
+	panic("implement me")
+}
+
+func (f fakeChatStore) DenyChat(_ int64) (bool, error) {
+	panic("implement me")
+}
+
+type fakeInviteStore []int64
+
+func (f fakeInviteStore) CreateInvite(_ domain.Invite) error {
+	panic("implement me")
+}
+
+func (f fakeInviteStore) RedeemInvite(_ string, _ domain.Redemption) (domain.Invite, error) {
+	panic("implement me")
+}
+
+func (f fakeInviteStore) IsChatInvited(chatID int64) bool {
+	return slices.Contains(f, chatID)
+}
+
+func (f fakeInviteStore) InvitedChats() []int64 {
+	return f
+}
+
+func (f fakeInviteStore) RevokeChat(_ int64) (bool, error) {
+	panic("implement me")
+}
+
 func TestNewAuthorizer(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -46,9 +106,9 @@ func TestNewAuthorizer(t *testing.T) {
 		expected []int64
 	}{
 		{
-			name: "loads allowed chat IDs",
+			name: "loads admin IDs",
 			setup: func() {
-				viper.Set("telegram.allowed_chat_ids", []int64{1, 2, 3})
+				viper.Set("telegram.admin_ids", []int64{1, 2, 3})
 			},
 			wantErr:  false,
 			expected: []int64{1, 2, 3},
@@ -56,14 +116,14 @@ func TestNewAuthorizer(t *testing.T) {
 		{
 			name: "invalid type returns error",
 			setup: func() {
-				viper.Set("telegram.allowed_chat_ids", "not a slice")
+				viper.Set("telegram.admin_ids", "not a slice")
 			},
 			wantErr: true,
 		},
 		{
 			name: "empty list is fine",
 			setup: func() {
-				viper.Set("telegram.allowed_chat_ids", []int64{})
+				viper.Set("telegram.admin_ids", []int64{})
 			},
 			wantErr:  false,
 			expected: []int64{},
@@ -75,7 +135,7 @@ func TestNewAuthorizer(t *testing.T) {
 			// Reset viper between tests
 			viper.Reset()
 			tt.setup()
-			auth, err := NewAuthorizer(&mockTextSender{})
+			auth, err := NewAuthorizer(&mockTextSender{}, fakeChatStore{}, fakeInviteStore{}, nil)
 
 			if tt.wantErr {
 				require.Error(t, err)
@@ -83,12 +143,20 @@ func TestNewAuthorizer(t *testing.T) {
 			} else {
 				require.NoError(t, err)
 				assert.NotNil(t, auth)
-				assert.Equal(t, tt.expected, auth.allowlist)
+				assert.Equal(t, tt.expected, auth.adminIDs)
 			}
 		})
 	}
 }
 
+func TestChatAuthorizer_IsAdmin(t *testing.T) {
+	a := &ChatAuthorizer{adminIDs: []int64{42}}
+
+	assert.True(t, a.IsAdmin(42))
+	assert.False(t, a.IsAdmin(43))
+	assert.False(t, a.IsAdmin(0))
+}
+
 func TestChatAuthorizer_IsAuthorized(t *testing.T) {
 	adminUsername := "adminuser"
 	viper.Set("telegram.admin_username", adminUsername) // Set for forbidden message formatting
@@ -96,6 +164,7 @@ func TestChatAuthorizer_IsAuthorized(t *testing.T) {
 	tests := []struct {
 		name         string
 		allowlist    []int64
+		invited      []int64
 		chatID       int64
 		sendErr      error
 		want         bool
@@ -109,6 +178,14 @@ func TestChatAuthorizer_IsAuthorized(t *testing.T) {
 			want:       true,
 			expectSend: false,
 		},
+		{
+			name:       "chatID redeemed an invite",
+			allowlist:  []int64{123},
+			invited:    []int64{-100},
+			chatID:     -100,
+			want:       true,
+			expectSend: false,
+		},
 		{
 			name:         "chatID not allowed sends message",
 			allowlist:    []int64{111, 222},
@@ -131,19 +208,24 @@ func TestChatAuthorizer_IsAuthorized(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockSender := &mockTextSender{sendError: tt.sendErr}
+			keyboard := domain.Keyboard{{{Text: "request", Data: "access:request"}}}
 			a := &ChatAuthorizer{
-				allowlist: tt.allowlist,
-				sender:    mockSender,
+				chats:    fakeChatStore(tt.allowlist),
+				invites:  fakeInviteStore(tt.invited),
+				sender:   mockSender,
+				keyboard: keyboard,
 			}
 
 			ctx := t.Context()
 			got := a.IsAuthorized(ctx, tt.chatID)
 
 			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.want, a.IsChatAuthorized(tt.chatID))
 			if tt.expectSend {
 				assert.True(t, mockSender.sendCalled, "SendMessageReply should have been called")
 				assert.NotEmpty(t, mockSender.sendReplies)
 				assert.Equal(t, tt.expectedText, mockSender.sendReplies[0])
+				assert.Equal(t, keyboard, mockSender.keyboard)
 			} else {
 				assert.False(t, mockSender.sendCalled, "SendMessageReply should not have been called")
 			}
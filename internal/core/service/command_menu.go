@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/port"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// SyncCommandMenu publishes the registered commands as telegram command menu. Chats with a command set differing
+// from the default one get their own menu, the others are reset to the default menu. The commands of admins are only
+// listed in the private chats of the admins. Chats failing to get their
+// menu, like chats the bot was removed from, don't stop the others and are reported together.
+func SyncCommandMenu(ctx context.Context, registry port.CommandRegistry, menu port.CommandMenu,
+	chatIDs, adminIDs []int64) error {
+	defaults := registry.DescribeCommands(0, false)
+
+	err := menu.SetCommands(ctx, defaults, 0)
+	if err != nil {
+		return fmt.Errorf("failed to set default commands: %w", err)
+	}
+
+	var errs []error
+	for _, chatID := range chatIDs {
+		commands := registry.DescribeCommands(chatID, false)
+		if slices.Equal(commands, defaults) {
+			commands = nil
+		}
+
+		err = menu.SetCommands(ctx, commands, chatID)
+		if err != nil {
+			log.Warn().Err(err).Int64("chatId", chatID).Msg("failed to set commands of chat")
+			errs = append(errs, fmt.Errorf("failed to set commands of chat %d: %w", chatID, err))
+		}
+	}
+
+	for _, adminID := range adminIDs {
+		err = menu.SetCommands(ctx, registry.DescribeCommands(adminID, true), adminID)
+		if err != nil {
+			log.Warn().Err(err).Int64("userId", adminID).Msg("failed to set commands of admin")
+			errs = append(errs, fmt.Errorf("failed to set commands of admin %d: %w", adminID, err))
+		}
+	}
+
+	log.Info().Int("commands", len(defaults)).Int("chats", len(chatIDs)).Int("failed", len(errs)).
+		Msg("synchronized command menu")
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRegistry struct {
+	port.CommandRegistry
+	commands map[int64][]domain.CommandInfo
+}
+
+func (f *fakeRegistry) DescribeCommands(chatID int64, admin bool) []domain.CommandInfo {
+	commands, ok := f.commands[chatID]
+	if !ok {
+		commands = f.commands[0]
+	}
+
+	if admin {
+		commands = append(slices.Clone(commands), domain.CommandInfo{Command: "/allow"})
+	}
+
+	return commands
+}
+
+type fakeMenu struct {
+	menus map[int64][]domain.CommandInfo
+	err   error
+	// failing are the chats failing to get their menu.
+	failing []int64
+}
+
+func (f *fakeMenu) SetCommands(_ context.Context, commands []domain.CommandInfo, chatID int64) error {
+	if slices.Contains(f.failing, chatID) {
+		return errors.New("bot was kicked")
+	}
+
+	f.menus[chatID] = commands
+	return f.err
+}
+
+func TestSyncCommandMenu(t *testing.T) {
+	all := []domain.CommandInfo{{Command: "/chat"}, {Command: "/image"}}
+	restricted := []domain.CommandInfo{{Command: "/chat"}}
+
+	registry := &fakeRegistry{commands: map[int64][]domain.CommandInfo{0: all, 2: restricted}}
+	menu := &fakeMenu{menus: map[int64][]domain.CommandInfo{}}
+
+	err := SyncCommandMenu(t.Context(), registry, menu, []int64{1, 2}, []int64{7})
+	require.NoError(t, err)
+
+	admin := []domain.CommandInfo{{Command: "/chat"}, {Command: "/image"}, {Command: "/allow"}}
+	assert.Equal(t, map[int64][]domain.CommandInfo{0: all, 1: nil, 2: restricted, 7: admin}, menu.menus)
+}
+
+func TestSyncCommandMenu_Error(t *testing.T) {
+	registry := &fakeRegistry{commands: map[int64][]domain.CommandInfo{}}
+	menu := &fakeMenu{menus: map[int64][]domain.CommandInfo{}, err: errors.New("fail")}
+
+	err := SyncCommandMenu(t.Context(), registry, menu, []int64{1}, nil)
+	require.EqualError(t, err, "failed to set default commands: fail")
+}
+
+func TestSyncCommandMenu_FailingChat(t *testing.T) {
+	all := []domain.CommandInfo{{Command: "/chat"}}
+	registry := &fakeRegistry{commands: map[int64][]domain.CommandInfo{0: all}}
+	menu := &fakeMenu{menus: map[int64][]domain.CommandInfo{}, failing: []int64{1, 3}}
+
+	err := SyncCommandMenu(t.Context(), registry, menu, []int64{1, 2, 3}, nil)
+	require.EqualError(t, err, "failed to set commands of chat 1: bot was kicked\n"+
+		"failed to set commands of chat 3: bot was kicked")
+
+	assert.Equal(t, map[int64][]domain.CommandInfo{0: all, 2: nil}, menu.menus, "later chats still get their menu")
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type RateLimiter interface {
+	// Allow takes a request of the message's user from the rate limit of the command. If none is left, it replies
+	// with the remaining wait time and returns false.
+	Allow(ctx context.Context, message *domain.Message, command string, limit domain.RateLimit) bool
+	// Wait takes a request of the message's user from the rate limit of the command without replying. If none is
+	// left, it returns the time until the next one, otherwise zero.
+	Wait(message *domain.Message, command string, limit domain.RateLimit) time.Duration
+}
+
+// maxBuckets is the number of buckets after which refilled ones are dropped.
+const maxBuckets = 1024
+
+// TokenBucketLimiter rate limits users per command and chat with token buckets, refilling continuously.
+type TokenBucketLimiter struct {
+	buckets map[bucketKey]*bucket
+	mutex   sync.Mutex
+	sender  port.TextSender
+	now     func() time.Time
+}
+
+type bucketKey struct {
+	command string
+	chatID  int64
+	userID  int64
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is the time the bucket is refilled completely, after which it can be dropped.
+	full time.Time
+}
+
+func NewTokenBucketLimiter(sender port.TextSender) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		buckets: make(map[bucketKey]*bucket),
+		sender:  sender,
+		now:     time.Now,
+	}
+}
+
+const rateLimited = "Slow down, %s is rate limited. Try again in %s."
+
+func (l *TokenBucketLimiter) Allow(ctx context.Context, message *domain.Message, command string,
+	limit domain.RateLimit) bool {
+	wait := l.Wait(message, command, limit)
+	if wait == 0 {
+		return true
+	}
+
+	_, err := l.sender.SendMessageReply(ctx, message, fmt.Sprintf(rateLimited, command, wait))
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to send rate limit warning")
+	}
+
+	return false
+}
+
+func (l *TokenBucketLimiter) Wait(message *domain.Message, command string, limit domain.RateLimit) time.Duration {
+	if !limit.IsSet() {
+		return 0
+	}
+
+	return l.take(bucketKey{command: command, chatID: message.ChatID, userID: message.UserID}, limit)
+}
+
+// take removes a token from the bucket of the key, returning the time until the next token if it is empty.
+func (l *TokenBucketLimiter) take(key bucketKey, limit domain.RateLimit) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	capacity := float64(limit.Requests)
+	perToken := limit.Interval / time.Duration(limit.Requests)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		l.prune(now)
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(capacity, b.tokens+float64(now.Sub(b.updated))/float64(perToken))
+	b.updated = now
+
+	if b.tokens < 1 {
+		// round to milliseconds first, so float inaccuracies don't add a second
+		wait := time.Duration((1 - b.tokens) * float64(perToken)).Round(time.Millisecond)
+		return max(time.Second, (wait + time.Second - 1).Truncate(time.Second))
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((capacity - b.tokens) * float64(perToken)))
+
+	return 0
+}
+
+// prune drops refilled buckets, once there are more than maxBuckets. The caller must hold the mutex.
+func (l *TokenBucketLimiter) prune(now time.Time) {
+	if len(l.buckets) < maxBuckets {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if !b.full.After(now) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(sender *mockTextSender, now *time.Time) *TokenBucketLimiter {
+	l := NewTokenBucketLimiter(sender)
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestTokenBucketLimiter_Allow(t *testing.T) {
+	sender := &mockTextSender{}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(sender, &now)
+	limit := domain.RateLimit{Requests: 2, Interval: time.Minute}
+	message := &domain.Message{ChatID: 1, UserID: 10}
+
+	assert.True(t, l.Allow(t.Context(), message, "/image", limit))
+	assert.True(t, l.Allow(t.Context(), message, "/image", limit))
+	assert.False(t, l.Allow(t.Context(), message, "/image", limit))
+
+	require.Len(t, sender.sendReplies, 1)
+	assert.Equal(t, "Slow down, /image is rate limited. Try again in 30s.", sender.sendReplies[0])
+
+	// other users, chats and commands have their own buckets
+	assert.True(t, l.Allow(t.Context(), &domain.Message{ChatID: 1, UserID: 11}, "/image", limit))
+	assert.True(t, l.Allow(t.Context(), &domain.Message{ChatID: 2, UserID: 10}, "/image", limit))
+	assert.True(t, l.Allow(t.Context(), message, "/edit", limit))
+
+	now = now.Add(20 * time.Second)
+	assert.False(t, l.Allow(t.Context(), message, "/image", limit))
+	assert.Equal(t, "Slow down, /image is rate limited. Try again in 10s.", sender.sendReplies[1])
+
+	now = now.Add(10 * time.Second)
+	assert.True(t, l.Allow(t.Context(), message, "/image", limit))
+	assert.False(t, l.Allow(t.Context(), message, "/image", limit))
+}
+
+func TestTokenBucketLimiter_Wait(t *testing.T) {
+	sender := &mockTextSender{}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(sender, &now)
+	limit := domain.RateLimit{Requests: 1, Interval: time.Minute}
+	message := &domain.Message{ChatID: 1, UserID: 10}
+
+	assert.Zero(t, l.Wait(message, "/chat", limit))
+	assert.Equal(t, time.Minute, l.Wait(message, "/chat", limit))
+	assert.False(t, l.Allow(t.Context(), message, "/chat", limit), "waiting shares the bucket with allowing")
+	assert.Zero(t, l.Wait(message, "/chat", domain.RateLimit{}))
+	assert.Len(t, sender.sendReplies, 1, "only Allow replies")
+}
+
+func TestTokenBucketLimiter_AllowUnset(t *testing.T) {
+	sender := &mockTextSender{}
+	l := NewTokenBucketLimiter(sender)
+
+	for range 10 {
+		assert.True(t, l.Allow(t.Context(), &domain.Message{ChatID: 1}, "/chat", domain.RateLimit{}))
+	}
+
+	assert.False(t, sender.sendCalled)
+	assert.Empty(t, l.buckets)
+}
+
+func TestTokenBucketLimiter_Prune(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(&mockTextSender{}, &now)
+	limit := domain.RateLimit{Requests: 1, Interval: time.Minute}
+
+	for i := range maxBuckets {
+		l.Allow(t.Context(), &domain.Message{ChatID: 1, UserID: int64(i)}, "/image", limit)
+	}
+
+	now = now.Add(time.Minute)
+	l.Allow(t.Context(), &domain.Message{ChatID: 2}, "/image", limit)
+
+	assert.Len(t, l.buckets, 1)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"slices"
+	"strconv"
+
+	"github.com/spf13/viper"
+)
+
+type RoleResolver interface {
+	// Role returns the role of a user in a chat.
+	Role(userID, chatID int64) domain.Role
+}
+
+// ConfigRoles resolves roles from the roles config section. Admins of telegram.admin_ids are always admin, other
+// users get the role assigned to their user ID, to the chat or the default role, in this order.
+type ConfigRoles struct {
+	roles       map[string]domain.Role
+	users       map[int64]string
+	chats       map[int64]string
+	defaultRole string
+	adminIDs    []int64
+}
+
+type roleConfig struct {
+	Models   []string
+	Commands []string
+	SpendCap float64 `mapstructure:"spend_cap"`
+}
+
+func NewConfigRoles() (*ConfigRoles, error) {
+	r := &ConfigRoles{
+		roles:       make(map[string]domain.Role),
+		defaultRole: domain.RoleMember,
+	}
+
+	err := viper.UnmarshalKey("telegram.admin_ids", &r.adminIDs)
+	if err != nil {
+		return nil, errors.New("failed to load admin user IDs")
+	}
+
+	if viper.IsSet("roles.default") {
+		r.defaultRole = viper.GetString("roles.default")
+	}
+
+	for _, name := range []string{domain.RoleAdmin, domain.RoleMember, domain.RoleGuest} {
+		var rc roleConfig
+
+		err = viper.UnmarshalKey("roles."+name, &rc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config of role %s: %w", name, err)
+		}
+
+		r.roles[name] = domain.Role{Name: name, Models: rc.Models, Commands: rc.Commands, SpendCap: rc.SpendCap}
+	}
+
+	if _, ok := r.roles[r.defaultRole]; !ok {
+		return nil, fmt.Errorf("unknown default role %q", r.defaultRole)
+	}
+
+	r.users, err = r.loadAssignments("roles.users")
+	if err != nil {
+		return nil, err
+	}
+
+	r.chats, err = r.loadAssignments("roles.chats")
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// loadAssignments reads a table of IDs and the names of their roles.
+func (r *ConfigRoles) loadAssignments(key string) (map[int64]string, error) {
+	assignments := make(map[int64]string)
+
+	for id, name := range viper.GetStringMapString(key) {
+		parsed, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q in %s", id, key)
+		}
+
+		if _, ok := r.roles[name]; !ok {
+			return nil, fmt.Errorf("unknown role %q of %s in %s", name, id, key)
+		}
+
+		assignments[parsed] = name
+	}
+
+	return assignments, nil
+}
+
+func (r *ConfigRoles) Role(userID, chatID int64) domain.Role {
+	if userID != 0 && slices.Contains(r.adminIDs, userID) {
+		return r.roles[domain.RoleAdmin]
+	}
+
+	if name, ok := r.users[userID]; ok && userID != 0 {
+		return r.roles[name]
+	}
+
+	if name, ok := r.chats[chatID]; ok {
+		return r.roles[name]
+	}
+
+	return r.roles[r.defaultRole]
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"hsbot/internal/core/domain"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigRoles_Role(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.Set("telegram.admin_ids", []int64{1})
+	viper.Set("roles.users", map[string]any{"2": "guest", "3": "member"})
+	viper.Set("roles.chats", map[string]any{"-100": "guest"})
+	viper.Set("roles.member.models", []string{"gpt", "claude"})
+	viper.Set("roles.member.spend_cap", 0.5)
+	viper.Set("roles.guest.models", []string{"gpt"})
+	viper.Set("roles.guest.commands", []string{"/chat", "help"})
+	viper.Set("roles.guest.spend_cap", 0.1)
+
+	r, err := NewConfigRoles()
+	require.NoError(t, err)
+
+	member := domain.Role{Name: domain.RoleMember, Models: []string{"gpt", "claude"}, SpendCap: 0.5}
+	guest := domain.Role{Name: domain.RoleGuest, Models: []string{"gpt"}, Commands: []string{"/chat", "help"},
+		SpendCap: 0.1}
+
+	tests := []struct {
+		name   string
+		userID int64
+		chatID int64
+		want   domain.Role
+	}{
+		{name: "admin", userID: 1, chatID: -100, want: domain.Role{Name: domain.RoleAdmin}},
+		{name: "user role", userID: 2, chatID: 5, want: guest},
+		{name: "user role wins over chat role", userID: 3, chatID: -100, want: member},
+		{name: "chat role", userID: 4, chatID: -100, want: guest},
+		{name: "default role", userID: 4, chatID: 5, want: member},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.Role(tc.userID, tc.chatID))
+		})
+	}
+
+	assert.True(t, guest.AllowsCommand("/help"))
+	assert.False(t, guest.AllowsCommand("/image"))
+	assert.True(t, guest.AllowsModel("GPT"))
+	assert.False(t, guest.AllowsModel("claude"))
+}
+
+func TestNewConfigRoles_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value any
+	}{
+		{name: "unknown default role", key: "roles.default", value: "owner"},
+		{name: "unknown assigned role", key: "roles.users", value: map[string]any{"2": "owner"}},
+		{name: "invalid ID", key: "roles.chats", value: map[string]any{"abc": "guest"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set(tc.key, tc.value)
+
+			_, err := NewConfigRoles()
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// alertTimeout bounds the sending of the alerts of a threshold, which are not bound to a request.
+const alertTimeout = 10 * time.Second
+
+// SpendAlerts warns a chat crossing a threshold of its spending limit and alerts the admins with its top spenders.
+type SpendAlerts struct {
+	sender    port.TextSender
+	adminChat int64
+	adminIDs  []int64
+	now       func() time.Time
+}
+
+// NewSpendAlerts sends admin alerts to telegram.admin_chat_id, or without one to the private chats of the admins in
+// telegram.admin_ids.
+func NewSpendAlerts(sender port.TextSender) (*SpendAlerts, error) {
+	var adminIDs []int64
+
+	err := viper.UnmarshalKey("telegram.admin_ids", &adminIDs)
+	if err != nil {
+		return nil, errors.New("failed to load admin user IDs")
+	}
+
+	return &SpendAlerts{
+		sender:    sender,
+		adminChat: viper.GetInt64("telegram.admin_chat_id"),
+		adminIDs:  adminIDs,
+		now:       time.Now,
+	}, nil
+}
+
+const (
+	thresholdWarning = "Heads-up: this chat used %d%% of its %s spending limit, $%.2f of $%.2f. " +
+		"Limit will reset in %s, at %s."
+	thresholdAlert = "Chat %d used %d%% of its %s spending limit, $%.2f of $%.2f."
+)
+
+func (a *SpendAlerts) ThresholdCrossed(event ThresholdEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), alertTimeout)
+	defer cancel()
+
+	log.Info().Int64("chatId", event.ChatID).Str("window", event.Window).Int("threshold", event.Threshold).
+		Msg("spending threshold crossed")
+
+	warning := fmt.Sprintf(thresholdWarning, event.Threshold, event.Window, event.Spent, event.Limit,
+		event.Reset.Sub(a.now()).Truncate(time.Second), event.Reset.Format(resetLayout))
+	_, err := a.sender.SendMessageReply(ctx, &domain.Message{ChatID: event.ChatID}, warning)
+	if err != nil {
+		log.Warn().Err(err).Int64("chatId", event.ChatID).Msg("failed to send spending threshold warning")
+	}
+
+	alert := formatAlert(event)
+	for _, chatID := range a.adminChats() {
+		_, err := a.sender.SendMessageReply(ctx, &domain.Message{ChatID: chatID}, alert)
+		if err != nil {
+			log.Warn().Err(err).Int64("chatId", chatID).Msg("failed to send spending alert")
+		}
+	}
+}
+
+func (a *SpendAlerts) adminChats() []int64 {
+	if a.adminChat != 0 {
+		return []int64{a.adminChat}
+	}
+
+	return a.adminIDs
+}
+
+// formatAlert lists the top spenders of the event below its summary.
+func formatAlert(event ThresholdEvent) string {
+	sb := &strings.Builder{}
+	sb.WriteString(fmt.Sprintf(thresholdAlert, event.ChatID, event.Threshold, event.Window, event.Spent,
+		event.Limit))
+
+	if len(event.TopSpenders) > 0 {
+		sb.WriteString("\nTop spenders:")
+	}
+
+	for _, spender := range event.TopSpenders {
+		sb.WriteString(fmt.Sprintf("\n%d: $%.2f", spender.UserID, spender.Spent))
+	}
+
+	return sb.String()
+}
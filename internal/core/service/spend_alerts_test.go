@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"hsbot/internal/core/domain"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chatRecorder records the chats of the replies sent.
+type chatRecorder struct {
+	mockTextSender
+	chatIDs []int64
+}
+
+func (c *chatRecorder) SendMessageReply(ctx context.Context, message *domain.Message, text string) (int, error) {
+	c.chatIDs = append(c.chatIDs, message.ChatID)
+	return c.mockTextSender.SendMessageReply(ctx, message, text)
+}
+
+func TestSpendAlerts_ThresholdCrossed(t *testing.T) {
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	event := ThresholdEvent{
+		ChatID:      -100,
+		Window:      Weekly,
+		Threshold:   80,
+		Spent:       4.2,
+		Limit:       5,
+		Reset:       time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC),
+		TopSpenders: []Spender{{UserID: 7, Spent: 3}, {UserID: 8, Spent: 1.2}},
+	}
+
+	tests := []struct {
+		name      string
+		adminChat int64
+		wantChats []int64
+	}{
+		{name: "admin chat", adminChat: -42, wantChats: []int64{-100, -42}},
+		{name: "private chats of the admins", wantChats: []int64{-100, 1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.Set("telegram.admin_ids", []int64{1, 2})
+			viper.Set("telegram.admin_chat_id", tt.adminChat)
+
+			sender := &chatRecorder{}
+			alerts, err := NewSpendAlerts(sender)
+			require.NoError(t, err)
+			alerts.now = func() time.Time { return now }
+
+			alerts.ThresholdCrossed(event)
+
+			assert.Equal(t, tt.wantChats, sender.chatIDs)
+			assert.Equal(t, "Heads-up: this chat used 80% of its weekly spending limit, $4.20 of $5.00. "+
+				"Limit will reset in 108h0m0s, at Mon 20 Jan 00:00 UTC.", sender.sendReplies[0])
+			assert.Equal(t, "Chat -100 used 80% of its weekly spending limit, $4.20 of $5.00.\n"+
+				"Top spenders:\n7: $3.00\n8: $1.20", sender.sendReplies[1])
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"hsbot/internal/core/domain"
 	"hsbot/internal/core/port"
+	"slices"
 	"sync"
 	"time"
 
@@ -13,81 +15,507 @@ import (
 )
 
 type Tracker interface {
-	AddCost(chatID int64, cost float64)
-	CheckLimit(ctx context.Context, chatID int64) bool
+	// AddCost adds the cost of the entry to the spending of its chat and user and records it in the ledger. The
+	// time of the entry is set to now. Beyond the budgets of the chat or the daily allowance of the user, with the
+	// spending cap of the role in the context, the cost is paid from the prepaid balance of the user as far as it goes.
+	AddCost(ctx context.Context, entry domain.LedgerEntry)
+	// CheckLimit reports whether the chat is within its budgets and the user within the daily user limit and the
+	// personal cap, zero for none, or has the prepaid balance to go beyond them. Whichever limit is hit first is
+	// reported to the chat, with the time of its reset.
+	CheckLimit(ctx context.Context, chatID, userID int64, userCap float64) bool
+	// CheckCost reports like CheckLimit whether the chat and the user are within their limits, and whether the
+	// estimated cost of a request fits into what is left of them.
+	CheckCost(ctx context.Context, chatID, userID int64, userCap, cost float64) bool
+	// AllowsCost reports like CheckCost whether the estimated cost of a request fits into the limits of the chat and
+	// the user, without telling the chat.
+	AllowsCost(chatID, userID int64, userCap, cost float64) bool
+	// GetSpent returns the spending of the chat today, without the costs paid from prepaid balances.
 	GetSpent(chatID int64) float64
+	// GetUserSpent returns the spending of the user in the chat today.
+	GetUserSpent(chatID, userID int64) float64
+	// WindowStart returns the start of the current day, week or month in the timezone of the budgets.
+	WindowStart(window string) time.Time
 }
 
+// Budget windows, resetting at the start of each day, week (on Monday) or month.
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// userKey identifies the spending of a user in a chat.
+type userKey struct {
+	chatID, userID int64
+}
+
+// budget accumulates the spending of a window until its reset. The limit applies to each chat, zero for none. The
+// spending of chats leaves out the costs paid from prepaid balances, which are only tracked in credited.
+type budget struct {
+	window   string
+	limit    float64
+	chats    map[int64]float64
+	users    map[userKey]float64
+	credited map[userKey]float64
+	// warned is the highest threshold each chat was notified about.
+	warned map[int64]int
+	reset  time.Time
+}
+
+// SpendNotifier is notified by the tracker when a chat crosses a threshold of one of its budgets.
+type SpendNotifier interface {
+	ThresholdCrossed(event ThresholdEvent)
+}
+
+// ThresholdEvent describes a chat that spent at least Threshold percent of the limit of a budget.
+type ThresholdEvent struct {
+	ChatID    int64
+	Window    string
+	Threshold int
+	Spent     float64
+	Limit     float64
+	Reset     time.Time
+	// TopSpenders are the users of the chat spending the most in the window, highest first.
+	TopSpenders []Spender
+}
+
+// Spender is the spending of a user in a budget window.
+type Spender struct {
+	UserID int64
+	Spent  float64
+}
+
+// maxTopSpenders is the number of top spenders of a ThresholdEvent.
+const maxTopSpenders = 3
+
+// UsageTracker tracks spending in daily, weekly and monthly budgets, which reset at midnight in the configured
+// timezone. The daily budget always exists, as it also tracks the spending of users.
 type UsageTracker struct {
-	chats      map[int64]float64
-	dailyLimit float64
+	budgets    []*budget
+	userLimit  float64
+	location   *time.Location
 	mutex      sync.Mutex
 	sender     port.TextSender
+	notifier   SpendNotifier
+	thresholds []int
+	ledger     port.Ledger
+	wallet     port.Wallet
+	now        func() time.Time
 }
 
-func NewUsageTracker(ctx context.Context, sender port.TextSender) *UsageTracker {
-	ut := &UsageTracker{
-		chats:      make(map[int64]float64),
-		sender:     sender,
-		dailyLimit: viper.GetFloat64("telegram.daily_spend_limit"),
+// NewUsageTracker creates a tracker with the spending limits of telegram.daily_spend_limit, weekly_spend_limit and
+// monthly_spend_limit, reset in the IANA timezone of telegram.spend_timezone, UTC by default. The notifier is told
+// once per window when a chat crosses one of the percentages of a limit in telegram.spend_alert_thresholds. Every
+// cost is recorded in the ledger. Users spending beyond the chat budgets or their daily allowance pay with their
+// balance in the wallet.
+func NewUsageTracker(sender port.TextSender, notifier SpendNotifier, ledger port.Ledger,
+	wallet port.Wallet) (*UsageTracker, error) {
+	location, err := time.LoadLocation(viper.GetString("telegram.spend_timezone"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid spend timezone: %w", err)
+	}
+
+	var thresholds []int
+	err = viper.UnmarshalKey("telegram.spend_alert_thresholds", &thresholds)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spend alert thresholds: %w", err)
 	}
 
-	go ut.ResetDailyLimit(ctx)
+	for _, threshold := range thresholds {
+		if threshold <= 0 || threshold > 100 {
+			return nil, fmt.Errorf("spend alert threshold %d is not a percentage between 1 and 100", threshold)
+		}
+	}
+	slices.Sort(thresholds)
+
+	limits := map[string]float64{
+		Daily:   viper.GetFloat64("telegram.daily_spend_limit"),
+		Weekly:  viper.GetFloat64("telegram.weekly_spend_limit"),
+		Monthly: viper.GetFloat64("telegram.monthly_spend_limit"),
+	}
 
-	return ut
+	t := newUsageTracker(sender, location, time.Now, viper.GetFloat64("telegram.daily_user_spend_limit"), limits)
+	t.notifier = notifier
+	t.thresholds = slices.Compact(thresholds)
+	t.ledger = ledger
+	t.wallet = wallet
+
+	err = t.restore()
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore spending from the ledger: %w", err)
+	}
+
+	return t, nil
+}
+
+func newUsageTracker(sender port.TextSender, location *time.Location, now func() time.Time, userLimit float64,
+	limits map[string]float64) *UsageTracker {
+	t := &UsageTracker{
+		userLimit: userLimit,
+		location:  location,
+		sender:    sender,
+		now:       now,
+	}
+
+	for _, window := range []string{Daily, Weekly, Monthly} {
+		if window != Daily && limits[window] <= 0 {
+			continue
+		}
+
+		t.budgets = append(t.budgets, &budget{window: window, limit: limits[window]})
+	}
+
+	t.roll(t.now())
+
+	return t
 }
 
-func (t *UsageTracker) AddCost(chatID int64, cost float64) {
+// restore adds the costs recorded in the ledger during the current windows to the budgets, so a restart doesn't
+// reset them. Thresholds the chats already reached count as notified.
+func (t *UsageTracker) restore() error {
+	if t.ledger == nil {
+		return nil
+	}
+
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
-	t.chats[chatID] += cost
+
+	now := t.now()
+	t.roll(now)
+
+	starts := make([]time.Time, len(t.budgets))
+	for i, b := range t.budgets {
+		starts[i] = windowStart(b.window, now, t.location)
+	}
+
+	entries, err := t.ledger.Entries(slices.MinFunc(starts, time.Time.Compare))
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		key := userKey{entry.ChatID, entry.UserID}
+		for i, b := range t.budgets {
+			if entry.Time.Before(starts[i]) || !entry.Time.Before(b.reset) {
+				continue
+			}
+
+			b.chats[entry.ChatID] += entry.Cost - entry.Credit
+			b.users[key] += entry.Cost
+			b.credited[key] += entry.Credit
+		}
+	}
+
+	for _, b := range t.budgets {
+		for chatID := range b.chats {
+			_, _ = t.crossed(b, chatID)
+		}
+	}
+
+	log.Debug().Int("entries", len(entries)).Msg("restored spending from the ledger")
+
+	return nil
 }
 
-const overLimit = "You have exceeded your daily spending limit: $%.2f. Limit will reset in %s."
+// AddCost adds the cost to the budgets of the chat and the user, notifying about the thresholds it crossed.
+func (t *UsageTracker) AddCost(ctx context.Context, entry domain.LedgerEntry) {
+	entry.Time = t.now()
+	events := t.add(&entry, t.userLimitOf(domain.RoleFromContext(ctx).SpendCap))
 
-func (t *UsageTracker) CheckLimit(ctx context.Context, chatID int64) bool {
-	if t.chats[chatID] > t.dailyLimit {
-		_, err := t.sender.SendMessageReply(ctx,
-			&domain.Message{ChatID: chatID},
-			fmt.Sprintf(overLimit, t.dailyLimit, time.Until(getNextResetTime()).Truncate(time.Second)))
-		if err != nil {
-			log.Warn().Err(err).Msg("failed to send daily limit exceeded warning")
+	if t.ledger != nil {
+		if err := t.ledger.Record(entry); err != nil {
+			log.Warn().Err(err).Int64("chatId", entry.ChatID).Msg("failed to record ledger entry")
 		}
-		return false
 	}
 
-	return true
+	for _, event := range events {
+		t.notifier.ThresholdCrossed(event)
+	}
 }
 
-func (t *UsageTracker) ResetDailyLimit(ctx context.Context) {
-	reset := getNextResetTime()
+// add adds the cost of the entry, drawing the part beyond the chat budgets or the user limit from the wallet into its
+// credit. It returns the events of the thresholds crossed, which are to be sent without the lock held.
+func (t *UsageTracker) add(entry *domain.LedgerEntry, userLimit float64) []ThresholdEvent {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 
-	for {
-		log.Debug().Time("reset", reset).Msg("running reset timer")
-		select {
-		case <-time.After(time.Until(reset)):
-			log.Debug().Msg("resetting daily limit")
-			t.mutex.Lock()
-			t.chats = make(map[int64]float64)
-			t.mutex.Unlock()
-			time.Sleep(time.Second)
-			reset = getNextResetTime()
-		case <-ctx.Done():
-			log.Debug().Msg("stopping daily limit reset")
-			return
+	t.roll(entry.Time)
+
+	key := userKey{entry.ChatID, entry.UserID}
+	if t.wallet != nil {
+		if over := t.overflow(key, entry.Cost, userLimit); over > 0 {
+			drawn, err := t.wallet.Withdraw(entry.UserID, entry.ChatID, min(over, entry.Cost))
+			if err != nil {
+				log.Warn().Err(err).Int64("userId", entry.UserID).Msg("failed to draw from prepaid balance")
+			}
+			entry.Credit = drawn
 		}
 	}
+
+	var events []ThresholdEvent
+	for _, b := range t.budgets {
+		b.chats[entry.ChatID] += entry.Cost - entry.Credit
+		b.users[key] += entry.Cost
+		b.credited[key] += entry.Credit
+
+		if event, ok := t.crossed(b, entry.ChatID); ok {
+			events = append(events, event)
+		}
+	}
+
+	return events
 }
 
-const hoursPerDay = 24
+// crossed returns the event of the highest threshold the chat reached in the budget, unless it was already notified
+// about it. The caller must hold the lock.
+func (t *UsageTracker) crossed(b *budget, chatID int64) (ThresholdEvent, bool) {
+	if t.notifier == nil || b.limit <= 0 {
+		return ThresholdEvent{}, false
+	}
+
+	reached := 0
+	for _, threshold := range t.thresholds {
+		if b.chats[chatID] >= b.limit*float64(threshold)/100 {
+			reached = threshold
+		}
+	}
+
+	if reached <= b.warned[chatID] {
+		return ThresholdEvent{}, false
+	}
+	b.warned[chatID] = reached
+
+	return ThresholdEvent{
+		ChatID:      chatID,
+		Window:      b.window,
+		Threshold:   reached,
+		Spent:       b.chats[chatID],
+		Limit:       b.limit,
+		Reset:       b.reset,
+		TopSpenders: topSpenders(b, chatID),
+	}, true
+}
+
+// topSpenders returns the users of the chat with the highest spending in the budget.
+func topSpenders(b *budget, chatID int64) []Spender {
+	var spenders []Spender
+	for key, spent := range b.users {
+		if key.chatID == chatID && spent > 0 {
+			spenders = append(spenders, Spender{UserID: key.userID, Spent: spent})
+		}
+	}
 
-func getNextResetTime() time.Time {
-	return time.Now().UTC().AddDate(0, 0, 1).Truncate(time.Hour * hoursPerDay)
+	slices.SortFunc(spenders, func(a, b Spender) int {
+		if c := cmp.Compare(b.Spent, a.Spent); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+
+	if len(spenders) > maxTopSpenders {
+		spenders = spenders[:maxTopSpenders]
+	}
+
+	return spenders
+}
+
+const (
+	overLimit     = "You have exceeded your %s spending limit: $%.2f. Limit will reset in %s, at %s."
+	overUserLimit = "You have exceeded your personal daily spending limit: $%.2f. Limit will reset in %s, at %s."
+	overEstimate  = "This request would cost about $%.2f, more than the $%.2f left of your %s spending limit. " +
+		"Limit will reset in %s, at %s."
+	overUserEstimate = "This request would cost about $%.2f, more than the $%.2f left of your personal daily " +
+		"spending limit. Limit will reset in %s, at %s."
+	topUpHint = " Your prepaid balance is $%.2f, top it up with /topup to keep going."
+)
+
+func (t *UsageTracker) CheckLimit(ctx context.Context, chatID, userID int64, userCap float64) bool {
+	return t.CheckCost(ctx, chatID, userID, userCap, 0)
+}
+
+func (t *UsageTracker) CheckCost(ctx context.Context, chatID, userID int64, userCap, cost float64) bool {
+	text := t.exceededLimit(chatID, userID, userCap, cost)
+	if text == "" {
+		return true
+	}
+
+	_, err := t.sender.SendMessageReply(ctx, &domain.Message{ChatID: chatID}, text)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to send spending limit exceeded warning")
+	}
+
+	return false
+}
+
+func (t *UsageTracker) AllowsCost(chatID, userID int64, userCap, cost float64) bool {
+	return t.exceededLimit(chatID, userID, userCap, cost) == ""
+}
+
+// exceededLimit returns the notice of the first limit that is exceeded, or would be by the cost, or an empty string
+// if all limits are kept or the prepaid balance of the user covers going beyond them.
+func (t *UsageTracker) exceededLimit(chatID, userID int64, userCap, cost float64) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	t.roll(now)
+
+	key := userKey{chatID, userID}
+	userLimit := t.userLimitOf(userCap)
+	text := t.overNotice(key, userLimit, cost, now)
+	if text == "" || t.wallet == nil {
+		return text
+	}
+
+	balance := t.wallet.Balance(userID)
+	if balance > 0 && balance >= min(t.overflow(key, cost, userLimit), cost) {
+		return ""
+	}
+
+	return text + fmt.Sprintf(topUpHint, balance)
+}
+
+// overNotice returns the notice of the first limit that is exceeded, or would be by the cost, the budgets of the chat
+// before the user limit. The caller must hold the lock.
+func (t *UsageTracker) overNotice(key userKey, userLimit, cost float64, now time.Time) string {
+	for _, b := range t.budgets {
+		if b.limit <= 0 {
+			continue
+		}
+
+		spent := b.chats[key.chatID]
+		if spent > b.limit {
+			return fmt.Sprintf(overLimit, b.window, b.limit, t.until(b.reset, now), t.format(b.reset))
+		}
+		if spent+cost > b.limit {
+			return fmt.Sprintf(overEstimate, cost, b.limit-spent, b.window, t.until(b.reset, now), t.format(b.reset))
+		}
+	}
+
+	if userLimit <= 0 {
+		return ""
+	}
+
+	daily := t.budgets[0]
+	spent := daily.users[key] - daily.credited[key]
+	if spent > userLimit {
+		return fmt.Sprintf(overUserLimit, userLimit, t.until(daily.reset, now), t.format(daily.reset))
+	}
+	if spent+cost > userLimit {
+		return fmt.Sprintf(overUserEstimate, cost, userLimit-spent, t.until(daily.reset, now), t.format(daily.reset))
+	}
+
+	return ""
+}
+
+// overflow returns how far the spending of the user in the chat goes with the cost beyond the budgets of the chat or
+// the user limit, zero for none, whichever is further. The caller must hold the lock.
+func (t *UsageTracker) overflow(key userKey, cost, userLimit float64) float64 {
+	var over float64
+	for _, b := range t.budgets {
+		if b.limit > 0 {
+			over = max(over, b.chats[key.chatID]+cost-b.limit)
+		}
+	}
+
+	if userLimit > 0 {
+		daily := t.budgets[0]
+		over = max(over, daily.users[key]-daily.credited[key]+cost-userLimit)
+	}
+
+	return over
+}
+
+// userLimitOf returns the daily limit of a user with the personal cap, the lower one of both, zero for none.
+func (t *UsageTracker) userLimitOf(userCap float64) float64 {
+	if userCap > 0 && (t.userLimit <= 0 || userCap < t.userLimit) {
+		return userCap
+	}
+
+	return t.userLimit
 }
 
 func (t *UsageTracker) GetSpent(chatID int64) float64 {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 
-	return t.chats[chatID]
+	t.roll(t.now())
+
+	return t.budgets[0].chats[chatID]
+}
+
+func (t *UsageTracker) GetUserSpent(chatID, userID int64) float64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.roll(t.now())
+
+	return t.budgets[0].users[userKey{chatID, userID}]
+}
+
+func (t *UsageTracker) WindowStart(window string) time.Time {
+	return windowStart(window, t.now(), t.location)
+}
+
+// roll resets the budgets whose window ended. The caller must hold the lock.
+func (t *UsageTracker) roll(now time.Time) {
+	for _, b := range t.budgets {
+		if b.chats != nil && now.Before(b.reset) {
+			continue
+		}
+
+		if b.chats != nil {
+			log.Debug().Str("window", b.window).Msg("resetting spending limit")
+		}
+
+		b.chats = make(map[int64]float64)
+		b.users = make(map[userKey]float64)
+		b.credited = make(map[userKey]float64)
+		b.warned = make(map[int64]int)
+		b.reset = nextReset(b.window, now, t.location)
+	}
+}
+
+func (t *UsageTracker) until(reset, now time.Time) time.Duration {
+	return reset.Sub(now).Truncate(time.Second)
+}
+
+// resetLayout formats the time a budget resets.
+const resetLayout = "Mon 2 Jan 15:04 MST"
+
+func (t *UsageTracker) format(reset time.Time) string {
+	return reset.In(t.location).Format(resetLayout)
+}
+
+// windowStart returns the start of the day, week or month of now in the location.
+func windowStart(window string, now time.Time, location *time.Location) time.Time {
+	year, month, day := now.In(location).Date()
+
+	switch window {
+	case Weekly:
+		daysSinceMonday := (int(now.In(location).Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, location)
+	case Monthly:
+		return time.Date(year, month, 1, 0, 0, 0, 0, location)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, location)
+	}
+}
+
+// nextReset returns the start of the next day, week or month after now in the location.
+func nextReset(window string, now time.Time, location *time.Location) time.Time {
+	year, month, day := now.In(location).Date()
+
+	switch window {
+	case Weekly:
+		daysUntilMonday := (8 - int(now.In(location).Weekday())) % 7
+		if daysUntilMonday == 0 {
+			daysUntilMonday = 7
+		}
+		return time.Date(year, month, day+daysUntilMonday, 0, 0, 0, 0, location)
+	case Monthly:
+		return time.Date(year, month+1, 1, 0, 0, 0, 0, location)
+	default:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, location)
+	}
 }
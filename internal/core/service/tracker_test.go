@@ -1,112 +1,206 @@
 package service
 
 import (
-	"context"
-	"fmt"
-	"sync"
+	"hsbot/internal/core/domain"
 	"testing"
 	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// newTestTracker returns a tracker at Wednesday noon in Berlin, and a pointer to move its clock.
+func newTestTracker(t *testing.T, sender *mockTextSender, userLimit float64,
+	limits map[string]float64) (*UsageTracker, *time.Time) {
+	t.Helper()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, berlin)
+	tracker := newUsageTracker(sender, berlin, func() time.Time { return now }, userLimit, limits)
+
+	return tracker, &now
+}
+
 func TestAddCost(t *testing.T) {
-	tracker := &UsageTracker{
-		chats: make(map[int64]float64),
-		mutex: sync.Mutex{},
-	}
+	tracker, _ := newTestTracker(t, &mockTextSender{}, 0, map[string]float64{Daily: 5, Weekly: 10})
+
 	tests := []struct {
-		name        string
-		chatID      int64
-		initialCost float64
-		addCost     float64
-		wantTotal   float64
+		name      string
+		chatID    int64
+		addCost   float64
+		wantTotal float64
 	}{
 		{
-			name:        "Add first cost",
-			chatID:      1,
-			initialCost: 0,
-			addCost:     2.50,
-			wantTotal:   2.50,
+			name:      "Add first cost",
+			chatID:    1,
+			addCost:   2.50,
+			wantTotal: 2.50,
 		},
 		{
-			name:        "Add to existing cost",
-			chatID:      2,
-			initialCost: 1.00,
-			addCost:     3.00,
-			wantTotal:   4.00,
+			name:      "Add to existing cost",
+			chatID:    1,
+			addCost:   1.00,
+			wantTotal: 3.50,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			tracker.chats[tt.chatID] = tt.initialCost
-			tracker.AddCost(tt.chatID, tt.addCost)
-			assert.InDelta(t, tt.wantTotal, tracker.chats[tt.chatID], 0.01)
+			tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: tt.chatID, UserID: 7, Cost: tt.addCost})
+			assert.InDelta(t, tt.wantTotal, tracker.GetSpent(tt.chatID), 0.01)
+			assert.InDelta(t, tt.wantTotal, tracker.GetUserSpent(tt.chatID, 7), 0.01)
+			assert.InDelta(t, tt.wantTotal, tracker.budgets[1].chats[tt.chatID], 0.01, "weekly budget")
 		})
 	}
 }
 
 func TestCheckLimit(t *testing.T) {
-	dailyLimit := 5.00
-	viper.Set("telegram.daily_spend_limit", dailyLimit)
+	limits := map[string]float64{Daily: 5, Weekly: 8, Monthly: 20}
+	dailyReset := "Limit will reset in 12h0m0s, at Thu 16 Jan 00:00 CET."
+
 	tests := []struct {
 		name          string
-		chatID        int64
 		spent         float64
+		windowSpent   map[string]float64
+		userSpent     float64
+		userCap       float64
+		userLimit     float64
+		cost          float64
 		expectAllowed bool
-		expectMessage bool
+		expectMessage string
 		simulateErr   error
 	}{
 		{
 			name:          "Below limit",
-			chatID:        1,
 			spent:         4.99,
 			expectAllowed: true,
 		},
 		{
 			name:          "At limit",
-			chatID:        2,
 			spent:         5.00,
 			expectAllowed: true,
 		},
 		{
-			name:          "Above limit and message sent",
-			chatID:        3,
+			name:          "Above daily limit and message sent",
 			spent:         5.01,
-			expectAllowed: false,
-			expectMessage: true,
+			expectMessage: "You have exceeded your daily spending limit: $5.00. " + dailyReset,
 		},
 		{
 			name:          "Above limit with send error",
-			chatID:        4,
 			spent:         7.00,
-			expectAllowed: false,
-			expectMessage: true,
+			expectMessage: "You have exceeded your daily spending limit: $5.00. " + dailyReset,
 			simulateErr:   assert.AnError,
 		},
+		{
+			name:        "Above weekly limit",
+			spent:       9.00,
+			windowSpent: map[string]float64{Daily: 1.00},
+			expectMessage: "You have exceeded your weekly spending limit: $8.00. " +
+				"Limit will reset in 108h0m0s, at Mon 20 Jan 00:00 CET.",
+		},
+		{
+			name:        "Above monthly limit",
+			spent:       21.00,
+			windowSpent: map[string]float64{Daily: 1.00, Weekly: 2.00},
+			expectMessage: "You have exceeded your monthly spending limit: $20.00. " +
+				"Limit will reset in 396h0m0s, at Sat 1 Feb 00:00 CET.",
+		},
+		{
+			name:          "User below cap",
+			spent:         1.00,
+			userSpent:     0.50,
+			userCap:       0.50,
+			expectAllowed: true,
+		},
+		{
+			name:          "User above cap",
+			spent:         1.00,
+			userSpent:     0.51,
+			userCap:       0.50,
+			expectMessage: "You have exceeded your personal daily spending limit: $0.50. " + dailyReset,
+		},
+		{
+			name:          "User without cap",
+			spent:         1.00,
+			userSpent:     1.00,
+			expectAllowed: true,
+		},
+		{
+			name:          "Lower personal cap wins over configured limit",
+			spent:         1.00,
+			userSpent:     0.30,
+			userCap:       0.20,
+			userLimit:     0.50,
+			expectMessage: "You have exceeded your personal daily spending limit: $0.20. " + dailyReset,
+		},
+		{
+			name:          "Lower configured limit wins over personal cap",
+			spent:         1.00,
+			userSpent:     0.30,
+			userCap:       0.50,
+			userLimit:     0.20,
+			expectMessage: "You have exceeded your personal daily spending limit: $0.20. " + dailyReset,
+		},
+		{
+			name:          "Estimate fits into the limit",
+			spent:         4.00,
+			cost:          1.00,
+			expectAllowed: true,
+		},
+		{
+			name:  "Estimate exceeds the daily limit",
+			spent: 4.50,
+			cost:  1.00,
+			expectMessage: "This request would cost about $1.00, more than the $0.50 left of your daily spending " +
+				"limit. " + dailyReset,
+		},
+		{
+			name:        "Estimate exceeds the weekly limit",
+			spent:       7.50,
+			windowSpent: map[string]float64{Daily: 1.00},
+			cost:        1.00,
+			expectMessage: "This request would cost about $1.00, more than the $0.50 left of your weekly spending " +
+				"limit. Limit will reset in 108h0m0s, at Mon 20 Jan 00:00 CET.",
+		},
+		{
+			name:      "Estimate exceeds the personal limit",
+			spent:     1.00,
+			userSpent: 0.40,
+			userCap:   0.50,
+			cost:      0.20,
+			expectMessage: "This request would cost about $0.20, more than the $0.10 left of your personal daily " +
+				"spending limit. " + dailyReset,
+		},
+		{
+			name:          "Chat limit is hit before user limit",
+			spent:         5.50,
+			userSpent:     0.30,
+			userLimit:     0.20,
+			expectMessage: "You have exceeded your daily spending limit: $5.00. " + dailyReset,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockSender := &mockTextSender{sendError: tt.simulateErr}
-			tracker := &UsageTracker{
-				chats:      map[int64]float64{tt.chatID: tt.spent},
-				mutex:      sync.Mutex{},
-				dailyLimit: dailyLimit,
-				sender:     mockSender,
+			tracker, _ := newTestTracker(t, mockSender, tt.userLimit, limits)
+
+			tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: tt.userSpent})
+			tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 8, Cost: tt.spent - tt.userSpent})
+			for _, b := range tracker.budgets {
+				if spent, ok := tt.windowSpent[b.window]; ok {
+					b.chats[1] = spent
+				}
 			}
-			ctx := t.Context()
-			result := tracker.CheckLimit(ctx, tt.chatID)
+
+			result := tracker.CheckCost(t.Context(), 1, 7, tt.userCap, tt.cost)
 			assert.Equal(t, tt.expectAllowed, result)
-			if tt.expectMessage {
+			if tt.expectMessage != "" {
 				assert.Equal(t, 1, mockSender.callCount)
-				assert.NotEmpty(t, mockSender.sendReplies[0])
-				expectedText := fmt.Sprintf(overLimit,
-					tracker.dailyLimit, time.Until(getNextResetTime()).Truncate(time.Second))
-
-				assert.Equal(t, expectedText, mockSender.sendReplies[0])
+				assert.Equal(t, tt.expectMessage, mockSender.sendReplies[0])
 			} else {
 				assert.Equal(t, 0, mockSender.callCount)
 			}
@@ -114,38 +208,308 @@ func TestCheckLimit(t *testing.T) {
 	}
 }
 
+func TestAllowsCost(t *testing.T) {
+	sender := &mockTextSender{}
+	tracker, _ := newTestTracker(t, sender, 1, map[string]float64{Daily: 5})
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 4.5})
+
+	assert.True(t, tracker.AllowsCost(1, 8, 0, 0.5))
+	assert.False(t, tracker.AllowsCost(1, 8, 0, 1))
+	assert.False(t, tracker.AllowsCost(1, 7, 0, 0.1), "the user limit is exceeded")
+	assert.Zero(t, sender.callCount, "the chat isn't told")
+}
+
+func TestUsageTracker_Reset(t *testing.T) {
+	tracker, now := newTestTracker(t, &mockTextSender{}, 0, map[string]float64{Daily: 5, Monthly: 20})
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 3})
+
+	*now = now.Add(11 * time.Hour)
+	assert.InDelta(t, 3.0, tracker.GetSpent(1), 0.001, "still the same day")
+
+	*now = now.Add(time.Hour)
+	assert.InDelta(t, 0.0, tracker.GetSpent(1), 0.001, "reset at midnight in Berlin")
+	assert.InDelta(t, 0.0, tracker.GetUserSpent(1, 7), 0.001)
+	assert.InDelta(t, 3.0, tracker.budgets[1].chats[1], 0.001, "the monthly budget continues")
+}
+
 func TestNewUsageTracker(t *testing.T) {
-	dailyLimit := 10.00
-	viper.Set("telegram.daily_spend_limit", dailyLimit)
+	viper.Reset()
+	t.Cleanup(viper.Reset)
 
-	ctx, cancel := context.WithCancel(t.Context())
-	defer cancel()
+	viper.Set("telegram.daily_spend_limit", 10.0)
+	viper.Set("telegram.monthly_spend_limit", 50.0)
+	viper.Set("telegram.spend_timezone", "America/New_York")
+	viper.Set("telegram.spend_alert_thresholds", []int{100, 50, 80, 50})
 
 	mockSender := &mockTextSender{}
-	tracker := NewUsageTracker(ctx, mockSender)
+	notifier := &recordingNotifier{}
+	tracker, err := NewUsageTracker(mockSender, notifier, nil, nil)
+	require.NoError(t, err)
 
-	assert.NotNil(t, tracker.chats)
-	assert.InDelta(t, dailyLimit, tracker.dailyLimit, 0.01)
+	require.Len(t, tracker.budgets, 2, "no weekly budget without limit")
+	assert.Equal(t, Daily, tracker.budgets[0].window)
+	assert.InDelta(t, 10.0, tracker.budgets[0].limit, 0.01)
+	assert.Equal(t, Monthly, tracker.budgets[1].window)
+	assert.Equal(t, "America/New_York", tracker.location.String())
 	assert.Equal(t, mockSender, tracker.sender)
+	assert.Equal(t, notifier, tracker.notifier)
+	assert.Equal(t, []int{50, 80, 100}, tracker.thresholds)
+
+	viper.Set("telegram.spend_alert_thresholds", []int{80, 120})
+	_, err = NewUsageTracker(mockSender, notifier, nil, nil)
+	require.Error(t, err)
+
+	viper.Set("telegram.spend_alert_thresholds", []int{})
+	viper.Set("telegram.spend_timezone", "Mars/Olympus_Mons")
+	_, err = NewUsageTracker(mockSender, notifier, nil, nil)
+	require.Error(t, err)
 }
 
-func TestGetNextResetTime(t *testing.T) {
-	reset := getNextResetTime()
-	assert.Equal(t, 0, reset.Hour())
-	assert.Equal(t, 0, reset.Minute())
-	assert.Equal(t, 0, reset.Second())
-	assert.Equal(t, time.Now().AddDate(0, 0, 1).Day(), reset.Day())
+type recordingNotifier struct {
+	events []ThresholdEvent
 }
 
-func TestGetSpent(t *testing.T) {
-	tracker := &UsageTracker{
-		chats: map[int64]float64{
-			1: 10.5,
-			2: 0.0,
-			3: 99.99,
-		},
-		mutex: sync.Mutex{},
+func (n *recordingNotifier) ThresholdCrossed(event ThresholdEvent) {
+	n.events = append(n.events, event)
+}
+
+func TestAddCost_Thresholds(t *testing.T) {
+	tracker, now := newTestTracker(t, &mockTextSender{}, 0, map[string]float64{Daily: 10, Weekly: 100})
+	notifier := &recordingNotifier{}
+	tracker.notifier = notifier
+	tracker.thresholds = []int{50, 80, 100}
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 4})
+	assert.Empty(t, notifier.events, "below the first threshold")
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 8, Cost: 2})
+	require.Len(t, notifier.events, 1)
+	assert.Equal(t, ThresholdEvent{
+		ChatID:      1,
+		Window:      Daily,
+		Threshold:   50,
+		Spent:       6,
+		Limit:       10,
+		Reset:       tracker.budgets[0].reset,
+		TopSpenders: []Spender{{UserID: 7, Spent: 4}, {UserID: 8, Spent: 2}},
+	}, notifier.events[0])
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 8, Cost: 0.5})
+	assert.Len(t, notifier.events, 1, "each threshold is notified once")
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 2, UserID: 7, Cost: 1})
+	assert.Len(t, notifier.events, 1, "other chats have their own budgets")
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 8, Cost: 4})
+	require.Len(t, notifier.events, 2)
+	assert.Equal(t, 100, notifier.events[1].Threshold, "skipped thresholds are not notified")
+	assert.Equal(t, []Spender{{UserID: 8, Spent: 6.5}, {UserID: 7, Spent: 4}}, notifier.events[1].TopSpenders)
+
+	*now = now.Add(12 * time.Hour)
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 6})
+	require.Len(t, notifier.events, 3, "thresholds are notified again after the reset")
+	assert.Equal(t, 50, notifier.events[2].Threshold)
+	assert.Equal(t, Daily, notifier.events[2].Window)
+}
+
+func TestNextReset(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		window string
+		now    time.Time
+		want   time.Time
+	}{
+		{name: "daily", window: Daily, now: time.Date(2025, 1, 15, 12, 0, 0, 0, berlin),
+			want: time.Date(2025, 1, 16, 0, 0, 0, 0, berlin)},
+		{name: "daily after midnight in Berlin", window: Daily, now: time.Date(2025, 1, 15, 23, 30, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 17, 0, 0, 0, 0, berlin)},
+		{name: "weekly", window: Weekly, now: time.Date(2025, 1, 15, 12, 0, 0, 0, berlin),
+			want: time.Date(2025, 1, 20, 0, 0, 0, 0, berlin)},
+		{name: "weekly on monday", window: Weekly, now: time.Date(2025, 1, 20, 0, 0, 0, 0, berlin),
+			want: time.Date(2025, 1, 27, 0, 0, 0, 0, berlin)},
+		{name: "weekly on sunday", window: Weekly, now: time.Date(2025, 1, 19, 23, 0, 0, 0, berlin),
+			want: time.Date(2025, 1, 20, 0, 0, 0, 0, berlin)},
+		{name: "monthly", window: Monthly, now: time.Date(2025, 12, 31, 12, 0, 0, 0, berlin),
+			want: time.Date(2026, 1, 1, 0, 0, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, nextReset(tt.window, tt.now, berlin))
+		})
 	}
+}
+
+func TestWindowStart(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		window string
+		now    time.Time
+		want   time.Time
+	}{
+		{name: "daily", window: Daily, now: time.Date(2025, 1, 15, 12, 0, 0, 0, berlin),
+			want: time.Date(2025, 1, 15, 0, 0, 0, 0, berlin)},
+		{name: "daily in UTC evening", window: Daily, now: time.Date(2025, 1, 15, 23, 30, 0, 0, time.UTC),
+			want: time.Date(2025, 1, 16, 0, 0, 0, 0, berlin)},
+		{name: "weekly", window: Weekly, now: time.Date(2025, 1, 15, 12, 0, 0, 0, berlin),
+			want: time.Date(2025, 1, 13, 0, 0, 0, 0, berlin)},
+		{name: "weekly on monday", window: Weekly, now: time.Date(2025, 1, 20, 0, 0, 0, 0, berlin),
+			want: time.Date(2025, 1, 20, 0, 0, 0, 0, berlin)},
+		{name: "weekly on sunday", window: Weekly, now: time.Date(2025, 1, 19, 23, 0, 0, 0, berlin),
+			want: time.Date(2025, 1, 13, 0, 0, 0, 0, berlin)},
+		{name: "monthly", window: Monthly, now: time.Date(2025, 12, 31, 12, 0, 0, 0, berlin),
+			want: time.Date(2025, 12, 1, 0, 0, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, windowStart(tt.window, tt.now, berlin))
+		})
+	}
+}
+
+type memoryLedger struct {
+	entries []domain.LedgerEntry
+	err     error
+}
+
+func (l *memoryLedger) Record(entry domain.LedgerEntry) error {
+	l.entries = append(l.entries, entry)
+	return l.err
+}
+
+func (l *memoryLedger) Entries(_ time.Time) ([]domain.LedgerEntry, error) {
+	return l.entries, l.err
+}
+
+func TestAddCost_Ledger(t *testing.T) {
+	tracker, now := newTestTracker(t, &mockTextSender{}, 0, map[string]float64{Daily: 5})
+	ledger := &memoryLedger{}
+	tracker.ledger = ledger
+
+	entry := domain.LedgerEntry{ChatID: 1, UserID: 7, Username: "alice", Command: "/chat", Model: "openai/gpt-4.1",
+		Tokens: 1200, Cost: 0.5}
+	tracker.AddCost(t.Context(), entry)
+
+	entry.Time = *now
+	assert.Equal(t, []domain.LedgerEntry{entry}, ledger.entries)
+
+	ledger.err = assert.AnError
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 0.25})
+	assert.InDelta(t, 0.75, tracker.GetSpent(1), 0.001, "costs are tracked even if recording fails")
+}
+
+func TestUsageTracker_Restore(t *testing.T) {
+	tracker, now := newTestTracker(t, &mockTextSender{}, 1, map[string]float64{Daily: 10, Monthly: 100})
+	notifier := &recordingNotifier{}
+	tracker.notifier = notifier
+	tracker.thresholds = []int{50}
+	tracker.ledger = &memoryLedger{entries: []domain.LedgerEntry{
+		{Time: now.AddDate(0, -1, 0), ChatID: 1, UserID: 7, Cost: 40},
+		{Time: now.AddDate(0, 0, -3), ChatID: 1, UserID: 7, Cost: 20},
+		{Time: now.Add(-time.Hour), ChatID: 1, UserID: 7, Cost: 4, Credit: 1},
+		{Time: now.Add(-time.Hour), ChatID: 1, UserID: 8, Cost: 2},
+	}}
+
+	require.NoError(t, tracker.restore())
+
+	assert.InDelta(t, 5, tracker.GetSpent(1), 1e-9, "costs paid from balances aren't part of the chat budget")
+	assert.InDelta(t, 4, tracker.GetUserSpent(1, 7), 1e-9)
+	assert.InDelta(t, 25, tracker.budgets[1].chats[1], 1e-9, "earlier months are left out")
+	assert.InDelta(t, 1, tracker.budgets[0].credited[userKey{1, 7}], 1e-9)
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 8, Cost: 0.1})
+	assert.Empty(t, notifier.events, "thresholds reached before the restart aren't notified again")
+}
+
+// memoryWallet keeps the balances of users in memory.
+type memoryWallet struct {
+	balances map[int64]float64
+}
+
+func (w *memoryWallet) Balance(userID int64) float64 {
+	return w.balances[userID]
+}
+
+func (w *memoryWallet) Deposit(transaction domain.CreditTransaction) (float64, error) {
+	w.balances[transaction.UserID] += transaction.Amount
+	return w.balances[transaction.UserID], nil
+}
+
+func (w *memoryWallet) Withdraw(userID, _ int64, amount float64) (float64, error) {
+	amount = min(amount, w.balances[userID])
+	w.balances[userID] -= amount
+	return amount, nil
+}
+
+func TestUsageTracker_PrepaidBalance(t *testing.T) {
+	sender := &mockTextSender{}
+	tracker, _ := newTestTracker(t, sender, 1, map[string]float64{Daily: 5})
+	wallet := &memoryWallet{balances: map[int64]float64{7: 0.5}}
+	tracker.wallet = wallet
+	ledger := &memoryLedger{}
+	tracker.ledger = ledger
+	ctx := domain.WithRole(t.Context(), domain.Role{Name: domain.RoleGuest, SpendCap: 0.25})
+
+	tracker.AddCost(ctx, domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 0.2})
+	assert.InDelta(t, 0.5, wallet.balances[7], 0.001, "within the allowance of the role")
+
+	assert.True(t, tracker.CheckCost(ctx, 1, 7, 0.25, 0.3), "the balance covers the estimate beyond the allowance")
+	assert.False(t, tracker.CheckCost(ctx, 1, 7, 0.25, 0.6))
+	assert.Equal(t, "This request would cost about $0.60, more than the $0.05 left of your personal daily spending "+
+		"limit. Limit will reset in 12h0m0s, at Thu 16 Jan 00:00 CET. Your prepaid balance is $0.50, top it up "+
+		"with /topup to keep going.", sender.sendReplies[0])
+
+	tracker.AddCost(ctx, domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 0.3})
+	assert.InDelta(t, 0.25, wallet.balances[7], 0.001, "the part beyond the allowance is paid from the balance")
+	assert.InDelta(t, 0.25, ledger.entries[1].Credit, 0.001)
+	assert.InDelta(t, 0.25, tracker.GetSpent(1), 0.001, "paid costs don't count towards the chat limits")
+	assert.InDelta(t, 0.5, tracker.GetUserSpent(1, 7), 0.001)
+
+	tracker.AddCost(ctx, domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 0.4})
+	assert.Zero(t, wallet.balances[7])
+	assert.InDelta(t, 0.4, tracker.GetSpent(1), 0.001, "the part the balance doesn't cover is paid by the chat")
+
+	assert.False(t, tracker.CheckLimit(ctx, 1, 7, 0.25))
+	assert.True(t, tracker.CheckLimit(ctx, 1, 8, 0.25), "other users keep their allowance")
+}
+
+func TestUsageTracker_PrepaidBalanceBeyondChatBudget(t *testing.T) {
+	sender := &mockTextSender{}
+	tracker, _ := newTestTracker(t, sender, 0, map[string]float64{Daily: 1, Weekly: 2})
+	wallet := &memoryWallet{balances: map[int64]float64{7: 0.5}}
+	tracker.wallet = wallet
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 8, Cost: 0.9})
+	assert.True(t, tracker.CheckCost(t.Context(), 1, 7, 0, 0.3), "the balance covers going beyond the chat budget")
+	assert.False(t, tracker.CheckCost(t.Context(), 1, 8, 0, 0.3), "users without a balance are stopped")
+	assert.Equal(t, "This request would cost about $0.30, more than the $0.10 left of your daily spending limit. "+
+		"Limit will reset in 12h0m0s, at Thu 16 Jan 00:00 CET. Your prepaid balance is $0.00, top it up with "+
+		"/topup to keep going.", sender.sendReplies[0])
+
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 0.3})
+	assert.InDelta(t, 0.3, wallet.balances[7], 0.001, "the part beyond the chat budget is paid from the balance")
+	assert.InDelta(t, 1, tracker.GetSpent(1), 0.001)
+	assert.InDelta(t, 1, tracker.budgets[1].chats[1], 0.001)
+
+	assert.True(t, tracker.CheckLimit(t.Context(), 1, 7, 0), "the chat budget is used up, but not the balance")
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 0.5})
+	assert.Zero(t, wallet.balances[7])
+	assert.InDelta(t, 1.2, tracker.GetSpent(1), 0.001, "the part the balance doesn't cover is paid by the chat")
+	assert.False(t, tracker.CheckLimit(t.Context(), 1, 7, 0))
+}
+
+func TestGetSpent(t *testing.T) {
+	tracker, _ := newTestTracker(t, &mockTextSender{}, 0, map[string]float64{Daily: 100})
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 1, UserID: 7, Cost: 10.5})
+	tracker.AddCost(t.Context(), domain.LedgerEntry{ChatID: 3, UserID: 7, Cost: 99.99})
 
 	tests := []struct {
 		name   string
@@ -157,11 +521,6 @@ func TestGetSpent(t *testing.T) {
 			chatID: 1,
 			want:   10.5,
 		},
-		{
-			name:   "zero value exists for ID 2",
-			chatID: 2,
-			want:   0.0,
-		},
 		{
 			name:   "spent value exists for ID 3",
 			chatID: 3,
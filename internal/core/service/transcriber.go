@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hsbot/internal/adapters/file"
+	"hsbot/internal/core/domain"
+	"hsbot/internal/core/port"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// ChunkingTranscriber decorates a port.Transcriber, splitting long audio into overlapping segments that are
+// transcribed concurrently and stitched back together.
+type ChunkingTranscriber struct {
+	transcriber port.Transcriber
+	converter   port.AudioConverter
+	segment     time.Duration
+	overlap     time.Duration
+	workers     int
+}
+
+const defaultChunkWorkers = 4
+
+func NewChunkingTranscriber(transcriber port.Transcriber, converter port.AudioConverter) (*ChunkingTranscriber, error) {
+	segment := viper.GetDuration("transcribe.chunk_length")
+	overlap := viper.GetDuration("transcribe.chunk_overlap")
+	if segment > 0 && overlap >= segment {
+		return nil, fmt.Errorf("transcribe.chunk_overlap %s must be shorter than transcribe.chunk_length %s",
+			overlap, segment)
+	}
+
+	workers := viper.GetInt("transcribe.chunk_workers")
+	if workers < 1 {
+		workers = defaultChunkWorkers
+	}
+
+	return &ChunkingTranscriber{
+		transcriber: transcriber,
+		converter:   converter,
+		segment:     segment,
+		overlap:     overlap,
+		workers:     workers,
+	}, nil
+}
+
+func (c *ChunkingTranscriber) GenerateFromAudio(ctx context.Context, url string) (string, error) {
+	// audio of known duration fitting into a single segment needn't be downloaded and probed for splitting
+	duration := domain.AudioDurationFromContext(ctx)
+	if c.segment == 0 || (duration > 0 && duration <= c.segment) {
+		return c.transcriber.GenerateFromAudio(ctx, url)
+	}
+
+	segments, err := c.converter.SplitAudio(ctx, url, c.segment, c.overlap)
+	if err != nil {
+		return "", fmt.Errorf("failed to split audio: %w", err)
+	}
+
+	if len(segments) == 1 {
+		// the single segment was downloaded already, transcribing it avoids downloading the audio again
+		return c.transcriber.GenerateFromAudio(ctx, file.EncodeDataURI("audio/mpeg", segments[0]))
+	}
+
+	log.Debug().Int("segments", len(segments)).Int("workers", c.workers).Msg("transcribing audio segments")
+
+	transcripts, err := c.transcribeSegments(ctx, segments)
+	if err != nil {
+		return "", err
+	}
+
+	return stitchTranscripts(transcripts), nil
+}
+
+// transcribeSegments transcribes all segments with a bounded pool of workers, keeping their order. The first error
+// cancels all remaining work.
+func (c *ChunkingTranscriber) transcribeSegments(ctx context.Context, segments [][]byte) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	transcripts := make([]string, len(segments))
+	jobs := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		done     int
+		firstErr error
+	)
+
+	for range min(c.workers, len(segments)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				text, err := c.transcriber.GenerateFromAudio(ctx, file.EncodeDataURI("audio/mpeg", segments[i]))
+
+				mutex.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("failed to transcribe segment %d: %w", i+1, err)
+						cancel()
+					}
+					mutex.Unlock()
+					continue
+				}
+
+				transcripts[i] = text
+				done++
+				finished := done
+				mutex.Unlock()
+
+				domain.ReportProgress(ctx, finished, len(segments))
+			}
+		}()
+	}
+
+	for i := range segments {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return transcripts, ctx.Err()
+}
+
+// maxOverlapWords limits the number of words compared when removing duplicated text between two segments.
+const maxOverlapWords = 50
+
+// stitchTranscripts joins the transcripts of overlapping segments, dropping the words that were transcribed twice.
+func stitchTranscripts(transcripts []string) string {
+	var words []string
+
+	for _, transcript := range transcripts {
+		next := strings.Fields(transcript)
+		overlap := findOverlap(words, next)
+		words = append(words, next[overlap:]...)
+	}
+
+	return strings.Join(words, " ")
+}
+
+// findOverlap returns the length of the longest suffix of previous that equals a prefix of next, ignoring case and
+// punctuation.
+func findOverlap(previous, next []string) int {
+	for n := min(len(previous), len(next), maxOverlapWords); n > 0; n-- {
+		match := true
+		for i := range n {
+			if normalizeWord(previous[len(previous)-n+i]) != normalizeWord(next[i]) {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return n
+		}
+	}
+
+	return 0
+}
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.Trim(word, ".,;:!?\"'()-…"))
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"hsbot/internal/core/domain"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAudioConverter struct {
+	segments [][]byte
+	err      error
+}
+
+func (m *mockAudioConverter) ExtractAudio(_ context.Context, _ string) ([]byte, error) {
+	return nil, m.err
+}
+
+func (m *mockAudioConverter) SplitAudio(_ context.Context, _ string, _, _ time.Duration) ([][]byte, error) {
+	return m.segments, m.err
+}
+
+// mockSegmentTranscriber returns the decoded data URI payload as transcript.
+type mockSegmentTranscriber struct {
+	mutex sync.Mutex
+	urls  []string
+	err   error
+}
+
+func (m *mockSegmentTranscriber) GenerateFromAudio(_ context.Context, url string) (string, error) {
+	m.mutex.Lock()
+	m.urls = append(m.urls, url)
+	m.mutex.Unlock()
+
+	if m.err != nil {
+		return "", m.err
+	}
+
+	if !strings.HasPrefix(url, "data:") {
+		return "full transcript", nil
+	}
+
+	payload := map[string]string{
+		"data:audio/mpeg;base64,b25l":     "one two three",
+		"data:audio/mpeg;base64,dHdv":     "Three, four five",
+		"data:audio/mpeg;base64,dGhyZWU=": "five six",
+	}
+
+	return payload[url], nil
+}
+
+func TestChunkingTranscriber_GenerateFromAudio(t *testing.T) {
+	viper.Set("transcribe.chunk_length", "10m")
+	viper.Set("transcribe.chunk_overlap", "5s")
+	viper.Set("transcribe.chunk_workers", 2)
+
+	tests := []struct {
+		name        string
+		segments    [][]byte
+		splitErr    error
+		transcribe  error
+		want        string
+		wantErr     bool
+		wantReports int
+	}{
+		{
+			name:     "single segment is transcribed without downloading again",
+			segments: [][]byte{[]byte("one")},
+			want:     "one two three",
+		},
+		{
+			name:        "segments are stitched without overlap",
+			segments:    [][]byte{[]byte("one"), []byte("two"), []byte("three")},
+			want:        "one two three four five six",
+			wantReports: 3,
+		},
+		{
+			name:     "split error",
+			splitErr: errors.New("split failed"),
+			wantErr:  true,
+		},
+		{
+			name:       "segment error",
+			segments:   [][]byte{[]byte("one"), []byte("two")},
+			transcribe: errors.New("transcription failed"),
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			mt := &mockSegmentTranscriber{err: tc.transcribe}
+			ct, err := NewChunkingTranscriber(mt, &mockAudioConverter{segments: tc.segments, err: tc.splitErr})
+			require.NoError(t, err)
+
+			var mutex sync.Mutex
+			reports := 0
+			ctx := domain.WithProgress(t.Context(), func(_, total int) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				assert.Equal(t, len(tc.segments), total)
+				reports++
+			})
+
+			got, err := ct.GenerateFromAudio(ctx, "https://example.com/audio.ogg")
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantReports, reports)
+		})
+	}
+}
+
+func TestChunkingTranscriber_Disabled(t *testing.T) {
+	viper.Set("transcribe.chunk_length", "")
+
+	mt := &mockSegmentTranscriber{}
+	ct, err := NewChunkingTranscriber(mt, &mockAudioConverter{err: errors.New("must not be called")})
+	require.NoError(t, err)
+
+	got, err := ct.GenerateFromAudio(t.Context(), "https://example.com/audio.ogg")
+	require.NoError(t, err)
+	assert.Equal(t, "full transcript", got)
+}
+
+func TestChunkingTranscriber_KnownShortDuration(t *testing.T) {
+	viper.Set("transcribe.chunk_length", "10m")
+	viper.Set("transcribe.chunk_overlap", "5s")
+
+	mt := &mockSegmentTranscriber{}
+	ct, err := NewChunkingTranscriber(mt, &mockAudioConverter{err: errors.New("must not be called")})
+	require.NoError(t, err)
+
+	ctx := domain.WithAudioDuration(t.Context(), 3*time.Second)
+	got, err := ct.GenerateFromAudio(ctx, "https://example.com/audio.ogg")
+	require.NoError(t, err)
+	assert.Equal(t, "full transcript", got)
+}
+
+func TestNewChunkingTranscriber_InvalidOverlap(t *testing.T) {
+	viper.Set("transcribe.chunk_length", "10s")
+	viper.Set("transcribe.chunk_overlap", "10s")
+	t.Cleanup(func() { viper.Set("transcribe.chunk_overlap", "") })
+
+	_, err := NewChunkingTranscriber(&mockSegmentTranscriber{}, &mockAudioConverter{})
+	require.Error(t, err)
+}
+
+func TestStitchTranscripts(t *testing.T) {
+	tests := []struct {
+		name        string
+		transcripts []string
+		want        string
+	}{
+		{
+			name:        "no overlap",
+			transcripts: []string{"hello world", "how are you"},
+			want:        "hello world how are you",
+		},
+		{
+			name:        "overlap ignoring case and punctuation",
+			transcripts: []string{"we met on Monday.", "on monday, we talked"},
+			want:        "we met on Monday. we talked",
+		},
+		{
+			name:        "empty segment",
+			transcripts: []string{"hello", "", "world"},
+			want:        "hello world",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, stitchTranscripts(tc.transcripts))
+		})
+	}
+}
@@ -2,14 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"hsbot/internal/adapters/converter"
 	"hsbot/internal/adapters/generator"
 	"hsbot/internal/adapters/handler"
 	"hsbot/internal/adapters/sender"
+	"hsbot/internal/adapters/store"
+	"hsbot/internal/core/domain"
 	"hsbot/internal/core/domain/command"
+	"hsbot/internal/core/port"
 	"hsbot/internal/core/service"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"time"
 
 	"github.com/go-telegram/bot/models"
@@ -41,34 +46,120 @@ func main() {
 
 	t := sender.NewTelegram(b)
 
-	registry := initHandlers(ctx, t)
+	identity, err := t.GetBotIdentity(ctx)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed resolving bot identity")
+	}
 
-	handlerTimeout, err := time.ParseDuration(viper.GetString("handler.timeout"))
+	log.Info().Str("username", identity.Username).Int64("id", identity.ID).Msg("resolved bot identity")
+
+	ffmpeg, err := converter.NewFFmpeg()
 	if err != nil {
-		log.Panic().Err(err).Msg("invalid timeout for handler in config")
+		log.Panic().Err(err).Msg("failed initializing ffmpeg converter")
+	}
+
+	var seedChatIDs []int64
+	if err := viper.UnmarshalKey("telegram.allowed_chat_ids", &seedChatIDs); err != nil {
+		log.Panic().Err(err).Msg("invalid allowed chat IDs in config")
+	}
+
+	chats, err := store.NewChatFile(filepath.Join(viper.GetString("store.dir"), "chats.json"), seedChatIDs)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed loading allowed chats")
 	}
 
-	auth, err := service.NewAuthorizer(t)
+	invites, err := store.NewInviteFile(filepath.Join(viper.GetString("store.dir"), "invites.json"))
+	if err != nil {
+		log.Panic().Err(err).Msg("failed loading invites")
+	}
+
+	auth, err := service.NewAuthorizer(t, chats, invites, command.AccessRequestKeyboard())
 	if err != nil {
 		log.Panic().Err(err).Msg("failed initializing authorizer")
 	}
 
-	commandHandler := handler.NewCommand(registry, handlerTimeout, auth)
+	roles, err := service.NewConfigRoles()
+	if err != nil {
+		log.Panic().Err(err).Msg("invalid roles in config")
+	}
+
+	registry, topUp := initHandlers(ctx, t, ffmpeg, identity, chats, invites, auth)
+
+	var adminIDs []int64
+	if err := viper.UnmarshalKey("telegram.admin_ids", &adminIDs); err != nil {
+		log.Panic().Err(err).Msg("invalid admin user IDs in config")
+	}
+
+	menuChats := append(chats.AllowedChats(), invites.InvitedChats()...)
+	if err := service.SyncCommandMenu(ctx, registry, t, menuChats, adminIDs); err != nil {
+		log.Err(err).Msg("failed synchronizing command menu")
+	}
+
+	handlerTimeout, err := time.ParseDuration(viper.GetString("handler.timeout"))
+	if err != nil {
+		log.Panic().Err(err).Msg("invalid timeout for handler in config")
+	}
+
+	limiter := service.NewTokenBucketLimiter(t)
+
+	commandHandler := handler.NewCommand(handler.CommandParams{
+		Registry:       registry,
+		Timeout:        handlerTimeout,
+		Authorizer:     auth,
+		Roles:          roles,
+		Limiter:        limiter,
+		AudioConverter: ffmpeg,
+		Identity:       identity,
+		Sender:         t,
+		Middlewares: []port.Middleware{
+			command.Recover(),
+			command.Logging(),
+			command.Authorize(auth, "/start"),
+			command.Permissions(roles, t),
+			command.RateLimit(limiter, registry),
+			command.Timeout(),
+		},
+	})
+
+	if topUp != nil {
+		payments := handler.NewPayment(topUp, handlerTimeout)
+		b.RegisterHandlerMatchFunc(handler.MatchPreCheckoutQuery, payments.HandlePreCheckoutQuery)
+		b.RegisterHandlerMatchFunc(handler.MatchSuccessfulPayment, payments.HandleSuccessfulPayment)
+	}
 
 	b.RegisterHandler(bot.HandlerTypeMessageText, "/", bot.MatchTypePrefix, commandHandler.Handle)
-	b.RegisterHandler(bot.HandlerTypePhotoCaption, "/", bot.MatchTypePrefix, commandHandler.Handle)
+	b.RegisterHandlerMatchFunc(handler.MatchMediaCaption, commandHandler.Handle)
+	b.RegisterHandlerMatchFunc(handler.MatchNonCommand, commandHandler.Handle)
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, "", bot.MatchTypePrefix, commandHandler.HandleCallback)
 
 	log.Info().Msg("bot listening")
 	b.Start(ctx)
 }
 
-func initHandlers(ctx context.Context, t *sender.Telegram) *command.Registry {
+// initHandlers registers the commands and listeners. The top-up command is returned as well, or nil if payments are
+// disabled, to handle the payment updates.
+func initHandlers(ctx context.Context, t *sender.Telegram, ffmpeg *converter.FFmpeg,
+	identity domain.BotIdentity, chats port.ChatStore, invites port.InviteStore,
+	auth service.Authorizer) (*command.Registry, *command.TopUp) {
 	or, err := generator.NewOpenRouter(viper.GetString("openrouter.api_key"),
-		viper.GetString("chat.system_prompt"))
+		viper.GetString("chat.system_prompt"), identity)
 	if err != nil {
 		log.Panic().Err(err).Msg("failed initializing openrouter generator")
 	}
 
+	err = or.SyncCatalog(ctx)
+	if errors.Is(err, domain.ErrModelsNotInCatalog) {
+		log.Panic().Err(err).Msg("failed validating openrouter models against the catalog")
+	}
+	if err != nil {
+		// the models work without the details of the catalog until a refresh gets them
+		log.Err(err).Msg("failed to sync openrouter model catalog, starting without it")
+	}
+
+	if interval := viper.GetDuration("openrouter.catalog_refresh"); interval > 0 {
+		go or.RunCatalogSync(ctx, interval)
+	}
+
 	magick, err := converter.NewMagick()
 	if err != nil {
 		log.Panic().Err(err).Msg("failed initializing magick converter")
@@ -80,33 +171,127 @@ func initHandlers(ctx context.Context, t *sender.Telegram) *command.Registry {
 		viper.GetString("fal.whisper_url"),
 		viper.GetString("fal.api_key"))
 
+	transcriber, err := service.NewChunkingTranscriber(fal, ffmpeg)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed initializing chunking transcriber")
+	}
+
 	registry := &command.Registry{}
 
-	track := service.NewUsageTracker(ctx, t)
+	alerts, err := service.NewSpendAlerts(t)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed initializing spend alerts")
+	}
+
+	ledger, err := store.NewLedgerFile(filepath.Join(viper.GetString("store.dir"), "ledger.jsonl"))
+	if err != nil {
+		log.Panic().Err(err).Msg("failed initializing spend ledger")
+	}
+
+	var wallet port.Wallet
+	var topUp *command.TopUp
+	if viper.GetFloat64("payments.star_price") > 0 {
+		walletFile, err := store.NewWalletFile(filepath.Join(viper.GetString("store.dir"), "wallet.jsonl"))
+		if err != nil {
+			log.Panic().Err(err).Msg("failed loading prepaid balances")
+		}
+
+		topUp, err = command.NewTopUp(walletFile, t, t, "/topup")
+		if err != nil {
+			log.Panic().Err(err).Msg("failed initializing top-ups")
+		}
+
+		wallet = walletFile
+	}
+
+	track, err := service.NewUsageTracker(t, alerts, ledger, wallet)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed initializing usage tracker")
+	}
+
+	confirmations := command.NewConfirmations(t)
+	registry.RegisterCallback(confirmations)
 
 	chat, err := command.NewChat(command.ChatParams{
 		TextGenerator: or,
 		TextSender:    t,
-		Transcriber:   fal,
+		Transcriber:   transcriber,
+		ModelProvider: or,
 		Command:       "/chat",
 		CacheDuration: viper.GetDuration("chat.context_timeout"),
 		Track:         track,
+		Confirmations: confirmations,
 	})
 
 	if err != nil {
 		log.Panic().Err(err).Msg("failed initializing chat handler")
 	}
 
-	registry.Register(chat)
+	typing := command.ChatAction(t, domain.Typing)
+	sendingPhoto := command.ChatAction(t, domain.SendingPhoto)
+
+	retry := command.NewChatRetry(chat, t, "/retry")
+	image := command.NewImage(fal, t, t, track, "/image")
+	edit := command.NewEdit(fal, t, t, track, "/edit")
+
+	registry.Register(chat, command.CostCheck(track, chat, confirmations), typing)
+	registry.RegisterCallback(chat)
+	registry.Register(retry, command.CostCheck(track, retry, confirmations), typing)
 	registry.Register(command.NewModels(or, t, "/models"))
-	registry.Register(command.NewImage(fal, t, t, track, "/image"))
-	registry.Register(command.NewEdit(fal, t, t, track, "/edit"))
-	registry.Register(command.NewScale(magick, t, t, "/scale"))
-	registry.Register(command.NewTranscribe(fal, t, "/transcribe"))
+	registry.Register(image, command.CostCheck(track, image, confirmations), sendingPhoto)
+	registry.Register(edit, command.CostCheck(track, edit, confirmations), sendingPhoto)
+	registry.Register(command.NewScale(magick, t, t, "/scale"), command.ChatAction(t, domain.SendingPhoto))
+	registry.Register(command.NewTranscribe(transcriber, t, "/transcribe"), command.ChatAction(t, domain.Typing))
 	registry.Register(command.NewChatClearContext(chat, t, "/clear"))
+	registry.Register(command.NewChatUndo(chat, t, "/undo"))
+	registry.Register(command.NewChatContext(chat, t, "/context"))
+	registry.Register(command.NewChatExport(chat, t, t, "/export"))
+	registry.Register(command.NewChatImport(chat, t, "/import"))
 	registry.Register(command.NewDebug(t, "/debug"))
-	registry.Register(command.NewSpent(track, t, "/spent"))
-	return registry
+	registry.Register(command.NewSpent(track, ledger, auth, t, t, "/spent"))
+	if topUp != nil {
+		registry.Register(topUp)
+	}
+
+	adminOnly := command.AdminOnly(auth, t)
+	registry.Register(command.NewAllow(chats, t, "/allow"), adminOnly)
+	registry.Register(command.NewDeny(chats, invites, t, "/deny"), adminOnly)
+	registry.Register(command.NewChats(chats, invites, t, "/chats"), adminOnly)
+	registry.Register(command.NewInvite(invites, t, identity.Username, "/invite"), adminOnly)
+	registry.Register(command.NewStart(invites, auth, t, "/start"))
+
+	accessRequest, err := command.NewAccessRequest(chats, t)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed initializing access requests")
+	}
+
+	registry.RegisterCallback(accessRequest)
+
+	autoTranscribe, err := command.NewAutoTranscribe(transcriber, t)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed initializing auto transcribe listener")
+	}
+
+	var disabledChatIDs []int64
+	if err := viper.UnmarshalKey("chat.autoreply_disabled_chat_ids", &disabledChatIDs); err != nil {
+		log.Panic().Err(err).Msg("invalid chat IDs with disabled auto replies in config")
+	}
+
+	autoReplies, err := store.NewAutoReplyFile(filepath.Join(viper.GetString("store.dir"), "autoreply.json"),
+		disabledChatIDs)
+	if err != nil {
+		log.Panic().Err(err).Msg("failed loading auto reply settings")
+	}
+
+	chatReply := command.NewChatReply(chat, identity, autoReplies)
+
+	registry.Register(command.NewChatReplyToggle(chatReply, t, "/autoreply"))
+
+	registry.Register(command.NewHelp(registry, auth, t, "/help"))
+
+	registry.RegisterListener(autoTranscribe, command.SpendLimit(track), typing)
+	registry.RegisterListener(chatReply, command.CostCheck(track, chat, confirmations), typing)
+	return registry, topUp
 }
 
 func initBot() (*bot.Bot, error) {